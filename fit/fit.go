@@ -0,0 +1,357 @@
+// Package fit fits curves through the data points entered on the Data tab
+// with least squares regression.
+package fit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"opencalcc/mathcat"
+	"strconv"
+	"strings"
+)
+
+// Point is a data point.
+type Point struct{ X, Y float64 }
+
+// Points are data points, usable as a plotter.XYer.
+type Points []Point
+
+func (p Points) Len() int                    { return len(p) }
+func (p Points) XY(i int) (float64, float64) { return p[i].X, p[i].Y }
+
+// Model is the kind of curve a fit is.
+type Model int
+
+const (
+	// Linear is y = a + b * x
+	Linear Model = iota
+	// Polynomial is y = a + b * x + c * x ^ 2 + ...
+	Polynomial
+	// Exponential is y = a * e ^ (b * x)
+	Exponential
+	// Logarithmic is y = a + b * ln(x)
+	Logarithmic
+)
+
+var (
+	ErrTooFewPoints = errors.New("Not enough data points")
+	ErrSingular     = errors.New("Data points don't determine a curve")
+)
+
+// Fit is a fitted curve. Coeffs are the a, b, c, ... of its model, and R2 is
+// the coefficient of determination of the curve on the data points: 1 for a
+// perfect fit, and lower the more of the variation in y the curve misses.
+type Fit struct {
+	Model  Model
+	Coeffs []float64
+	R2     float64
+}
+
+// Eval returns the value of the curve at x.
+func (f *Fit) Eval(x float64) float64 {
+	switch f.Model {
+	case Exponential:
+		return f.Coeffs[0] * math.Exp(f.Coeffs[1]*x)
+	case Logarithmic:
+		return f.Coeffs[0] + f.Coeffs[1]*math.Log(x)
+	}
+
+	// Horner's method
+	y := 0.0
+	for i := len(f.Coeffs) - 1; i >= 0; i-- {
+		y = y*x + f.Coeffs[i]
+	}
+	return y
+}
+
+// String returns the curve as an expression of x, which can be graphed.
+func (f *Fit) String() string {
+	switch f.Model {
+	case Exponential:
+		return fmt.Sprintf("%s * exp(%s * x)", number(f.Coeffs[0]), number(f.Coeffs[1]))
+	case Logarithmic:
+		return sum([]string{number(f.Coeffs[0]), term(f.Coeffs[1], "ln(x)")})
+	}
+
+	var terms []string
+	for i := len(f.Coeffs) - 1; i >= 0; i-- {
+		switch {
+		case f.Coeffs[i] == 0 && len(f.Coeffs) > 1:
+			continue
+		case i == 0:
+			terms = append(terms, number(f.Coeffs[i]))
+		case i == 1:
+			terms = append(terms, term(f.Coeffs[i], "x"))
+		default:
+			terms = append(terms, term(f.Coeffs[i], "x ^ "+strconv.Itoa(i)))
+		}
+	}
+	return sum(terms)
+}
+
+// number formats x so mathcat can parse it, which doesn't take the + in 1e+06.
+func number(x float64) string {
+	return strings.Replace(strconv.FormatFloat(x, 'g', 6, 64), "e+", "e", 1)
+}
+
+// term is coeff * x, leaving out coefficients that show as 1.
+func term(coeff float64, x string) string {
+	switch c := number(coeff); c {
+	case "1":
+		return x
+	case "-1":
+		return "-" + x
+	default:
+		return c + " * " + x
+	}
+}
+
+// sum joins terms with + and -, so adding a negative term is written a - b.
+func sum(terms []string) string {
+	if len(terms) == 0 {
+		return "0"
+	}
+
+	res := terms[0]
+	for _, t := range terms[1:] {
+		if strings.HasPrefix(t, "-") {
+			res += " - " + t[1:]
+		} else {
+			res += " + " + t
+		}
+	}
+	return res
+}
+
+// Line fits the line y = a + b * x through points.
+func Line(points Points) (*Fit, error) {
+	fit, err := Poly(points, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	fit.Model = Linear
+	return fit, nil
+}
+
+// Poly fits a polynomial of the given degree through points. It needs at
+// least degree + 1 points with different x values.
+func Poly(points Points, degree int) (*Fit, error) {
+	if degree < 1 {
+		return nil, fmt.Errorf("Invalid degree %d", degree)
+	}
+	if len(points) <= degree {
+		return nil, ErrTooFewPoints
+	}
+
+	rows := make([][]float64, len(points))
+	ys := make([]float64, len(points))
+	for i, p := range points {
+		rows[i] = make([]float64, degree+1)
+		xn := 1.0
+		for j := range rows[i] {
+			rows[i][j] = xn
+			xn *= p.X
+		}
+		ys[i] = p.Y
+	}
+
+	coeffs, err := leastSquares(rows, ys)
+	if err != nil {
+		return nil, err
+	}
+
+	fit := &Fit{Model: Polynomial, Coeffs: coeffs}
+	fit.R2 = rSquared(fit, points)
+	return fit, nil
+}
+
+// Exp fits y = a * e ^ (b * x) through points, by fitting a line through
+// (x, ln(y)). All y values have to be positive.
+func Exp(points Points) (*Fit, error) {
+	logPoints := make(Points, len(points))
+	for i, p := range points {
+		if p.Y <= 0 {
+			return nil, errors.New("Exponential fits need positive y values")
+		}
+		logPoints[i] = Point{p.X, math.Log(p.Y)}
+	}
+
+	line, err := Poly(logPoints, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	fit := &Fit{Model: Exponential, Coeffs: []float64{math.Exp(line.Coeffs[0]), line.Coeffs[1]}}
+	fit.R2 = rSquared(fit, points)
+	return fit, nil
+}
+
+// Log fits y = a + b * ln(x) through points, by fitting a line through
+// (ln(x), y). All x values have to be positive.
+func Log(points Points) (*Fit, error) {
+	logPoints := make(Points, len(points))
+	for i, p := range points {
+		if p.X <= 0 {
+			return nil, errors.New("Logarithmic fits need positive x values")
+		}
+		logPoints[i] = Point{math.Log(p.X), p.Y}
+	}
+
+	line, err := Poly(logPoints, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	fit := &Fit{Model: Logarithmic, Coeffs: line.Coeffs}
+	fit.R2 = rSquared(fit, points)
+	return fit, nil
+}
+
+// rSquared is 1 - SSres / SStot, where SSres is the sum of the squared
+// residuals of fit and SStot that of the deviations from the mean of y. It's
+// 1 if all y values are the same and the fit goes through them.
+func rSquared(fit *Fit, points Points) float64 {
+	mean := 0.0
+	for _, p := range points {
+		mean += p.Y
+	}
+	mean /= float64(len(points))
+
+	var ssRes, ssTot float64
+	for _, p := range points {
+		r := p.Y - fit.Eval(p.X)
+		d := p.Y - mean
+		ssRes += r * r
+		ssTot += d * d
+	}
+
+	if ssTot == 0 {
+		if ssRes == 0 {
+			return 1
+		}
+		return 0
+	}
+
+	return 1 - ssRes/ssTot
+}
+
+// leastSquares solves the overdetermined system a * c = y for c in the least
+// squares sense, with Householder QR decomposition. That avoids the normal
+// equations, which square the condition number and lose half the digits for
+// polynomials of higher degree.
+func leastSquares(a [][]float64, y []float64) ([]float64, error) {
+	m, n := len(a), len(a[0])
+
+	// Work on copies, a is reduced to R and y to Q^T * y
+	r := make([][]float64, m)
+	for i := range a {
+		r[i] = append([]float64(nil), a[i]...)
+	}
+	qty := append([]float64(nil), y...)
+
+	// The length of every column, for telling whether what's left of it
+	// after taking out the columns before it is only rounding errors.
+	// Comparing to the largest entry instead takes columns like 1 and x
+	// next to x ^ 4 for years around 2000 as dependent.
+	lengths := make([]float64, n)
+	for j := range lengths {
+		for i := range r {
+			lengths[j] = math.Hypot(lengths[j], r[i][j])
+		}
+	}
+
+	for k := 0; k < n; k++ {
+		// Householder vector v that reflects column k below the diagonal
+		// onto its first element
+		norm := 0.0
+		for i := k; i < m; i++ {
+			norm = math.Hypot(norm, r[i][k])
+		}
+		if norm <= 1e-14*lengths[k] {
+			return nil, ErrSingular
+		}
+		if r[k][k] > 0 {
+			norm = -norm
+		}
+
+		v := make([]float64, m)
+		for i := k; i < m; i++ {
+			v[i] = r[i][k]
+		}
+		v[k] -= norm
+
+		vv := 0.0
+		for i := k; i < m; i++ {
+			vv += v[i] * v[i]
+		}
+
+		reflect := func(col func(i int) *float64) {
+			dot := 0.0
+			for i := k; i < m; i++ {
+				dot += v[i] * *col(i)
+			}
+			f := 2 * dot / vv
+			for i := k; i < m; i++ {
+				*col(i) -= f * v[i]
+			}
+		}
+
+		for j := k; j < n; j++ {
+			reflect(func(i int) *float64 { return &r[i][j] })
+		}
+		reflect(func(i int) *float64 { return &qty[i] })
+	}
+
+	// Back substitution in R * c = Q^T * y
+	c := make([]float64, n)
+	for i := n - 1; i >= 0; i-- {
+		s := qty[i]
+		for j := i + 1; j < n; j++ {
+			s -= r[i][j] * c[j]
+		}
+		c[i] = s / r[i][i]
+	}
+
+	return c, nil
+}
+
+// ParsePoints reads data points, one per line. The x and y of a point are
+// separated by a comma, or by spaces or a tab like when they're pasted from a
+// spreadsheet. Both can be expressions, like 1/3, sqrt(2). Empty lines are
+// skipped.
+func ParsePoints(text string) (Points, error) {
+	var points Points
+	p := mathcat.New()
+
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.Contains(line, ",") {
+			line = strings.Join(strings.Fields(line), ", ")
+		}
+
+		// The point is evaluated as a vector [x, y]
+		res, err := p.RunComplex("[" + line + "]")
+		if err != nil {
+			return nil, fmt.Errorf("Line %d: %s", i+1, err)
+		}
+		if !res.IsMatrix() || len(res.Matrix) != 1 || len(res.Matrix[0]) != 2 {
+			return nil, fmt.Errorf("Line %d: expecting an x and a y value", i+1)
+		}
+
+		x, y := res.Matrix[0][0], res.Matrix[0][1]
+		if !x.IsReal() || !y.IsReal() {
+			return nil, fmt.Errorf("Line %d: expecting real numbers", i+1)
+		}
+
+		xf, _ := x.Re.Float64()
+		yf, _ := y.Re.Float64()
+		points = append(points, Point{xf, yf})
+	}
+
+	return points, nil
+}
@@ -0,0 +1,145 @@
+package fit
+
+import (
+	"math"
+	"testing"
+)
+
+// sample evaluates f at the given x values.
+func sample(f func(x float64) float64, xs ...float64) Points {
+	points := make(Points, len(xs))
+	for i, x := range xs {
+		points[i] = Point{x, f(x)}
+	}
+	return points
+}
+
+func TestFit(t *testing.T) {
+	xs := []float64{1, 2, 3, 4, 5, 6}
+
+	tests := []struct {
+		name   string
+		fit    func(Points) (*Fit, error)
+		f      func(x float64) float64
+		coeffs []float64
+		expr   string
+	}{
+		{"line", Line, func(x float64) float64 { return 2*x - 1 }, []float64{-1, 2}, "2 * x - 1"},
+		{"quadratic", func(p Points) (*Fit, error) { return Poly(p, 2) },
+			func(x float64) float64 { return x*x - 3*x + 2 }, []float64{2, -3, 1}, "x ^ 2 - 3 * x + 2"},
+		{"cubic", func(p Points) (*Fit, error) { return Poly(p, 3) },
+			func(x float64) float64 { return 0.5 * x * x * x }, []float64{0, 0, 0, 0.5}, "0.5 * x ^ 3"},
+		{"exponential", Exp, func(x float64) float64 { return 3 * math.Exp(0.5*x) }, []float64{3, 0.5},
+			"3 * exp(0.5 * x)"},
+		{"logarithmic", Log, func(x float64) float64 { return 1 - 2*math.Log(x) }, []float64{1, -2},
+			"1 - 2 * ln(x)"},
+	}
+
+	for _, test := range tests {
+		fit, err := test.fit(sample(test.f, xs...))
+		if err != nil {
+			t.Errorf("unexpected error fitting %s: %s", test.name, err)
+			continue
+		}
+
+		for i, c := range test.coeffs {
+			if math.Abs(fit.Coeffs[i]-c) > 1e-9 {
+				t.Errorf("wrong coefficients for %s (expected %v, got %v)", test.name, test.coeffs, fit.Coeffs)
+				break
+			}
+		}
+		if math.Abs(fit.R2-1) > 1e-12 {
+			t.Errorf("wrong R² for %s (expected 1, got %g)", test.name, fit.R2)
+		}
+		if expr := fit.String(); expr != test.expr {
+			t.Errorf("wrong expression for %s (expected %s, got %s)", test.name, test.expr, expr)
+		}
+	}
+}
+
+func TestFitNoise(t *testing.T) {
+	// The least squares line through these is y = 1 + x, with residuals
+	// 1, -1, -1, 1 out of a total variation of 9
+	points := Points{{0, 2}, {1, 1}, {2, 2}, {3, 5}}
+	fit, err := Line(points)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if math.Abs(fit.Coeffs[0]-1) > 1e-12 || math.Abs(fit.Coeffs[1]-1) > 1e-12 {
+		t.Errorf("wrong line (expected [1 1], got %v)", fit.Coeffs)
+	}
+	if r2 := 1 - 4.0/9; math.Abs(fit.R2-r2) > 1e-12 {
+		t.Errorf("wrong R² (expected %g, got %g)", r2, fit.R2)
+	}
+	if y := fit.Eval(10); math.Abs(y-11) > 1e-12 {
+		t.Errorf("wrong value at 10 (expected 11, got %g)", y)
+	}
+
+	// A constant is fitted exactly
+	fit, err = Line(Points{{1, 4}, {2, 4}, {3, 4}})
+	if err != nil || fit.R2 != 1 {
+		t.Errorf("wrong fit of a constant (%v, %v)", fit, err)
+	}
+
+	// High degrees stay accurate away from 0
+	years := sample(func(x float64) float64 { return (x - 2000) * (x - 2000) }, 1998, 1999, 2000, 2001, 2002, 2003)
+	fit, err = Poly(years, 2)
+	if err != nil || math.Abs(fit.Eval(2010)-100) > 1e-4 {
+		t.Errorf("wrong fit of years (%v, %v)", fit, err)
+	}
+
+	// Columns of large x values aren't taken as dependent
+	large := sample(func(x float64) float64 { return 2e-12*x*x + 1 }, 1e6, 1.1e6, 1.2e6, 1.3e6, 1.4e6, 1.5e6, 1.6e6, 1.7e6, 1.8e6, 1.9e6)
+	fit, err = Poly(large, 2)
+	if err != nil || math.Abs(fit.Eval(2e6)-9) > 1e-6 {
+		t.Errorf("wrong fit of large x (%v, %v)", fit, err)
+	}
+	quartic := func(x float64) float64 { d := x - 2005; return d*d*d*d/100 - d }
+	years = sample(quartic, 2000, 2001, 2002, 2003, 2004, 2005, 2006, 2007, 2008, 2009)
+	fit, err = Poly(years, 4)
+	if err != nil || math.Abs(fit.Eval(2010)-quartic(2010)) > 1e-3 {
+		t.Errorf("wrong quartic fit of years (%v, %v)", fit, err)
+	}
+}
+
+func TestFitErrors(t *testing.T) {
+	bad := map[string]func() (*Fit, error){
+		"one point":       func() (*Fit, error) { return Line(Points{{1, 1}}) },
+		"same x":          func() (*Fit, error) { return Line(Points{{1, 1}, {1, 2}, {1, 3}}) },
+		"degree too high": func() (*Fit, error) { return Poly(Points{{1, 1}, {2, 2}, {3, 3}}, 3) },
+		"degree 0":        func() (*Fit, error) { return Poly(Points{{1, 1}, {2, 2}}, 0) },
+		"exp of zero":     func() (*Fit, error) { return Exp(Points{{1, 1}, {2, 0}}) },
+		"log of negative": func() (*Fit, error) { return Log(Points{{-1, 1}, {2, 3}}) },
+		"no points":       func() (*Fit, error) { return Line(nil) },
+	}
+
+	for name, fit := range bad {
+		if _, err := fit(); err == nil {
+			t.Errorf("no error for %s", name)
+		}
+	}
+}
+
+func TestParsePoints(t *testing.T) {
+	points, err := ParsePoints("1, 2\n\n  3 4 \n1/2, sqrt(4)\n5\t-6\n")
+	if err != nil {
+		t.Fatalf("unexpected error parsing points: %s", err)
+	}
+
+	expected := Points{{1, 2}, {3, 4}, {0.5, 2}, {5, -6}}
+	if len(points) != len(expected) {
+		t.Fatalf("wrong points (expected %v, got %v)", expected, points)
+	}
+	for i := range points {
+		if points[i] != expected[i] {
+			t.Errorf("wrong point %d (expected %v, got %v)", i, expected[i], points[i])
+		}
+	}
+
+	for _, text := range []string{"1", "1, 2, 3", "1, x", "1, sqrt(-1)", "1, 2\n3,"} {
+		if _, err := ParsePoints(text); err == nil {
+			t.Errorf("no error parsing bad points '%s'", text)
+		}
+	}
+}
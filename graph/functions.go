@@ -0,0 +1,219 @@
+package graph
+
+import (
+	"fmt"
+	"image/color"
+
+	"gonum.org/v1/plot/vg"
+)
+
+// Color is a colour functions can be drawn in, with a name to pick it by.
+type Color struct {
+	Name  string
+	Value color.RGBA
+}
+
+// Colors are the colours functions can be drawn in. New functions get the
+// first one that isn't used yet.
+var Colors = []Color{
+	{"Red", color.RGBA{R: 255, A: 255}},
+	{"Green", color.RGBA{G: 255, A: 255}},
+	{"Blue", color.RGBA{B: 255, A: 255}},
+	{"Cyan", color.RGBA{G: 255, B: 255, A: 255}},
+	{"Magenta", color.RGBA{R: 255, B: 255, A: 255}},
+	{"Orange", color.RGBA{R: 255, G: 140, A: 255}},
+	{"Purple", color.RGBA{R: 128, B: 128, A: 255}},
+	{"Black", color.RGBA{A: 255}},
+}
+
+// ColorNames returns the names of Colors, in order.
+func ColorNames() []string {
+	names := make([]string, len(Colors))
+	for i, c := range Colors {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// LineStyle is the way the line of a function is drawn.
+type LineStyle int
+
+const (
+	Solid LineStyle = iota
+	Dashed
+	Dotted
+)
+
+// LineStyles are all line styles, in order.
+var LineStyles = []LineStyle{Solid, Dashed, Dotted}
+
+func (s LineStyle) String() string {
+	switch s {
+	case Dashed:
+		return "Dashed"
+	case Dotted:
+		return "Dotted"
+	}
+	return "Solid"
+}
+
+// Dashes returns the dash pattern of s for a plotter.Line, which is nil for
+// solid lines.
+func (s LineStyle) Dashes() []vg.Length {
+	switch s {
+	case Dashed:
+		return []vg.Length{vg.Points(8), vg.Points(4)}
+	case Dotted:
+		return []vg.Length{vg.Points(2), vg.Points(3)}
+	}
+	return nil
+}
+
+// LineStyleNames returns the names of LineStyles, in order.
+func LineStyleNames() []string {
+	names := make([]string, len(LineStyles))
+	for i, s := range LineStyles {
+		names[i] = s.String()
+	}
+	return names
+}
+
+// Mode is the kind of functions the graph plots.
+type Mode int
+
+const (
+	// Cartesian functions are y = f(x)
+	Cartesian Mode = iota
+	// Parametric functions are curves x = f(t), y = g(t)
+	Parametric
+	// Polar functions are curves r = f(θ)
+	Polar
+)
+
+// Modes are all modes, in order.
+var Modes = []Mode{Cartesian, Parametric, Polar}
+
+func (m Mode) String() string {
+	switch m {
+	case Parametric:
+		return "Parametric"
+	case Polar:
+		return "Polar"
+	}
+	return "Function"
+}
+
+// ModeNames returns the names of Modes, in order.
+func ModeNames() []string {
+	names := make([]string, len(Modes))
+	for i, m := range Modes {
+		names[i] = m.String()
+	}
+	return names
+}
+
+// Function is a function on the Graph tab. Expr is the function of x plotted
+// in Cartesian mode, X and Y are the functions of t plotted in Parametric mode
+// and R is the function of θ plotted in Polar mode, so switching modes keeps
+// all of them.
+type Function struct {
+	Expr    string
+	X, Y    string
+	R       string
+	Color   Color
+	Style   LineStyle
+	Visible bool
+}
+
+// Empty reports whether f has nothing to plot in mode.
+func (f *Function) Empty(mode Mode) bool {
+	switch mode {
+	case Parametric:
+		return f.X == "" || f.Y == ""
+	case Polar:
+		return f.R == ""
+	}
+	return f.Expr == ""
+}
+
+// Label is f as shown in the legend of a graph in mode.
+func (f *Function) Label(mode Mode) string {
+	switch mode {
+	case Parametric:
+		return fmt.Sprintf("(%s, %s)", f.X, f.Y)
+	case Polar:
+		return "r = " + f.R
+	}
+	return f.Expr
+}
+
+// Functions is the list of functions on the Graph tab, which users add and
+// remove functions to.
+type Functions []*Function
+
+// Add appends an empty, visible function in the first colour no other
+// function has, and returns it.
+func (fs *Functions) Add() *Function {
+	f := &Function{Color: fs.nextColor(), Visible: true}
+	*fs = append(*fs, f)
+	return f
+}
+
+func (fs Functions) nextColor() Color {
+	for _, c := range Colors {
+		used := false
+		for _, f := range fs {
+			if f.Color.Name == c.Name {
+				used = true
+				break
+			}
+		}
+		if !used {
+			return c
+		}
+	}
+
+	// All colours are taken, start over
+	return Colors[len(fs)%len(Colors)]
+}
+
+// Remove removes the function at index i.
+func (fs *Functions) Remove(i int) {
+	if i < 0 || i >= len(*fs) {
+		return
+	}
+	*fs = append((*fs)[:i], (*fs)[i+1:]...)
+}
+
+// Names returns names to pick the functions by, "Func 1" for the first one
+// and so on.
+func (fs Functions) Names() []string {
+	names := make([]string, len(fs))
+	for i := range fs {
+		names[i] = fmt.Sprintf("Func %d", i+1)
+	}
+	return names
+}
+
+// Plotted returns the functions that are drawn in mode: the visible ones that
+// aren't empty.
+func (fs Functions) Plotted(mode Mode) Functions {
+	var res Functions
+	for _, f := range fs {
+		if f.Visible && !f.Empty(mode) {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+// FirstEmpty returns the index of the first function without an expression
+// of x, or -1 if there's none.
+func (fs Functions) FirstEmpty() int {
+	for i, f := range fs {
+		if f.Expr == "" {
+			return i
+		}
+	}
+	return -1
+}
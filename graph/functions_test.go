@@ -0,0 +1,94 @@
+package graph
+
+import (
+	"testing"
+)
+
+func TestFunctions(t *testing.T) {
+	var fs Functions
+	for i := 0; i < 3; i++ {
+		fs.Add()
+	}
+
+	for i, f := range fs {
+		if f.Color.Name != Colors[i].Name || !f.Visible {
+			t.Errorf("wrong new function %d (expected a visible %s one, got %+v)", i, Colors[i].Name, f)
+		}
+	}
+
+	// The colour of a removed function is given to the next new one
+	fs.Remove(1)
+	fs.Remove(5)
+	if f := fs.Add(); f.Color.Name != "Green" {
+		t.Errorf("wrong colour after removing a function (expected Green, got %s)", f.Color.Name)
+	}
+	if names := fs.Names(); len(names) != 3 || names[2] != "Func 3" {
+		t.Errorf("wrong names %v", names)
+	}
+
+	fs[0].Expr = "x"
+	fs[1].Expr = "x ^ 2"
+	fs[1].Visible = false
+	if plotted := fs.Plotted(Cartesian); len(plotted) != 1 || plotted[0] != fs[0] {
+		t.Errorf("wrong plotted functions %v", plotted)
+	}
+	if i := fs.FirstEmpty(); i != 2 {
+		t.Errorf("wrong first empty function (expected 2, got %d)", i)
+	}
+	fs[2].Expr = "1"
+	if i := fs.FirstEmpty(); i != -1 {
+		t.Errorf("wrong first empty function (expected -1, got %d)", i)
+	}
+
+	// Colours are reused once they've all been taken
+	for len(fs) < len(Colors)+1 {
+		fs.Add()
+	}
+	if f := fs[len(Colors)]; f.Color.Name != Colors[0].Name {
+		t.Errorf("wrong colour when all are taken (expected %s, got %s)", Colors[0].Name, f.Color.Name)
+	}
+}
+
+func TestFunctionModes(t *testing.T) {
+	f := &Function{Expr: "x ^ 2", X: "cos(t)", Visible: true}
+	fs := Functions{f}
+
+	if f.Empty(Cartesian) || !f.Empty(Parametric) {
+		t.Error("wrong emptiness of a function with only x(t)")
+	}
+	if len(fs.Plotted(Parametric)) != 0 {
+		t.Error("plotted a curve without y(t)")
+	}
+
+	f.Y = "sin(t)"
+	if len(fs.Plotted(Parametric)) != 1 {
+		t.Error("curve isn't plotted")
+	}
+	if label := f.Label(Parametric); label != "(cos(t), sin(t))" {
+		t.Errorf("wrong label of curve (got %s)", label)
+	}
+	if label := f.Label(Cartesian); label != "x ^ 2" {
+		t.Errorf("wrong label of function (got %s)", label)
+	}
+	f.R = "1 + cos(θ)"
+	if f.Empty(Polar) || f.Label(Polar) != "r = 1 + cos(θ)" {
+		t.Errorf("wrong polar function (got %s)", f.Label(Polar))
+	}
+	if names := ModeNames(); len(names) != len(Modes) || names[0] != "Function" || names[2] != "Polar" {
+		t.Errorf("wrong mode names %v", names)
+	}
+}
+
+func TestLineStyle(t *testing.T) {
+	if Solid.Dashes() != nil {
+		t.Error("solid lines shouldn't have dashes")
+	}
+	for _, s := range []LineStyle{Dashed, Dotted} {
+		if len(s.Dashes()) != 2 {
+			t.Errorf("wrong dashes for %s: %v", s, s.Dashes())
+		}
+	}
+	if names := LineStyleNames(); len(names) != 3 || names[1] != "Dashed" {
+		t.Errorf("wrong line style names %v", names)
+	}
+}
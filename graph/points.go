@@ -0,0 +1,379 @@
+// Package graph samples the functions and curves entered on the Graph tab and
+// finds points of interest on them.
+package graph
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"opencalcc/mathcat"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+// Points are sampled points of a function, usable as a plotter.XYer.
+type Points []struct{ X, Y float64 }
+
+func (p Points) Len() int                    { return len(p) }
+func (p Points) XY(i int) (float64, float64) { return p[i].X, p[i].Y }
+
+// compile parses expr once and returns it as a function of one variable,
+// which is x for functions, t for parametric curves and θ for polar ones. The
+// variable can go by more than one name, like θ and theta. The returned
+// function reports false for values where expr can't be evaluated or doesn't
+// give a finite result.
+func compile(expr string, variable ...string) (func(x float64) (float64, bool), error) {
+	e, err := mathcat.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(x float64) (float64, bool) {
+		vars := make(map[string]*big.Rat, len(variable))
+		for _, name := range variable {
+			vars[name] = new(big.Rat).SetFloat64(x)
+		}
+
+		res, err := e.Eval(vars)
+		if err != nil || res == nil {
+			return math.NaN(), false
+		}
+
+		y, _ := res.Float64()
+		if math.IsInf(y, 0) || math.IsNaN(y) {
+			return math.NaN(), false
+		}
+
+		return y, true
+	}, nil
+}
+
+// Sampling starts from initialSamples evenly spaced intervals and halves
+// them where the curve bends, at most maxRefinements times, stopping early
+// once a function has been sampled at maxSamples points.
+const (
+	initialSamples = 200
+	maxRefinements = 10
+	maxSamples     = 20000
+)
+
+// sample is f evaluated at x, ok is false where f is undefined. depth is how
+// often the interval from this sample to the next one has been halved, and
+// jump is how much f changed over the interval it was halved from.
+type sample struct {
+	x, y  float64
+	ok    bool
+	depth int
+	jump  float64
+}
+
+// GeneratePoints samples expr, a function of x, across the domain of view.
+// It returns the curve as segments to be drawn as separate lines, broken
+// where expr is undefined and at jumps and asymptotes like those of 1/x and
+// tan(x).
+//
+// Sampling is adaptive: intervals are halved where the curve bends more than
+// a pixel or so away from a straight line between its ends, so smooth parts
+// get few points and sharp turns many. Intervals that still jump after
+// halving as often as allowed are discontinuities, while a curve that's only
+// steep straightens out as it's halved and stays connected: halving an
+// interval of a continuous curve about halves how much it changes over it,
+// while an interval across a jump keeps most of the jump.
+func GeneratePoints(expr string, view View) []Points {
+	if expr == "" || !view.Valid() {
+		return nil
+	}
+
+	f, err := compile(expr, "x")
+	if err != nil {
+		return nil
+	}
+
+	tol := (view.YMax - view.YMin) / 1000
+	eval := func(x float64) sample {
+		y, ok := f(x)
+		return sample{x: x, y: y, ok: ok, jump: math.NaN()}
+	}
+
+	samples := make([]sample, initialSamples+1)
+	dx := (view.XMax - view.XMin) / initialSamples
+	for i := range samples {
+		samples[i] = eval(view.XMin + float64(i)*dx)
+	}
+
+	// offscreen reports whether a and b are both above or both below view,
+	// where how the curve bends between them doesn't show
+	offscreen := func(a, b sample) bool {
+		return (a.y > view.YMax && b.y > view.YMax) || (a.y < view.YMin && b.y < view.YMin)
+	}
+
+	for pass := 0; pass < maxRefinements; pass++ {
+		// split[i] is set if the interval from sample i to i + 1 is halved
+		split := make([]bool, len(samples)-1)
+		count := 0
+		mark := func(i int) {
+			if !split[i] && !(samples[i].ok && samples[i+1].ok && offscreen(samples[i], samples[i+1])) {
+				split[i] = true
+				count++
+			}
+		}
+
+		for i := range split {
+			// Narrow down where the function becomes undefined
+			if samples[i].ok != samples[i+1].ok {
+				mark(i)
+			}
+		}
+		for i := 1; i+1 < len(samples); i++ {
+			a, m, b := samples[i-1], samples[i], samples[i+1]
+			if !a.ok || !m.ok || !b.ok {
+				continue
+			}
+
+			line := a.y + (b.y-a.y)*(m.x-a.x)/(b.x-a.x)
+			if math.Abs(m.y-line) > tol {
+				mark(i - 1)
+				mark(i)
+			}
+		}
+
+		if count == 0 || len(samples)+count > maxSamples {
+			break
+		}
+
+		refined := make([]sample, 0, len(samples)+count)
+		for i, s := range samples[:len(split)] {
+			if !split[i] {
+				refined = append(refined, s)
+				continue
+			}
+
+			next := samples[i+1]
+			m := eval((s.x + next.x) / 2)
+			s.depth, m.depth = s.depth+1, s.depth+1
+			s.jump, m.jump = math.Abs(next.y-s.y), math.Abs(next.y-s.y)
+			refined = append(refined, s, m)
+		}
+		samples = append(refined, samples[len(samples)-1])
+	}
+
+	// jumps reports whether the curve jumps between samples a and b: their
+	// interval was halved as often as allowed, the curve changes visibly over
+	// it and about as much as over the interval it was halved from
+	jumps := func(a, b sample) bool {
+		if !a.ok || !b.ok || offscreen(a, b) || a.depth < maxRefinements {
+			return false
+		}
+
+		jump := math.Abs(b.y - a.y)
+		return jump > 10*tol && jump > 0.75*a.jump
+	}
+
+	// Points far off screen are pulled in so they stay drawable, which
+	// doesn't change how the curve looks
+	maxY := view.YMax + 1e6*(view.YMax-view.YMin)
+	minY := view.YMin - 1e6*(view.YMax-view.YMin)
+
+	var segments []Points
+	var segment Points
+	for i, s := range samples {
+		if i > 0 && jumps(samples[i-1], s) {
+			segments = append(segments, segment)
+			segment = nil
+		}
+
+		if !s.ok {
+			if len(segment) > 0 {
+				segments = append(segments, segment)
+			}
+			segment = nil
+			continue
+		}
+
+		segment = append(segment, struct{ X, Y float64 }{s.x, math.Max(minY, math.Min(maxY, s.y))})
+	}
+	if len(segment) > 0 {
+		segments = append(segments, segment)
+	}
+
+	return segments
+}
+
+// GenerateCurve samples the parametric curve (xt, yt), functions of t, with
+// the steps of param. The curve is broken into segments where either function
+// is undefined.
+func GenerateCurve(xt, yt string, param Interval) []Points {
+	if xt == "" || yt == "" {
+		return nil
+	}
+
+	fx, err := compile(xt, "t")
+	if err != nil {
+		return nil
+	}
+	fy, err := compile(yt, "t")
+	if err != nil {
+		return nil
+	}
+
+	return sampleCurve(func(t float64) (float64, float64, bool) {
+		x, okx := fx(t)
+		y, oky := fy(t)
+		return x, y, okx && oky
+	}, param)
+}
+
+// GeneratePolar samples the polar curve r = expr, a function of θ (or theta),
+// with the steps of param. The curve is broken into segments where expr is
+// undefined.
+func GeneratePolar(expr string, param Interval) []Points {
+	if expr == "" {
+		return nil
+	}
+
+	f, err := compile(expr, "θ", "theta")
+	if err != nil {
+		return nil
+	}
+
+	return sampleCurve(func(theta float64) (float64, float64, bool) {
+		r, ok := f(theta)
+		return r * math.Cos(theta), r * math.Sin(theta), ok
+	}, param)
+}
+
+// sampleCurve samples the curve f with the steps of param. Steps too small to
+// draw are made larger, so there are at most maxSamples points. The curve is
+// broken into segments where f is undefined.
+func sampleCurve(f func(t float64) (x, y float64, ok bool), param Interval) []Points {
+	if !param.Valid() {
+		return nil
+	}
+
+	step := math.Max(param.Step, (param.Max-param.Min)/maxSamples)
+	steps := int(math.Ceil((param.Max - param.Min) / step))
+
+	var segments []Points
+	var segment Points
+	for i := 0; i <= steps; i++ {
+		x, y, ok := f(math.Min(param.Min+float64(i)*step, param.Max))
+		if !ok {
+			if len(segment) > 0 {
+				segments = append(segments, segment)
+			}
+			segment = nil
+			continue
+		}
+
+		segment = append(segment, struct{ X, Y float64 }{x, y})
+	}
+	if len(segment) > 0 {
+		segments = append(segments, segment)
+	}
+
+	return segments
+}
+
+// ParseCurve compiles the parametric curve (xt, yt) into a function of t. The
+// function returns NaN where the curve is undefined.
+func ParseCurve(xt, yt string) (func(t float64) (x, y float64), error) {
+	fx, err := compile(xt, "t")
+	if err != nil {
+		return nil, fmt.Errorf("invalid x(t): %s", err)
+	}
+	fy, err := compile(yt, "t")
+	if err != nil {
+		return nil, fmt.Errorf("invalid y(t): %s", err)
+	}
+
+	return func(t float64) (float64, float64) {
+		x, okx := fx(t)
+		y, oky := fy(t)
+		if !okx || !oky {
+			return math.NaN(), math.NaN()
+		}
+		return x, y
+	}, nil
+}
+
+// ParsePolar compiles the polar curve r = expr into a function of θ. The
+// function returns NaN where expr is undefined.
+func ParsePolar(expr string) (func(theta float64) float64, error) {
+	f, err := compile(expr, "θ", "theta")
+	if err != nil {
+		return nil, fmt.Errorf("invalid r(θ): %s", err)
+	}
+
+	return func(theta float64) float64 {
+		r, _ := f(theta)
+		return r
+	}, nil
+}
+
+// ParseFunction compiles expr into a plotter function of x. The function
+// returns NaN where expr is undefined.
+func ParseFunction(exprStr string) (*plotter.Function, error) {
+	if exprStr == "" {
+		return plotter.NewFunction(func(x float64) float64 { return math.NaN() }), nil
+	}
+
+	f, err := compile(exprStr, "x")
+	if err != nil {
+		return nil, fmt.Errorf("invalid function: %s", err)
+	}
+
+	fn := plotter.NewFunction(func(x float64) float64 {
+		y, _ := f(x)
+		return y
+	})
+	return fn, nil
+}
+
+// FindInverse bisects [Dmin, Dmax] for an x where fn(x) is y.
+func FindInverse(fn *plotter.Function, y float64, Dmin, Dmax, tol float64) (float64, bool) {
+	for i := 0; i < 100; i++ {
+		mid := (Dmin + Dmax) / 2
+		fmid := fn.F(mid)
+		if math.IsNaN(fmid) {
+			return math.NaN(), false
+		}
+		if math.Abs(fmid-y) < tol {
+			return mid, true
+		}
+		if fmid < y {
+			Dmin = mid
+		} else {
+			Dmax = mid
+		}
+	}
+	return math.NaN(), false
+}
+
+// FindIntersection bisects [Dmin, Dmax] for a point where fn1 and fn2 meet.
+func FindIntersection(fn1, fn2 *plotter.Function, Dmin, Dmax, tol float64) (float64, float64, bool) {
+	if fn1 == nil || fn2 == nil {
+		return math.NaN(), math.NaN(), false
+	}
+
+	diffFn := plotter.NewFunction(func(x float64) float64 {
+		return fn1.F(x) - fn2.F(x)
+	})
+
+	for i := 0; i < 100; i++ {
+		mid := (Dmin + Dmax) / 2
+		fmid := diffFn.F(mid)
+		if math.IsNaN(fmid) {
+			return math.NaN(), math.NaN(), false
+		}
+		if math.Abs(fmid) < tol {
+			return mid, fn1.F(mid), true
+		}
+		if fmid < 0 {
+			Dmin = mid
+		} else {
+			Dmax = mid
+		}
+	}
+	return math.NaN(), math.NaN(), false
+
+}
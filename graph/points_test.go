@@ -0,0 +1,275 @@
+package graph
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGeneratePoints(t *testing.T) {
+	functions := map[string]func(x float64) float64{
+		"x":         func(x float64) float64 { return x },
+		"max(x, 2)": func(x float64) float64 { return math.Max(x, 2) },
+		"0xFF * x":  func(x float64) float64 { return 0xFF * x },
+		"x ^ 2 / 3": func(x float64) float64 { return x * x / 3 },
+		"xs = x":    func(x float64) float64 { return x },
+
+		"x < 0 ? -x : x^2": func(x float64) float64 {
+			if x < 0 {
+				return -x
+			}
+			return x * x
+		},
+		"sum(k, 1, 3, x^k / k)":  func(x float64) float64 { return x + x*x/2 + x*x*x/3 },
+		"integrate(2t, t, 0, x)": func(x float64) float64 { return x * x },
+		"if(x > 0 && x < 5, 1, 0)": func(x float64) float64 {
+			if x > 0 && x < 5 {
+				return 1
+			}
+			return 0
+		},
+	}
+
+	for expr, expected := range functions {
+		segments := GeneratePoints(expr, DefaultView)
+		if len(segments) == 0 {
+			t.Errorf("no points generated for '%s'", expr)
+			continue
+		}
+
+		for _, pt := range flatten(segments) {
+			if math.Abs(pt.Y-expected(pt.X)) > 1e-9 {
+				t.Errorf("wrong point for '%s' at x = %g (expected %g, got %g)",
+					expr, pt.X, expected(pt.X), pt.Y)
+				break
+			}
+		}
+	}
+
+	badFunctions := []string{"", "xs", "foo(x)", "x +", "(x"}
+
+	for _, expr := range badFunctions {
+		if segments := GeneratePoints(expr, DefaultView); len(segments) != 0 {
+			t.Errorf("expected no points for bad function '%s'", expr)
+		}
+	}
+}
+
+// flatten joins segments into one list of points.
+func flatten(segments []Points) Points {
+	var pts Points
+	for _, segment := range segments {
+		pts = append(pts, segment...)
+	}
+	return pts
+}
+
+// crosses reports whether segment has points on both sides of x.
+func crosses(segment Points, x float64) bool {
+	return segment[0].X < x && segment[len(segment)-1].X > x
+}
+
+func TestAdaptiveSampling(t *testing.T) {
+	// A line needs no refining, and its zero crossing is kept
+	segments := GeneratePoints("x", DefaultView)
+	if len(segments) != 1 || len(segments[0]) != initialSamples+1 {
+		t.Errorf("wrong sampling of x (expected 1 segment of %d points, got %d segments of %d points)",
+			initialSamples+1, len(segments), len(flatten(segments)))
+	}
+	zero := false
+	for _, pt := range flatten(segments) {
+		zero = zero || math.Abs(pt.X) < 1e-9 && math.Abs(pt.Y) < 1e-9
+	}
+	if !zero {
+		t.Error("x isn't sampled at 0")
+	}
+
+	// 1/x breaks at 0 whether or not 0 is sampled
+	for _, view := range []View{DefaultView, {-10, 9.97, -10, 10}} {
+		segments := GeneratePoints("1/x", view)
+		if len(segments) != 2 {
+			t.Errorf("wrong number of segments of 1/x in %v (expected 2, got %d)", view, len(segments))
+			continue
+		}
+		for _, segment := range segments {
+			if crosses(segment, 0) {
+				t.Errorf("segment of 1/x in %v crosses 0", view)
+			}
+		}
+	}
+
+	// tan(x) breaks at each asymptote between -10 and 10, at ±π/2, ±3π/2 and
+	// ±5π/2, and nowhere else
+	segments = GeneratePoints("tan(x)", DefaultView)
+	if len(segments) != 7 {
+		t.Errorf("wrong number of segments of tan(x) (expected 7, got %d)", len(segments))
+	}
+	for _, segment := range segments {
+		for k := -3; k < 3; k++ {
+			if asymptote := (float64(k) + 0.5) * math.Pi; crosses(segment, asymptote) {
+				t.Errorf("segment of tan(x) crosses %g", asymptote)
+			}
+		}
+		for _, pt := range segment {
+			if math.Abs(pt.Y) < 10 && math.Abs(pt.Y-math.Tan(pt.X)) > 1e-9 {
+				t.Errorf("wrong point of tan(x) at %g (expected %g, got %g)", pt.X, math.Tan(pt.X), pt.Y)
+				break
+			}
+		}
+	}
+
+	// Steep but continuous curves stay in one piece, also where they're
+	// refined the most, like near where ln(x) starts
+	for _, expr := range []string{"exp(x)", "x^3", "100x^3", "1000 sin(x)", "ln(x)"} {
+		segments := GeneratePoints(expr, DefaultView)
+		if len(segments) != 1 || len(segments[0]) < 2 {
+			t.Errorf("%s is broken up (got %d segments of %d points)", expr, len(segments), len(flatten(segments)))
+		}
+	}
+
+	// Smooth curves get fewer points than steep ones, and sin(1/x) is refined
+	// near 0 where it oscillates but stays within the limit
+	smooth := len(flatten(GeneratePoints("sin(x)", DefaultView)))
+	wild := flatten(GeneratePoints("sin(1/x)", View{-1, 1, -2, 2}))
+	if smooth > 2*initialSamples || len(wild) <= 4*smooth || len(wild) > maxSamples {
+		t.Errorf("wrong number of points (sin(x) got %d, sin(1/x) got %d)", smooth, len(wild))
+	}
+	near, far := 0, 0
+	for _, pt := range wild {
+		if math.Abs(pt.Y-math.Sin(1/pt.X)) > 1e-9 {
+			t.Errorf("wrong point of sin(1/x) at %g (expected %g, got %g)", pt.X, math.Sin(1/pt.X), pt.Y)
+			break
+		}
+		if math.Abs(pt.X) < 0.1 {
+			near++
+		} else {
+			far++
+		}
+	}
+	if near <= far {
+		t.Errorf("sin(1/x) isn't refined near 0 (%d points near 0, %d further out)", near, far)
+	}
+}
+
+func TestGenerateCurve(t *testing.T) {
+	segments := GenerateCurve("cos(t)", "sin(t)", DefaultParam)
+	if len(segments) != 1 || len(segments[0]) != 630 {
+		t.Fatalf("wrong sampling of a circle (expected 1 segment of 630 points, got %d segments of %d points)",
+			len(segments), len(flatten(segments)))
+	}
+	for i, pt := range segments[0] {
+		tt := math.Min(float64(i)*DefaultParam.Step, DefaultParam.Max)
+		if math.Abs(pt.X-math.Cos(tt)) > 1e-9 || math.Abs(pt.Y-math.Sin(tt)) > 1e-9 {
+			t.Errorf("wrong point of the circle at t = %g (got %v)", tt, pt)
+			break
+		}
+	}
+	if last := segments[0][len(segments[0])-1]; math.Abs(last.X-1) > 1e-9 || math.Abs(last.Y) > 1e-9 {
+		t.Errorf("circle doesn't end at t = 2π (got %v)", last)
+	}
+
+	// Undefined points break the curve
+	segments = GenerateCurve("sqrt(t ^ 2 - 1)", "t", Interval{-2, 2, 0.5})
+	if len(segments) != 2 || len(segments[0]) != 3 || len(segments[1]) != 3 {
+		t.Errorf("wrong segments of a broken curve (got %v)", segments)
+	}
+
+	// Tiny steps are limited
+	if pts := flatten(GenerateCurve("t", "t", Interval{0, 1, 1e-9})); len(pts) > maxSamples+1 {
+		t.Errorf("too many points for a tiny step (got %d)", len(pts))
+	}
+
+	bad := []struct {
+		x, y  string
+		param Interval
+	}{
+		{"", "t", DefaultParam},
+		{"t", "x", DefaultParam},
+		{"t", "t +", DefaultParam},
+		{"t", "t", Interval{1, 0, 0.1}},
+		{"t", "t", Interval{0, 1, 0}},
+	}
+	for _, b := range bad {
+		if segments := GenerateCurve(b.x, b.y, b.param); len(segments) != 0 {
+			t.Errorf("expected no points for bad curve (%s, %s) over %v", b.x, b.y, b.param)
+		}
+	}
+
+	curve, err := ParseCurve("t ^ 2", "ln(t)")
+	if err != nil {
+		t.Fatalf("unexpected error parsing curve: %s", err)
+	}
+	if x, y := curve(math.E); math.Abs(x-math.E*math.E) > 1e-9 || math.Abs(y-1) > 1e-9 {
+		t.Errorf("wrong point at t = e (got %g, %g)", x, y)
+	}
+	if x, y := curve(-1); !math.IsNaN(x) || !math.IsNaN(y) {
+		t.Errorf("expected NaN where the curve is undefined (got %g, %g)", x, y)
+	}
+	if _, err := ParseCurve("t", "(t"); err == nil {
+		t.Error("expected error parsing bad curve")
+	}
+}
+
+func TestGeneratePolar(t *testing.T) {
+	for _, expr := range []string{"1 + cos(θ)", "1 + cos(theta)"} {
+		segments := GeneratePolar(expr, DefaultParam)
+		if len(segments) != 1 || len(segments[0]) != 630 {
+			t.Errorf("wrong sampling of '%s' (got %d segments of %d points)", expr, len(segments), len(flatten(segments)))
+			continue
+		}
+
+		for i, pt := range segments[0] {
+			theta := math.Min(float64(i)*DefaultParam.Step, DefaultParam.Max)
+			r := 1 + math.Cos(theta)
+			if math.Abs(pt.X-r*math.Cos(theta)) > 1e-9 || math.Abs(pt.Y-r*math.Sin(theta)) > 1e-9 {
+				t.Errorf("wrong point of '%s' at θ = %g (got %v)", expr, theta, pt)
+				break
+			}
+		}
+	}
+
+	// A negative r is on the other side of the origin
+	if pts := flatten(GeneratePolar("-2", Interval{0, 1, 1})); math.Abs(pts[0].X+2) > 1e-9 {
+		t.Errorf("wrong point for a negative r (got %v)", pts[0])
+	}
+
+	// Undefined points break the curve
+	segments := GeneratePolar("sqrt(θ ^ 2 - 1)", Interval{-2, 2, 0.5})
+	if len(segments) != 2 {
+		t.Errorf("wrong segments of a broken polar curve (got %v)", segments)
+	}
+
+	for _, expr := range []string{"", "x", "θ +"} {
+		if segments := GeneratePolar(expr, DefaultParam); len(segments) != 0 {
+			t.Errorf("expected no points for bad polar curve '%s'", expr)
+		}
+	}
+
+	r, err := ParsePolar("2θ")
+	if err != nil {
+		t.Fatalf("unexpected error parsing polar curve: %s", err)
+	}
+	if got := r(math.Pi); math.Abs(got-2*math.Pi) > 1e-9 {
+		t.Errorf("wrong r at θ = π (expected %g, got %g)", 2*math.Pi, got)
+	}
+	if _, err := ParsePolar("(θ"); err == nil {
+		t.Error("expected error parsing bad polar curve")
+	}
+}
+
+func TestParseFunction(t *testing.T) {
+	fn, err := ParseFunction("max(x, 2) + 0x10")
+	if err != nil {
+		t.Fatalf("unexpected error parsing function: %s", err)
+	}
+
+	tests := map[float64]float64{-50: 18, 0: 18, 3.5: 19.5, 123.25: 139.25}
+	for x, expected := range tests {
+		if y := fn.F(x); y != expected {
+			t.Errorf("wrong value at x = %g (expected %g, got %g)", x, expected, y)
+		}
+	}
+
+	if _, err := ParseFunction("max(x,"); err == nil {
+		t.Error("expected error parsing bad function")
+	}
+}
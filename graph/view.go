@@ -0,0 +1,102 @@
+package graph
+
+import (
+	"math"
+)
+
+// View is the part of the plane the graph shows: the domain XMin to XMax and
+// the range YMin to YMax.
+type View struct {
+	XMin, XMax, YMin, YMax float64
+}
+
+// DefaultView is the view of a new graph, -10 to 10 both ways.
+var DefaultView = View{-10, 10, -10, 10}
+
+// minSpan keeps zooming in from collapsing the view onto a single point,
+// where float64 runs out of digits.
+const minSpan = 1e-9
+
+// Valid reports whether v is a finite view with some width and height.
+func (v View) Valid() bool {
+	for _, b := range []float64{v.XMin, v.XMax, v.YMin, v.YMax} {
+		if math.IsNaN(b) || math.IsInf(b, 0) {
+			return false
+		}
+	}
+
+	return v.XMax > v.XMin && v.YMax > v.YMin
+}
+
+// Pan moves the view by dx and dy.
+func (v View) Pan(dx, dy float64) View {
+	return View{v.XMin + dx, v.XMax + dx, v.YMin + dy, v.YMax + dy}
+}
+
+// Zoom scales the view by factor around the point (x, y), which stays at the
+// same place on the graph. Factors below 1 zoom in.
+func (v View) Zoom(x, y, factor float64) View {
+	if (v.XMax-v.XMin)*factor < minSpan || (v.YMax-v.YMin)*factor < minSpan {
+		return v
+	}
+
+	return View{
+		x + (v.XMin-x)*factor,
+		x + (v.XMax-x)*factor,
+		y + (v.YMin-y)*factor,
+		y + (v.YMax-y)*factor,
+	}
+}
+
+// Box returns the view of the box with corners (x0, y0) and (x1, y1), in any
+// order. Boxes too small to zoom into, like from a click, give v unchanged.
+func (v View) Box(x0, y0, x1, y1 float64) View {
+	box := View{math.Min(x0, x1), math.Max(x0, x1), math.Min(y0, y1), math.Max(y0, y1)}
+	if box.XMax-box.XMin < minSpan || box.YMax-box.YMin < minSpan {
+		return v
+	}
+
+	return box
+}
+
+// TickStep returns the distance between major ticks for an axis spanning
+// span, about a tenth of it rounded to 1, 2 or 5 times a power of ten, and the
+// distance between the minor ticks in between.
+func TickStep(span float64) (major, minor float64) {
+	if span <= 0 || math.IsNaN(span) || math.IsInf(span, 0) {
+		return 1, 0.25
+	}
+
+	magnitude := math.Pow(10, math.Floor(math.Log10(span/10)))
+	switch step := span / 10 / magnitude; {
+	case step < 1.5:
+		return magnitude, magnitude / 5
+	case step < 3.5:
+		return 2 * magnitude, magnitude / 2
+	case step < 7.5:
+		return 5 * magnitude, magnitude
+	default:
+		return 10 * magnitude, 2 * magnitude
+	}
+}
+
+// Interval is the range of the parameter of parametric and polar curves, t or
+// θ, sampled every Step from Min to Max.
+type Interval struct {
+	Min, Max, Step float64
+}
+
+// DefaultParam is the range of t and θ of a new graph, one turn around a
+// circle.
+var DefaultParam = Interval{0, 2 * math.Pi, 0.01}
+
+// Valid reports whether i is a finite interval going up in positive steps.
+func (i Interval) Valid() bool {
+	for _, b := range []float64{i.Min, i.Max, i.Step} {
+		if math.IsNaN(b) || math.IsInf(b, 0) {
+			return false
+		}
+	}
+
+	return i.Max > i.Min && i.Step > 0
+}
@@ -0,0 +1,62 @@
+package graph
+
+import (
+	"math"
+	"testing"
+)
+
+func TestView(t *testing.T) {
+	v := DefaultView
+
+	if pan := v.Pan(5, -2); pan != (View{-5, 15, -12, 8}) {
+		t.Errorf("wrong pan (got %v)", pan)
+	}
+
+	// The point zoomed around stays where it is
+	zoom := v.Zoom(5, 0, 0.5)
+	if zoom != (View{-2.5, 7.5, -5, 5}) {
+		t.Errorf("wrong zoom in (got %v)", zoom)
+	}
+	if out := zoom.Zoom(5, 0, 2); out != v {
+		t.Errorf("zooming out doesn't undo zooming in (got %v)", out)
+	}
+	tiny := View{0, 1e-9, 0, 1e-9}
+	if z := tiny.Zoom(0, 0, 0.5); z != tiny {
+		t.Errorf("zoomed in too far (got %v)", z)
+	}
+
+	if box := v.Box(3, 4, -1, 2); box != (View{-1, 3, 2, 4}) {
+		t.Errorf("wrong box (got %v)", box)
+	}
+	if box := v.Box(3, 4, 3, 2); box != v {
+		t.Errorf("zoomed into a line (got %v)", box)
+	}
+
+	for _, bad := range []View{{1, 1, 0, 1}, {0, 1, 2, -2}, {math.NaN(), 1, 0, 1}, {0, math.Inf(1), 0, 1}} {
+		if bad.Valid() {
+			t.Errorf("%v should be invalid", bad)
+		}
+	}
+	if !v.Valid() {
+		t.Errorf("%v should be valid", v)
+	}
+}
+
+func TestTickStep(t *testing.T) {
+	tests := []struct{ span, major, minor float64 }{
+		{20, 2, 0.5},
+		{10, 1, 0.2},
+		{100, 10, 2},
+		{50, 5, 1},
+		{0.01, 0.001, 0.0002},
+		{80, 10, 2},
+		{0, 1, 0.25},
+	}
+
+	for _, test := range tests {
+		major, minor := TickStep(test.span)
+		if math.Abs(major-test.major) > 1e-12 || math.Abs(minor-test.minor) > 1e-12 {
+			t.Errorf("wrong ticks for %g (expected %g, %g, got %g, %g)", test.span, test.major, test.minor, major, minor)
+		}
+	}
+}
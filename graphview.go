@@ -0,0 +1,237 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"opencalcc/graph"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/widget"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+	"gonum.org/v1/plot/vg/vgimg"
+)
+
+// zoomPerScroll is how much one notch of the scroll wheel zooms, Fyne scrolls
+// by 10 per notch.
+const zoomPerScroll = 0.9
+
+// GraphView is a plot drawn in memory at the size of the widget. Dragging
+// pans it, scrolling zooms in and out around the mouse, and dragging with
+// Shift held or with the right mouse button zooms into the box dragged out.
+type GraphView struct {
+	widget.BaseWidget
+
+	view    graph.View
+	plot    func(view graph.View) *plot.Plot
+	minSize fyne.Size
+
+	// OnViewChanged is called when the view is panned or zoomed.
+	OnViewChanged func(view graph.View)
+
+	raster *canvas.Raster
+	box    *canvas.Rectangle
+
+	// dataArea is where the data is drawn on the last rendered image, as a
+	// fraction of the widget from its bottom left corner, to turn positions
+	// on the widget into points on the plane
+	dataArea vg.Rectangle
+
+	boxZoom    bool
+	boxStart   fyne.Position
+	boxCurrent fyne.Position
+	dragging   bool
+}
+
+// NewGraphView returns a graph of view, drawn by makePlot.
+func NewGraphView(view graph.View, makePlot func(view graph.View) *plot.Plot) *GraphView {
+	g := &GraphView{view: view, plot: makePlot, minSize: fyne.NewSize(300, 300)}
+	g.raster = canvas.NewRaster(g.render)
+	g.box = canvas.NewRectangle(color.NRGBA{B: 255, A: 40})
+	g.box.StrokeColor = color.NRGBA{B: 255, A: 200}
+	g.box.StrokeWidth = 1
+	g.box.Hide()
+	g.ExtendBaseWidget(g)
+	return g
+}
+
+// View returns the part of the plane g shows.
+func (g *GraphView) View() graph.View {
+	return g.view
+}
+
+// SetView shows view, and redraws the graph.
+func (g *GraphView) SetView(view graph.View) {
+	if view.Valid() {
+		g.view = view
+	}
+	g.Refresh()
+}
+
+// SetMinSize sets the smallest size g is laid out at.
+func (g *GraphView) SetMinSize(size fyne.Size) {
+	g.minSize = size
+	g.Refresh()
+}
+
+// changeView is SetView for views changed by the mouse.
+func (g *GraphView) changeView(view graph.View) {
+	if view == g.view {
+		return
+	}
+
+	g.SetView(view)
+	if g.OnViewChanged != nil {
+		g.OnViewChanged(g.view)
+	}
+}
+
+// render draws the plot at w by h pixels. Points are sized to the widget
+// like the rest of the interface, so text keeps its size when the window is
+// made larger.
+func (g *GraphView) render(w, h int) image.Image {
+	if w <= 0 || h <= 0 {
+		return image.NewRGBA(image.Rect(0, 0, 1, 1))
+	}
+
+	size := g.Size()
+	dpi := 96
+	if size.Width > 0 {
+		dpi = int(math.Round(96 * float64(w) / float64(size.Width)))
+	}
+	width := vg.Length(w) * vg.Inch / vg.Length(dpi)
+	height := vg.Length(h) * vg.Inch / vg.Length(dpi)
+
+	img := vgimg.NewWith(vgimg.UseWH(width, height), vgimg.UseDPI(dpi))
+	c := draw.New(img)
+	p := g.plot(g.view)
+	p.Draw(c)
+
+	da := p.DataCanvas(c).Rectangle
+	g.dataArea = vg.Rectangle{
+		Min: vg.Point{X: da.Min.X / width, Y: da.Min.Y / height},
+		Max: vg.Point{X: da.Max.X / width, Y: da.Max.Y / height},
+	}
+
+	return img.Image()
+}
+
+// toPlane returns the point on the plane at pos on the widget.
+func (g *GraphView) toPlane(pos fyne.Position) (x, y float64) {
+	size := g.Size()
+	da := g.dataArea
+	if size.Width == 0 || size.Height == 0 || da.Max.X == da.Min.X || da.Max.Y == da.Min.Y {
+		return (g.view.XMin + g.view.XMax) / 2, (g.view.YMin + g.view.YMax) / 2
+	}
+
+	fx := (float64(pos.X/size.Width) - float64(da.Min.X)) / float64(da.Max.X-da.Min.X)
+	fy := (1 - float64(pos.Y/size.Height) - float64(da.Min.Y)) / float64(da.Max.Y-da.Min.Y)
+	return g.view.XMin + fx*(g.view.XMax-g.view.XMin), g.view.YMin + fy*(g.view.YMax-g.view.YMin)
+}
+
+// MouseDown starts a box zoom for the right button or with Shift held.
+func (g *GraphView) MouseDown(ev *desktop.MouseEvent) {
+	g.boxZoom = ev.Button == desktop.MouseButtonSecondary || ev.Modifier&fyne.KeyModifierShift != 0
+	g.boxStart = ev.Position
+	g.boxCurrent = ev.Position
+	g.dragging = ev.Button == desktop.MouseButtonSecondary
+}
+
+// MouseUp ends a box zoom with the right button, which Fyne doesn't report
+// as a drag.
+func (g *GraphView) MouseUp(ev *desktop.MouseEvent) {
+	if ev.Button == desktop.MouseButtonSecondary && g.dragging {
+		g.boxCurrent = ev.Position
+		g.DragEnd()
+	}
+}
+
+// MouseIn is needed for MouseMoved.
+func (g *GraphView) MouseIn(*desktop.MouseEvent) {}
+
+// MouseMoved draws the box of a box zoom with the right button.
+func (g *GraphView) MouseMoved(ev *desktop.MouseEvent) {
+	if g.boxZoom && g.dragging {
+		g.boxCurrent = ev.Position
+		g.showBox()
+	}
+}
+
+// MouseOut is needed for MouseMoved.
+func (g *GraphView) MouseOut() {}
+
+// Dragged pans the graph along with the mouse, or draws the box of a box zoom.
+func (g *GraphView) Dragged(ev *fyne.DragEvent) {
+	if g.boxZoom {
+		g.dragging = true
+		g.boxCurrent = ev.Position
+		g.showBox()
+		return
+	}
+
+	x0, y0 := g.toPlane(ev.Position.Subtract(ev.Dragged))
+	x1, y1 := g.toPlane(ev.Position)
+	g.changeView(g.view.Pan(x0-x1, y0-y1))
+}
+
+// DragEnd zooms into the box of a box zoom.
+func (g *GraphView) DragEnd() {
+	if !g.boxZoom {
+		return
+	}
+
+	g.boxZoom, g.dragging = false, false
+	g.box.Hide()
+	x0, y0 := g.toPlane(g.boxStart)
+	x1, y1 := g.toPlane(g.boxCurrent)
+	g.changeView(g.view.Box(x0, y0, x1, y1))
+}
+
+// showBox moves the box of a box zoom to between where the drag started and
+// the mouse.
+func (g *GraphView) showBox() {
+	min := fyne.NewPos(fyne.Min(g.boxStart.X, g.boxCurrent.X), fyne.Min(g.boxStart.Y, g.boxCurrent.Y))
+	max := fyne.NewPos(fyne.Max(g.boxStart.X, g.boxCurrent.X), fyne.Max(g.boxStart.Y, g.boxCurrent.Y))
+	g.box.Move(min)
+	g.box.Resize(fyne.NewSize(max.X-min.X, max.Y-min.Y))
+	g.box.Show()
+	g.box.Refresh()
+}
+
+// Scrolled zooms in when scrolling up and out when scrolling down, keeping
+// the point under the mouse in place.
+func (g *GraphView) Scrolled(ev *fyne.ScrollEvent) {
+	x, y := g.toPlane(ev.Position)
+	factor := math.Pow(zoomPerScroll, float64(ev.Scrolled.DY)/10)
+	g.changeView(g.view.Zoom(x, y, factor))
+}
+
+func (g *GraphView) CreateRenderer() fyne.WidgetRenderer {
+	return &graphViewRenderer{g}
+}
+
+type graphViewRenderer struct {
+	g *GraphView
+}
+
+func (r *graphViewRenderer) Layout(size fyne.Size) {
+	r.g.raster.Resize(size)
+}
+
+func (r *graphViewRenderer) MinSize() fyne.Size {
+	return r.g.minSize
+}
+
+func (r *graphViewRenderer) Refresh() {
+	r.g.raster.Refresh()
+}
+
+func (r *graphViewRenderer) Objects() []fyne.CanvasObject {
+	return []fyne.CanvasObject{r.g.raster, r.g.box}
+}
+
+func (r *graphViewRenderer) Destroy() {}
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"image/color"
 	"math"
+	"math/big"
+	"opencalcc/fit"
+	"opencalcc/graph"
 	"opencalcc/mathcat"
-	"os"
 	"runtime"
 	"runtime/debug"
 	"strconv"
@@ -16,14 +18,18 @@ import (
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"gonum.org/v1/plot"
 	"gonum.org/v1/plot/plotter"
 	"gonum.org/v1/plot/vg"
 	"gonum.org/v1/plot/vg/draw"
-	"gonum.org/v1/plot/vg/vgimg"
 )
 
+// exactPrecision is the number of bits transcendental functions are computed
+// with in exact mode.
+const exactPrecision = 256
+
 func main() {
 	app := app.New()
 	window := app.NewWindow("OpenCalcc")
@@ -34,21 +40,12 @@ func main() {
 
 	// graph
 	functionLabel := widget.NewRichTextFromMarkdown("## Function Input")
-	function1 := widget.NewEntry()
-	function1.SetPlaceHolder("Enter function...")
-	function1.Resize(fyne.NewSize(400, 40))
-
-	function2 := widget.NewEntry()
-	function2.SetPlaceHolder("Enter function...")
-	function2.Resize(fyne.NewSize(400, 40))
-
-	function3 := widget.NewEntry()
-	function3.SetPlaceHolder("Enter function...")
-	function3.Resize(fyne.NewSize(400, 40))
 
-	function4 := widget.NewEntry()
-	function4.SetPlaceHolder("Enter function...")
-	function4.Resize(fyne.NewSize(400, 40))
+	// the functions on the graph, starting with four empty ones
+	var functions graph.Functions
+	for i := 0; i < 4; i++ {
+		functions.Add()
+	}
 
 	domainMin := widget.NewEntry()
 	domainMin.SetPlaceHolder("Min")
@@ -78,38 +75,113 @@ func main() {
 		rangeMax,
 	)
 
-	file := "opencalccgraph.png"
-	makeGraph(file, function1.Text, function2.Text, function3.Text, function4.Text, domainMin.Text, domainMax.Text, rangeMin.Text, rangeMax.Text)
-	graph := canvas.NewImageFromFile(file)
-	graph.FillMode = canvas.ImageFillOriginal
-	graph.Resize(fyne.NewSize(600, 450))
+	// parametric and polar curves are plotted for t or θ from paramMin to
+	// paramMax, in steps of paramStep
+	paramMin := widget.NewEntry()
+	paramMin.SetPlaceHolder("0")
+	paramMax := widget.NewEntry()
+	paramMax.SetPlaceHolder("2pi")
+	paramStep := widget.NewEntry()
+	paramStep.SetPlaceHolder("0.01")
+
+	paramLabel := widget.NewLabel("t:")
+	paramContainer := container.NewHBox(
+		paramLabel,
+		paramMin,
+		widget.NewLabel("to"),
+		paramMax,
+		widget.NewLabel("step"),
+		paramStep,
+	)
+	paramContainer.Hide()
+
+	mode := graph.Cartesian
 
-	regenGraph := widget.NewButton("Regenerate Graph", func() {
-		makeGraph(file, function1.Text, function2.Text, function3.Text, function4.Text, domainMin.Text, domainMax.Text, rangeMin.Text, rangeMax.Text)
-		graph.File = file
-		graph.Refresh()
+	// data points from the Data tab and the curve fitted through them, drawn
+	// on the graph too
+	var (
+		dataPoints fit.Points
+		dataFit    *fit.Fit
+	)
+
+	graphView := NewGraphView(graph.DefaultView, func(view graph.View) *plot.Plot {
+		param := entryParam(paramMin.Text, paramMax.Text, paramStep.Text)
+		return makePlot(functions, mode, param, view, dataPoints, dataFit)
+	})
+	graphView.SetMinSize(fyne.NewSize(600, 450))
+	graphView.OnViewChanged = func(view graph.View) {
+		domainMin.SetText(boundText(view.XMin))
+		domainMax.SetText(boundText(view.XMax))
+		rangeMin.SetText(boundText(view.YMin))
+		rangeMax.SetText(boundText(view.YMax))
+	}
+
+	redrawGraph := func() {
+		graphView.SetView(entryView(domainMin.Text, domainMax.Text, rangeMin.Text, rangeMax.Text))
+	}
+
+	regenGraph := widget.NewButton("Regenerate Graph", redrawGraph)
+	submitted := func(string) { redrawGraph() }
+	domainMin.OnSubmitted = submitted
+	domainMax.OnSubmitted = submitted
+	rangeMin.OnSubmitted = submitted
+	rangeMax.OnSubmitted = submitted
+	paramMin.OnSubmitted = submitted
+	paramMax.OnSubmitted = submitted
+	paramStep.OnSubmitted = submitted
+
+	// the tools pick functions by name, their options follow the list
+	traceFunctionSelector := widget.NewSelect(nil, nil)
+	intersectionSelect1 := widget.NewSelect(nil, nil)
+	intersectionSelect2 := widget.NewSelect(nil, nil)
+	derivativeSelect := widget.NewSelect(nil, nil)
+	functionSelects := []*widget.Select{traceFunctionSelector, intersectionSelect1, intersectionSelect2, derivativeSelect}
+
+	// selectedFunction parses the function picked in s
+	selectedFunction := func(s *widget.Select) (*plotter.Function, error) {
+		i := s.SelectedIndex()
+		if i < 0 || i >= len(functions) {
+			return nil, fmt.Errorf("no function selected")
+		}
+		return graph.ParseFunction(functions[i].Expr)
+	}
+
+	functionRows := container.NewVBox()
+	var refreshFunctions func()
+	refreshFunctions = func() {
+		functionRows.Objects = nil
+		names := functions.Names()
+		for i, f := range functions {
+			functionRows.Add(functionRow(names[i], f, mode, redrawGraph, func() {
+				functions.Remove(i)
+				refreshFunctions()
+				redrawGraph()
+			}))
+		}
+		functionRows.Refresh()
+
+		for _, s := range functionSelects {
+			selected := s.SelectedIndex()
+			s.Options = names
+			if selected >= len(names) {
+				s.ClearSelected()
+			}
+			s.Refresh()
+		}
+	}
+	refreshFunctions()
+	traceFunctionSelector.SetSelectedIndex(0)
+	derivativeSelect.SetSelectedIndex(0)
+
+	addFunction := widget.NewButton("Add Function", func() {
+		functions.Add()
+		refreshFunctions()
 	})
-	function1.OnSubmitted = func(text string) {
-		makeGraph(file, function1.Text, function2.Text, function3.Text, function4.Text, domainMin.Text, domainMax.Text, rangeMin.Text, rangeMax.Text)
-		graph.File = file
-		graph.Refresh()
-	}
-	function2.OnSubmitted = function1.OnSubmitted
-	function3.OnSubmitted = function1.OnSubmitted
-	function4.OnSubmitted = function1.OnSubmitted
-	domainMin.OnSubmitted = function1.OnSubmitted
-	domainMax.OnSubmitted = function1.OnSubmitted
-	rangeMin.OnSubmitted = function1.OnSubmitted
-	rangeMax.OnSubmitted = function1.OnSubmitted
 
 	// trace funcs
 	traceLabel := widget.NewRichTextFromMarkdown("## Trace Graph")
 
 	traceSelector := widget.NewLabel("Select a function to trace:")
-	selectedfunc := "Func 1"
-	traceFunctionSelector := widget.NewSelect([]string{"Func 1", "Func 2", "Func 3", "Func 4"}, func(selected string) {
-		selectedfunc = selected
-	})
 
 	traceXnum := widget.NewEntry()
 	traceXnum.SetPlaceHolder("X value")
@@ -119,22 +191,17 @@ func main() {
 	traceXresult.Wrapping = fyne.TextWrapWord
 
 	traceX := widget.NewButton("Trace X", func() {
+		if mode != graph.Cartesian {
+			traceCurve(functions, traceFunctionSelector.SelectedIndex(), mode, traceXnum.Text, traceXresult)
+			return
+		}
+
 		x, err := strconv.ParseFloat(traceXnum.Text, 64)
 		if err != nil {
 			traceXresult.SetText("Invalid X value")
 			return
 		}
-		var fn *plotter.Function
-		switch selectedfunc {
-		case "Func 1":
-			fn, err = parseFunction(function1.Text)
-		case "Func 2":
-			fn, err = parseFunction(function2.Text)
-		case "Func 3":
-			fn, err = parseFunction(function3.Text)
-		case "Func 4":
-			fn, err = parseFunction(function4.Text)
-		}
+		fn, err := selectedFunction(traceFunctionSelector)
 		if err != nil {
 			traceXresult.SetText("Invalid function")
 			return
@@ -153,22 +220,17 @@ func main() {
 	traceYresult.Wrapping = fyne.TextWrapWord
 
 	traceY := widget.NewButton("Trace Y", func() {
+		if mode != graph.Cartesian {
+			traceYresult.SetText("Trace Y works on functions of x")
+			return
+		}
+
 		y, err := strconv.ParseFloat(traceYnum.Text, 64)
 		if err != nil {
 			traceYresult.SetText("Invalid Y value")
 			return
 		}
-		var fn *plotter.Function
-		switch selectedfunc {
-		case "Func 1":
-			fn, err = parseFunction(function1.Text)
-		case "Func 2":
-			fn, err = parseFunction(function2.Text)
-		case "Func 3":
-			fn, err = parseFunction(function3.Text)
-		case "Func 4":
-			fn, err = parseFunction(function4.Text)
-		}
+		fn, err := selectedFunction(traceFunctionSelector)
 		if err != nil {
 			traceYresult.SetText("Invalid function")
 			return
@@ -183,7 +245,7 @@ func main() {
 			dMax = 10
 		}
 
-		x, found := findInverse(fn, y, dMin, dMax, 1e-6)
+		x, found := graph.FindInverse(fn, y, dMin, dMax, 1e-6)
 		if !found {
 			traceYresult.SetText("No solution found")
 			return
@@ -201,52 +263,24 @@ func main() {
 		traceY,
 	)
 
-	intersectionSelection := []string{}
-	intersectionSelect1 := widget.NewSelect([]string{"Func 1", "Func 2", "Func 3", "Func 4"}, func(selected string) {
-		intersectionSelection = append(intersectionSelection, selected)
-	})
-	intersectionSelect2 := widget.NewSelect([]string{"Func 1", "Func 2", "Func 3", "Func 4"}, func(selected string) {
-		intersectionSelection = append(intersectionSelection, selected)
-	})
-
 	findIntersectionResult := widget.NewLabel("Intersection result will appear here")
 
 	findIntersection := widget.NewButton("Find Intersection between 2 functions", func() {
-		var fn1, fn2 *plotter.Function
-		var err error
-
-		if len(intersectionSelection) < 2 {
+		if mode != graph.Cartesian {
+			findIntersectionResult.SetText("Intersections work on functions of x")
+			return
+		}
+		if intersectionSelect1.SelectedIndex() < 0 || intersectionSelect2.SelectedIndex() < 0 {
 			findIntersectionResult.SetText("Please select 2 functions")
 			return
 		}
 
-		// Get first function
-		switch intersectionSelection[0] {
-		case "Func 1":
-			fn1, err = parseFunction(function1.Text)
-		case "Func 2":
-			fn1, err = parseFunction(function2.Text)
-		case "Func 3":
-			fn1, err = parseFunction(function3.Text)
-		case "Func 4":
-			fn1, err = parseFunction(function4.Text)
-		}
+		fn1, err := selectedFunction(intersectionSelect1)
 		if err != nil || fn1 == nil {
 			findIntersectionResult.SetText("Invalid first function")
 			return
 		}
-
-		// Get second function
-		switch intersectionSelection[1] {
-		case "Func 1":
-			fn2, err = parseFunction(function1.Text)
-		case "Func 2":
-			fn2, err = parseFunction(function2.Text)
-		case "Func 3":
-			fn2, err = parseFunction(function3.Text)
-		case "Func 4":
-			fn2, err = parseFunction(function4.Text)
-		}
+		fn2, err := selectedFunction(intersectionSelect2)
 		if err != nil || fn2 == nil {
 			findIntersectionResult.SetText("Invalid second function")
 			return
@@ -261,7 +295,7 @@ func main() {
 			dMax = 10
 		}
 
-		x, y, found := findIntersection(fn1, fn2, dMin, dMax, 1e-6)
+		x, y, found := graph.FindIntersection(fn1, fn2, dMin, dMax, 1e-6)
 		if !found {
 			findIntersectionResult.SetText("No intersection found")
 			return
@@ -270,17 +304,79 @@ func main() {
 		findIntersectionResult.SetText(output)
 	})
 
+	// derivative of a function, plotted in the first empty function or a new
+	// one
+	derivativeLabel := widget.NewRichTextFromMarkdown("## Derivative")
+
+	derivativeResult := widget.NewLabel("")
+	derivativeResult.Wrapping = fyne.TextWrapWord
+
+	plotDerivative := widget.NewButton("Plot Derivative", func() {
+		if mode != graph.Cartesian {
+			derivativeResult.SetText("Plot Derivative works on functions of x")
+			return
+		}
+		i := derivativeSelect.SelectedIndex()
+		if i < 0 || i >= len(functions) {
+			derivativeResult.SetText("Select a function")
+			return
+		}
+		if functions[i].Expr == "" {
+			derivativeResult.SetText(derivativeSelect.Selected + " is empty")
+			return
+		}
+
+		derivative, err := mathcat.Diff(functions[i].Expr, "x")
+		if err != nil {
+			derivativeResult.SetText("Error: " + err.Error())
+			return
+		}
+
+		target := functions.FirstEmpty()
+		if target < 0 {
+			functions.Add()
+			target = len(functions) - 1
+		}
+		functions[target].Expr = derivative.String()
+		refreshFunctions()
+		redrawGraph()
+		derivativeResult.SetText(fmt.Sprintf("%s = %s", functions.Names()[target], derivative))
+	})
+
+	modeSelect := widget.NewSelect(graph.ModeNames(), nil)
+	modeSelect.SetSelected(mode.String())
+	modeSelect.OnChanged = func(string) {
+		mode = graph.Modes[modeSelect.SelectedIndex()]
+		switch mode {
+		case graph.Parametric:
+			paramLabel.SetText("t:")
+			paramContainer.Show()
+			traceXnum.SetPlaceHolder("t value")
+			traceX.SetText("Trace t")
+		case graph.Polar:
+			paramLabel.SetText("θ:")
+			paramContainer.Show()
+			traceXnum.SetPlaceHolder("θ value")
+			traceX.SetText("Trace θ")
+		default:
+			paramContainer.Hide()
+			traceXnum.SetPlaceHolder("X value")
+			traceX.SetText("Trace X")
+		}
+		refreshFunctions()
+		redrawGraph()
+	}
+
 	// graph control panel
 	controlPanel := container.NewVBox(
 		functionLabel,
-		function1,
-		function2,
-		function3,
-		function4,
-		container.NewHBox(layout.NewSpacer(), regenGraph, layout.NewSpacer()),
+		container.NewHBox(widget.NewLabel("Mode:"), modeSelect),
+		functionRows,
+		container.NewHBox(layout.NewSpacer(), addFunction, regenGraph, layout.NewSpacer()),
 		widget.NewSeparator(),
 		domainContainer,
 		rangeContainer,
+		paramContainer,
 		widget.NewSeparator(),
 		traceLabel,
 		traceSelector,
@@ -296,10 +392,14 @@ func main() {
 		intersectionSelect2,
 		findIntersection,
 		findIntersectionResult,
+		widget.NewSeparator(),
+		derivativeLabel,
+		container.NewHBox(derivativeSelect, plotDerivative),
+		derivativeResult,
 	)
 
 	graphContent := container.NewHSplit(
-		graph,
+		graphView,
 		container.NewScroll(controlPanel),
 	)
 	graphContent.Offset = 0.7
@@ -313,6 +413,8 @@ func main() {
 
 	output := widget.NewMultiLineEntry()
 	output.SetPlaceHolder("Result will appear here")
+	// monospace so the columns of matrices line up
+	output.TextStyle = fyne.TextStyle{Monospace: true}
 	output.Disable()
 	output.Resize(fyne.NewSize(400, 40))
 
@@ -320,22 +422,74 @@ func main() {
 	historyScroll := container.NewScroll(container.NewVBox())
 	historyScroll.SetMinSize(fyne.NewSize(400, 200))
 
+	// one parser for the whole session so variables and functions carry over
+	calc := mathcat.New()
+
+	// trace(n, x) evaluates graph function n at x, which only makes sense for
+	// functions of x
+	calc.RegisterFunction("trace", 2, func(args []*big.Rat) (*big.Rat, error) {
+		n := mathcat.RationalToInteger(args[0]).Int64()
+		if !args[0].IsInt() || n < 1 || n > int64(len(functions)) {
+			return nil, fmt.Errorf("No graph function %s", args[0].RatString())
+		}
+		if mode != graph.Cartesian {
+			return nil, fmt.Errorf("Graph is in %s mode, trace only works on functions of x", mode)
+		}
+		if functions[n-1].Expr == "" {
+			return nil, fmt.Errorf("Func %d is empty", n)
+		}
+		return mathcat.Exec(functions[n-1].Expr, map[string]*big.Rat{"x": args[1]})
+	})
+
 	calcmode := false
 	var calcmodeSwitch *widget.Check
 	calcmodeSwitch = widget.NewCheck("Exact Mode", func(checked bool) {
 		calcmode = checked
 		if checked {
+			calc.SetPrecision(exactPrecision)
 			calcmodeSwitch.SetText("Float Mode")
 		} else {
+			calc.SetPrecision(0)
 			calcmodeSwitch.SetText("Exact Mode")
 		}
 	})
 
+	angleModes := map[string]mathcat.AngleMode{
+		"Radians":  mathcat.Radians,
+		"Degrees":  mathcat.Degrees,
+		"Gradians": mathcat.Gradians,
+	}
+	angleSelect := widget.NewSelect([]string{"Radians", "Degrees", "Gradians"}, func(selected string) {
+		calc.SetAngleMode(angleModes[selected])
+	})
+	angleSelect.SetSelected("Radians")
+
 	calcButton := widget.NewButton("Calculate", func() {
-		PressedEnter(input, output, historyScroll.Content, calcmode)
+		PressedEnter(calc, input, output, historyScroll.Content, calcmode)
 	})
 	calcButton.Importance = widget.HighImportance
 
+	// d/dx shows the derivative of the input to x
+	derivButton := widget.NewButton("d/dx", func() {
+		if input.Text == "" {
+			return
+		}
+
+		derivative, err := calc.Diff(input.Text, "x")
+		if err != nil {
+			output.SetText("Error: " + err.Error())
+			return
+		}
+		output.SetText(derivative.String())
+
+		var latex string
+		if e, err := mathcat.LaTeX(input.Text); err == nil {
+			latex = fmt.Sprintf(`\frac{d}{dx}\left(%s\right) = %s`, e, derivative.LaTeX())
+		}
+		historyScroll.Content.(*fyne.Container).Add(
+			historyEntry(fmt.Sprintf("d/dx %s = %s", input.Text, derivative), latex))
+	})
+
 	clearButton := widget.NewButton("Clear Input", func() {
 		input.SetText("")
 		output.SetText("")
@@ -348,6 +502,7 @@ func main() {
 
 	buttonContainer := container.NewHBox(
 		calcButton,
+		derivButton,
 		clearButton,
 		clearHistory,
 	)
@@ -358,23 +513,115 @@ func main() {
 		input,
 		output,
 		container.NewHBox(layout.NewSpacer(), buttonContainer, layout.NewSpacer()),
-		calcmodeSwitch,
+		container.NewHBox(calcmodeSwitch, angleSelect),
 		widget.NewSeparator(),
 		historyTitle,
 		historyScroll,
 	)
 
+	// data
+	dataTitle := widget.NewRichTextFromMarkdown("## Data Points")
+
+	dataInput := widget.NewMultiLineEntry()
+	dataInput.SetPlaceHolder("One x, y pair per line, like\n1, 2.1\n2, 3.9\n3, 6.2")
+	dataInput.SetMinRowsVisible(12)
+
+	degreeEntry := widget.NewEntry()
+	degreeEntry.SetText("2")
+	degreeEntry.Disable()
+
+	modelSelect := widget.NewSelect([]string{"Linear", "Polynomial", "Exponential", "Logarithmic"}, func(selected string) {
+		// only polynomials have a degree
+		if selected == "Polynomial" {
+			degreeEntry.Enable()
+		} else {
+			degreeEntry.Disable()
+		}
+	})
+	modelSelect.SetSelected("Linear")
+
+	fitResult := widget.NewLabel("")
+	fitResult.Wrapping = fyne.TextWrapWord
+
+	dataView := NewGraphView(graph.DefaultView, func(view graph.View) *plot.Plot {
+		return makePlot(nil, graph.Cartesian, graph.DefaultParam, view, dataPoints, dataFit)
+	})
+	dataView.SetMinSize(fyne.NewSize(450, 450))
+
+	// moves the data plot around the points, and redraws the graph tab
+	refreshData := func() {
+		dataView.SetView(dataBounds(dataPoints))
+		redrawGraph()
+	}
+
+	fitButton := widget.NewButton("Fit", func() {
+		points, err := fit.ParsePoints(dataInput.Text)
+		if err != nil {
+			fitResult.SetText("Error: " + err.Error())
+			return
+		}
+
+		var curve *fit.Fit
+		switch modelSelect.Selected {
+		case "Linear":
+			curve, err = fit.Line(points)
+		case "Polynomial":
+			degree, convErr := strconv.Atoi(degreeEntry.Text)
+			if convErr != nil {
+				fitResult.SetText("Invalid degree")
+				return
+			}
+			curve, err = fit.Poly(points, degree)
+		case "Exponential":
+			curve, err = fit.Exp(points)
+		case "Logarithmic":
+			curve, err = fit.Log(points)
+		}
+		if err != nil {
+			fitResult.SetText("Error: " + err.Error())
+			return
+		}
+
+		dataPoints, dataFit = points, curve
+		fitResult.SetText(fitText(curve))
+		refreshData()
+	})
+	fitButton.Importance = widget.HighImportance
+
+	clearData := widget.NewButton("Clear Data", func() {
+		dataInput.SetText("")
+		fitResult.SetText("")
+		dataPoints, dataFit = nil, nil
+		refreshData()
+	})
+
+	dataPanel := container.NewVBox(
+		dataTitle,
+		dataInput,
+		container.NewHBox(widget.NewLabel("Model:"), modelSelect, widget.NewLabel("Degree:"), degreeEntry),
+		container.NewHBox(layout.NewSpacer(), fitButton, clearData, layout.NewSpacer()),
+		widget.NewSeparator(),
+		fitResult,
+	)
+
+	dataContent := container.NewHSplit(
+		dataView,
+		container.NewScroll(dataPanel),
+	)
+	dataContent.Offset = 0.6
+
 	// tabs
 	tabs := container.NewAppTabs(
 		container.NewTabItem("Calculator", calcContent),
 		container.NewTabItem("Graph", graphContent),
+		container.NewTabItem("Data", dataContent),
 	)
 	tabs.SetTabLocation(container.TabLocationTop)
 
 	window.SetContent(tabs)
 
 	input.OnSubmitted = func(text string) {
-		PressedEnter(input, output, historyScroll.Content, calcmode)
+		PressedEnter(calc, input, output, historyScroll.Content, calcmode)
 	}
 
 	window.Resize(fyne.NewSize(1200, 800))
@@ -386,279 +633,484 @@ func onLeave() {
 	fmt.Println("Exited")
 }
 
-func PressedEnter(expression fyne.CanvasObject, output fyne.CanvasObject, history fyne.CanvasObject, calcmode bool) {
+func PressedEnter(calc *mathcat.Parser, expression fyne.CanvasObject, output fyne.CanvasObject, history fyne.CanvasObject, calcmode bool) {
 	if expression.(*widget.Entry).Text == "" {
 		output.(*widget.Entry).SetText("")
 		return
 	}
-	result, err := mathcat.Eval(expression.(*widget.Entry).Text)
+	result, err := calc.RunComplex(expression.(*widget.Entry).Text)
 	if err != nil {
 		output.(*widget.Entry).SetText("Error: " + err.Error())
+	} else if result == nil {
+		// function definitions don't have a result
+		output.(*widget.Entry).SetText("Function defined")
+		history.(*fyne.Container).Add(historyEntry(
+			historyText(expression.(*widget.Entry).Text, true),
+			historyLaTeX(expression.(*widget.Entry).Text, "", true)))
+		return
+	}
+
+	if err != nil {
+		history.(*fyne.Container).Add(historyEntry(
+			fmt.Sprintf("%s = %s", historyText(expression.(*widget.Entry).Text, true), output.(*widget.Entry).Text),
+			""))
+		return
+	}
+
+	format := func(x *big.Rat) string {
+		return exactText(x, calc.Precision())
+	}
+	if !calcmode {
+		format = func(x *big.Rat) string {
+			floatResult, _ := x.Float64()
+			return fmt.Sprintf("%.6g", floatResult)
+		}
+	}
+
+	// complex results are shown as a + bi, matrices as a grid
+	resultText := result.Text(format)
+	if result.IsMatrix() {
+		output.(*widget.Entry).SetText(matrixText(result.Matrix, format))
 	} else {
-		var outputText string
-		if !calcmode {
-			floatResult, _ := result.Float64()
-			outputText = fmt.Sprintf("%.6g", floatResult)
-		} else {
-			outputText = result.String()
+		output.(*widget.Entry).SetText(resultText)
+	}
+
+	// the simplifier takes products to commute, which matrices don't
+	simplify := !result.IsMatrix()
+	history.(*fyne.Container).Add(historyEntry(
+		fmt.Sprintf("%s = %s", historyText(expression.(*widget.Entry).Text, simplify), resultText),
+		historyLaTeX(expression.(*widget.Entry).Text, resultText, simplify)))
+}
+
+// matrixText shows m as a grid with one row per line and aligned columns.
+func matrixText(m mathcat.Matrix, format func(*big.Rat) string) string {
+	cells := make([][]string, len(m))
+	var widths []int
+	for i, row := range m {
+		cells[i] = make([]string, len(row))
+		for j, x := range row {
+			cells[i][j] = x.Text(format)
+			if j == len(widths) {
+				widths = append(widths, 0)
+			}
+			if n := len([]rune(cells[i][j])); n > widths[j] {
+				widths[j] = n
+			}
+		}
+	}
+
+	lines := make([]string, len(cells))
+	for i, row := range cells {
+		for j, cell := range row {
+			row[j] = strings.Repeat(" ", widths[j]-len([]rune(cell))) + cell
 		}
-		output.(*widget.Entry).SetText(outputText)
+		lines[i] = "[ " + strings.Join(row, "  ") + " ]"
 	}
 
-	history.(*fyne.Container).Add(widget.NewLabel(fmt.Sprintf("%s = %s", expression.(*widget.Entry).Text, output.(*widget.Entry).Text)))
+	return strings.Join(lines, "\n")
 }
 
-func makeGraph(filename string, function1 string, function2 string, function3 string, function4 string, domainMin string, domainMax string, rangeMin string, rangeMax string) {
-	p := plot.New()
+// historyEntry is a line in the history, with a button to copy it as LaTeX
+// unless latex is empty.
+func historyEntry(text string, latex string) fyne.CanvasObject {
+	label := widget.NewLabel(text)
+	if latex == "" {
+		return label
+	}
 
-	p.Title.Text = "Functions"
-	p.Title.TextStyle.Font.Size = vg.Points(24)
-	p.X.Label.Text = "Y"
-	p.Y.Label.Text = "X"
-	p.X.Label.TextStyle.Font.Size = vg.Points(20)
-	p.Y.Label.TextStyle.Font.Size = vg.Points(20)
-	p.X.Tick.Label.Font.Size = vg.Points(16)
-	p.Y.Tick.Label.Font.Size = vg.Points(16)
-	p.Legend.TextStyle.Font.Size = vg.Points(16)
+	copyButton := widget.NewButton("Copy LaTeX", func() {
+		fyne.CurrentApp().Clipboard().SetContent(latex)
+	})
 
-	p.X.Width = vg.Points(2)
-	p.Y.Width = vg.Points(2)
+	return container.NewBorder(nil, nil, nil, copyButton, label)
+}
 
-	importedDomainMin, err := strconv.ParseFloat(domainMin, 64)
+// historyExpr normalises an expression for the history. Expressions with
+// variables are simplified if simplify is set, so 2x + x shows as 3 * x, but
+// constant ones are only reprinted, as simplifying 1 + 2 would just give the
+// result. That's decided from the syntax, so nothing is evaluated again.
+func historyExpr(expr string, simplify bool) (*mathcat.Expr, error) {
+	e, err := mathcat.Compile(expr)
 	if err != nil {
-		importedDomainMin = -10
+		return nil, err
 	}
-	importedDomainMax, err := strconv.ParseFloat(domainMax, 64)
-	if err != nil {
-		importedDomainMax = 10
+
+	if !simplify || e.IsConstant() {
+		return e, nil
 	}
-	importedRangeMin, err := strconv.ParseFloat(rangeMin, 64)
+
+	return e.Simplify(), nil
+}
+
+// historyText is the normalised expression as text, or expr itself if it
+// doesn't parse.
+func historyText(expr string, simplify bool) string {
+	e, err := historyExpr(expr, simplify)
 	if err != nil {
-		importedRangeMin = -10
+		return expr
 	}
-	importedRangeMax, err := strconv.ParseFloat(rangeMax, 64)
+
+	return e.String()
+}
+
+// historyLaTeX is the normalised expression followed by its result as LaTeX,
+// or an empty string if either can't be rendered.
+func historyLaTeX(expr string, result string, simplify bool) string {
+	e, err := historyExpr(expr, simplify)
 	if err != nil {
-		importedRangeMax = 10
+		return ""
 	}
-
-	majorInterval := 1.0
-	if math.Abs(importedDomainMax-importedDomainMin) >= 15 {
-		majorInterval = 5.0
-	} else if math.Abs(importedDomainMax-importedDomainMin) >= 50 {
-		majorInterval = 10.0
-	} else if math.Abs(importedDomainMax-importedDomainMin) >= 100 {
-		majorInterval = 20.0
+	if result == "" {
+		return e.LaTeX()
 	}
 
-	p.X.Tick.Marker = SubTicker{Major: majorInterval, Minor: majorInterval / 4}
-	p.Y.Tick.Marker = SubTicker{Major: majorInterval, Minor: majorInterval / 4}
+	// Float mode shows large numbers like 1e+06, which don't parse
+	res, err := mathcat.LaTeX(strings.Replace(result, "e+", "e", 1))
+	if err != nil {
+		return ""
+	}
 
-	// grids
-	mainGrid := plotter.NewGrid()
-	mainGrid.Vertical.Width = vg.Points(2)
-	mainGrid.Horizontal.Width = vg.Points(2)
-	mainGrid.Vertical.Color = color.RGBA{A: 180}
-	mainGrid.Horizontal.Color = color.RGBA{A: 180}
+	return e.LaTeX() + " = " + res
+}
 
-	// small grid
-	fineGrid := plotter.NewGrid()
-	fineGrid.Vertical.Width = vg.Points(0.5)
-	fineGrid.Horizontal.Width = vg.Points(0.5)
-	fineGrid.Vertical.Color = color.RGBA{A: 40}
-	fineGrid.Horizontal.Color = color.RGBA{A: 40}
+// exactText shows result as a fraction, unless it came out of a transcendental
+// function and the fraction would be unreadable, then as a decimal with all
+// the digits the precision gives.
+func exactText(result *big.Rat, prec uint) string {
+	if result.IsInt() || result.Denom().BitLen() <= 64 {
+		return result.RatString()
+	}
 
-	p.Add(fineGrid)
-	p.Add(mainGrid)
+	digits := int(float64(prec) * math.Log10(2))
+	return new(big.Float).SetPrec(prec).SetRat(result).Text('g', digits)
+}
 
-	// Draw axes
-	if importedDomainMin <= 0 && importedDomainMax >= 0 {
-		yAxis := plotter.NewFunction(func(x float64) float64 { return 0 })
-		yAxis.Width = vg.Points(4)
-		yAxis.Color = color.RGBA{A: 255}
-		p.Add(yAxis)
+// functionRow is the row of a graph function on the Graph tab: its entry, the
+// x(t) and y(t) entries in parametric mode or the r(θ) entry in polar mode,
+// its colour and line style, whether it's shown and a button to delete it.
+// changed is called when the function should be redrawn, remove when it's
+// deleted.
+func functionRow(name string, f *graph.Function, mode graph.Mode, changed func(), remove func()) fyne.CanvasObject {
+	newEntry := func(placeholder string, expr *string) *widget.Entry {
+		entry := widget.NewEntry()
+		entry.SetPlaceHolder(placeholder)
+		entry.SetText(*expr)
+		entry.OnChanged = func(text string) { *expr = text }
+		entry.OnSubmitted = func(string) { changed() }
+		return entry
 	}
-	if importedRangeMin <= 0 && importedRangeMax >= 0 {
-		xAxis := plotter.NewFunction(func(x float64) float64 { return 0 })
-		xAxis.Width = vg.Points(4)
-		xAxis.Color = color.RGBA{A: 255}
-		p.Add(xAxis)
+
+	var entry fyne.CanvasObject
+	switch mode {
+	case graph.Parametric:
+		entry = container.NewGridWithColumns(2, newEntry("x(t)", &f.X), newEntry("y(t)", &f.Y))
+	case graph.Polar:
+		entry = newEntry("r(θ)", &f.R)
+	default:
+		entry = newEntry(name, &f.Expr)
 	}
 
-	funcs := []string{function1, function2, function3, function4}
-	colors := []color.Color{
-		color.RGBA{R: 255, A: 255},
-		color.RGBA{G: 255, A: 255},
-		color.RGBA{B: 255, A: 255},
-		color.RGBA{B: 255, G: 255, A: 255},
+	swatch := canvas.NewRectangle(f.Color.Value)
+	swatch.SetMinSize(fyne.NewSize(16, 16))
+
+	colorSelect := widget.NewSelect(graph.ColorNames(), nil)
+	colorSelect.SetSelected(f.Color.Name)
+	colorSelect.OnChanged = func(string) {
+		f.Color = graph.Colors[colorSelect.SelectedIndex()]
+		swatch.FillColor = f.Color.Value
+		swatch.Refresh()
+		changed()
 	}
 
-	for i, f := range funcs {
-		if f == "" {
-			continue
-		}
-		pts := generatePoints(f, importedDomainMin, importedDomainMax)
-		line, err := plotter.NewLine(pts)
-		if err != nil {
-			continue
-		}
-		line.Color = colors[i]
-		p.Add(line)
-		p.Legend.Add(f, line)
+	styleSelect := widget.NewSelect(graph.LineStyleNames(), nil)
+	styleSelect.SetSelected(f.Style.String())
+	styleSelect.OnChanged = func(string) {
+		f.Style = graph.LineStyles[styleSelect.SelectedIndex()]
+		changed()
 	}
 
-	p.X.Min = importedDomainMin
-	p.X.Max = importedDomainMax
-	p.Y.Min = importedRangeMin
-	p.Y.Max = importedRangeMax
-	p.Legend.ThumbnailWidth = 0.5 * vg.Inch
+	show := widget.NewCheck("Show", nil)
+	show.SetChecked(f.Visible)
+	show.OnChanged = func(visible bool) {
+		f.Visible = visible
+		changed()
+	}
 
-	w := 8 * vg.Inch
-	h := 8 * vg.Inch
+	deleteButton := widget.NewButtonWithIcon("", theme.DeleteIcon(), remove)
 
-	img := vgimg.New(w, h)
-	c := draw.New(img)
-	p.Draw(c)
+	return container.NewBorder(nil, nil, container.NewHBox(widget.NewLabel(name+":"), swatch), container.NewHBox(colorSelect, styleSelect, show, deleteButton), entry)
+}
 
-	f, err := os.Create(filename)
-	if err != nil {
-		panic(err)
+// fitText describes a fitted curve with its coefficients and R².
+func fitText(curve *fit.Fit) string {
+	names := "abcdefghij"
+	var coeffs []string
+	for i, c := range curve.Coeffs {
+		if i < len(names) {
+			coeffs = append(coeffs, fmt.Sprintf("%c = %.6g", names[i], c))
+		}
 	}
-	defer f.Close()
 
-	png := vgimg.PngCanvas{Canvas: img}
-	if _, err := png.WriteTo(f); err != nil {
-		panic(err)
+	var model string
+	switch curve.Model {
+	case fit.Linear:
+		model = "y = a + bx"
+	case fit.Polynomial:
+		model = "y = a + bx + cx²"
+		if len(curve.Coeffs) > 3 {
+			model += " + …"
+		}
+	case fit.Exponential:
+		model = "y = a·e^(bx)"
+	case fit.Logarithmic:
+		model = "y = a + b·ln(x)"
 	}
-}
 
-type Points []struct{ X, Y float64 }
+	return fmt.Sprintf("%s\n%s\n\ny = %s\nR² = %.6g", model, strings.Join(coeffs, "\n"), curve, curve.R2)
+}
 
-func (p Points) Len() int                    { return len(p) }
-func (p Points) XY(i int) (float64, float64) { return p[i].X, p[i].Y }
+// dataBounds returns the view that shows all points with some room around
+// them, or the default view if there are none.
+func dataBounds(points fit.Points) graph.View {
+	if len(points) == 0 {
+		return graph.DefaultView
+	}
 
-func generatePoints(expr string, xmin, xmax float64) Points {
-	if expr == "" {
-		return nil
+	xmin, xmax := points[0].X, points[0].X
+	ymin, ymax := points[0].Y, points[0].Y
+	for _, p := range points[1:] {
+		xmin, xmax = math.Min(xmin, p.X), math.Max(xmax, p.X)
+		ymin, ymax = math.Min(ymin, p.Y), math.Max(ymax, p.Y)
 	}
 
-	initialCapacity := 1000
-	points := make(Points, 0, initialCapacity)
-	steps := 7000
-	dx := (xmax - xmin) / float64(steps)
-	lastY := math.NaN()
+	pad := func(min, max float64) (float64, float64) {
+		margin := (max - min) / 10
+		if margin == 0 {
+			margin = 1
+		}
+		return min - margin, max + margin
+	}
 
-	growthFactor := 1.5
-	maxValue := 1e6
+	view := graph.View{}
+	view.XMin, view.XMax = pad(xmin, xmax)
+	view.YMin, view.YMax = pad(ymin, ymax)
+	return view
+}
 
-	for i := 0; i <= steps; i++ {
-		x := xmin + float64(i)*dx
-		res, err := mathcat.Eval(strings.ReplaceAll(expr, "x", fmt.Sprintf("(%g)", x)))
+// entryView is the view entered in the domain and range entries. Bounds that
+// are left empty or aren't numbers are those of the default view.
+func entryView(domainMin, domainMax, rangeMin, rangeMax string) graph.View {
+	bound := func(text string, def float64) float64 {
+		x, err := strconv.ParseFloat(text, 64)
 		if err != nil {
-			lastY = math.NaN()
-			continue
-		}
-		if res == nil {
-			lastY = math.NaN()
-			continue
-		}
-		y, ok := res.Float64()
-		if !ok || math.IsInf(y, 0) || math.IsNaN(y) || math.Abs(y) > maxValue {
-			lastY = math.NaN()
-			continue
+			return def
 		}
+		return x
+	}
 
-		if !math.IsNaN(lastY) {
-			delta := math.Abs(y - lastY)
-			threshold := math.Max(math.Abs(y), math.Abs(lastY)) * 0.5
-			if delta > threshold {
-				lastY = math.NaN()
-				continue
-			}
-		}
+	def := graph.DefaultView
+	return graph.View{
+		XMin: bound(domainMin, def.XMin),
+		XMax: bound(domainMax, def.XMax),
+		YMin: bound(rangeMin, def.YMin),
+		YMax: bound(rangeMax, def.YMax),
+	}
+}
 
-		// dynamically change amount of points
-		if len(points) == cap(points) {
-			newCap := int(float64(cap(points)) * growthFactor)
-			newPoints := make(Points, len(points), newCap)
-			copy(newPoints, points)
-			points = newPoints
+// entryParam is the range of t or θ entered in the parameter entries, which
+// can be expressions like 2pi. Entries that are left empty or don't evaluate
+// are those of the default range.
+func entryParam(min, max, step string) graph.Interval {
+	value := func(text string, def float64) float64 {
+		x, err := mathcat.Exec(text, nil)
+		if err != nil || strings.TrimSpace(text) == "" {
+			return def
 		}
+		f, _ := x.Float64()
+		return f
+	}
 
-		points = append(points, struct{ X, Y float64 }{x, y})
-		lastY = y
+	def := graph.DefaultParam
+	return graph.Interval{
+		Min:  value(min, def.Min),
+		Max:  value(max, def.Max),
+		Step: value(step, def.Step),
 	}
-	return points
 }
 
-func parseFunction(exprStr string) (*plotter.Function, error) {
-	if exprStr == "" {
-		return plotter.NewFunction(func(x float64) float64 { return math.NaN() }), nil
+// traceCurve shows the point of the parametric or polar function i at t, or θ
+// in polar mode, in result.
+func traceCurve(functions graph.Functions, i int, mode graph.Mode, t string, result *widget.Label) {
+	name := "t"
+	if mode == graph.Polar {
+		name = "θ"
+	}
+
+	if i < 0 || i >= len(functions) || functions[i].Empty(mode) {
+		result.SetText("Invalid function")
+		return
 	}
-	pts := generatePoints(exprStr, -10, 10)
-	if len(pts) == 0 {
-		return nil, fmt.Errorf("invalid function")
+	tval, err := mathcat.Exec(t, nil)
+	if err != nil || strings.TrimSpace(t) == "" {
+		result.SetText("Invalid " + name + " value")
+		return
 	}
+	tf, _ := tval.Float64()
 
-	fn := plotter.NewFunction(func(x float64) float64 {
-		for i := 0; i < len(pts)-1; i++ {
-			if x >= pts[i].X && x <= pts[i+1].X {
-				dx := pts[i+1].X - pts[i].X
-				dy := pts[i+1].Y - pts[i].Y
-				return pts[i].Y + (x-pts[i].X)*dy/dx
-			}
+	if mode == graph.Polar {
+		r, err := graph.ParsePolar(functions[i].R)
+		if err != nil {
+			result.SetText("Invalid function")
+			return
 		}
-		return math.NaN()
-	})
-	return fn, nil
-}
 
-func findInverse(fn *plotter.Function, y float64, Dmin, Dmax, tol float64) (float64, bool) {
-	for i := 0; i < 100; i++ {
-		mid := (Dmin + Dmax) / 2
-		fmid := fn.F(mid)
-		if math.IsNaN(fmid) {
-			return math.NaN(), false
-		}
-		if math.Abs(fmid-y) < tol {
-			return mid, true
-		}
-		if fmid < y {
-			Dmin = mid
-		} else {
-			Dmax = mid
-		}
+		rf := r(tf)
+		result.SetText(fmt.Sprintf("θ = %s: r = %.6g, (x, y) = (%.6g, %.6g)", t, rf, rf*math.Cos(tf), rf*math.Sin(tf)))
+		return
+	}
+
+	curve, err := graph.ParseCurve(functions[i].X, functions[i].Y)
+	if err != nil {
+		result.SetText("Invalid function")
+		return
 	}
-	return math.NaN(), false
+
+	x, y := curve(tf)
+	result.SetText(fmt.Sprintf("t = %s: (x, y) = (%.6g, %.6g)", t, x, y))
+}
+
+// boundText writes a bound of the view for the domain and range entries.
+func boundText(x float64) string {
+	return strconv.FormatFloat(x, 'g', -1, 64)
 }
 
-func findIntersection(fn1, fn2 *plotter.Function, Dmin, Dmax, tol float64) (float64, float64, bool) {
-	if fn1 == nil || fn2 == nil {
-		return math.NaN(), math.NaN(), false
+// makePlot plots functions in mode, data points and the curve fitted through
+// them in view. Parametric and polar functions are plotted for t or θ in
+// param, and polar ones on a polar grid.
+func makePlot(functions graph.Functions, mode graph.Mode, param graph.Interval, view graph.View, data fit.Points, curve *fit.Fit) *plot.Plot {
+	p := plot.New()
+
+	p.Title.Text = "Functions"
+	p.Title.TextStyle.Font.Size = vg.Points(24)
+	p.X.Label.Text = "Y"
+	p.Y.Label.Text = "X"
+	p.X.Label.TextStyle.Font.Size = vg.Points(20)
+	p.Y.Label.TextStyle.Font.Size = vg.Points(20)
+	p.X.Tick.Label.Font.Size = vg.Points(16)
+	p.Y.Tick.Label.Font.Size = vg.Points(16)
+	p.Legend.TextStyle.Font.Size = vg.Points(16)
+
+	p.X.Width = vg.Points(2)
+	p.Y.Width = vg.Points(2)
+
+	xMajor, xMinor := graph.TickStep(view.XMax - view.XMin)
+	yMajor, yMinor := graph.TickStep(view.YMax - view.YMin)
+	p.X.Tick.Marker = SubTicker{Major: xMajor, Minor: xMinor}
+	p.Y.Tick.Marker = SubTicker{Major: yMajor, Minor: yMinor}
+
+	// grids
+	mainGrid := plotter.NewGrid()
+	mainGrid.Vertical.Width = vg.Points(2)
+	mainGrid.Horizontal.Width = vg.Points(2)
+	mainGrid.Vertical.Color = color.RGBA{A: 180}
+	mainGrid.Horizontal.Color = color.RGBA{A: 180}
+
+	// small grid
+	fineGrid := plotter.NewGrid()
+	fineGrid.Vertical.Width = vg.Points(0.5)
+	fineGrid.Horizontal.Width = vg.Points(0.5)
+	fineGrid.Vertical.Color = color.RGBA{A: 40}
+	fineGrid.Horizontal.Color = color.RGBA{A: 40}
+
+	if mode == graph.Polar {
+		labels := p.X.Tick.Label
+		labels.XAlign = draw.XCenter
+		labels.YAlign = draw.YCenter
+		circles := mainGrid.Vertical
+		circles.Width = vg.Points(1)
+		circles.Color = color.RGBA{A: 100}
+		p.Add(PolarGrid{
+			Step:    math.Max(xMajor, yMajor),
+			Circles: circles,
+			Rays:    fineGrid.Vertical,
+			Labels:  labels,
+		})
+	} else {
+		p.Add(fineGrid)
+		p.Add(mainGrid)
 	}
 
-	diffFn := plotter.NewFunction(func(x float64) float64 {
-		return fn1.F(x) - fn2.F(x)
-	})
+	// Draw axes
+	if view.XMin <= 0 && view.XMax >= 0 {
+		yAxis, err := plotter.NewLine(plotter.XYs{{X: 0, Y: view.YMin}, {X: 0, Y: view.YMax}})
+		if err == nil {
+			yAxis.Width = vg.Points(4)
+			yAxis.Color = color.RGBA{A: 255}
+			p.Add(yAxis)
+		}
+	}
+	if view.YMin <= 0 && view.YMax >= 0 {
+		xAxis := plotter.NewFunction(func(x float64) float64 { return 0 })
+		xAxis.Width = vg.Points(4)
+		xAxis.Color = color.RGBA{A: 255}
+		p.Add(xAxis)
+	}
 
-	for i := 0; i < 100; i++ {
-		mid := (Dmin + Dmax) / 2
-		fmid := diffFn.F(mid)
-		if math.IsNaN(fmid) {
-			return math.NaN(), math.NaN(), false
+	for _, f := range functions.Plotted(mode) {
+		var segments []graph.Points
+		switch mode {
+		case graph.Parametric:
+			segments = graph.GenerateCurve(f.X, f.Y, param)
+		case graph.Polar:
+			segments = graph.GeneratePolar(f.R, param)
+		default:
+			segments = graph.GeneratePoints(f.Expr, view)
 		}
-		if math.Abs(fmid) < tol {
-			return mid, fn1.F(mid), true
+
+		for i, segment := range segments {
+			line, err := plotter.NewLine(segment)
+			if err != nil {
+				continue
+			}
+			line.Color = f.Color.Value
+			line.Dashes = f.Style.Dashes()
+			p.Add(line)
+			if i == 0 {
+				p.Legend.Add(f.Label(mode), line)
+			}
 		}
-		if fmid < 0 {
-			Dmin = mid
-		} else {
-			Dmax = mid
+	}
+
+	// data points from the Data tab and the curve fitted through them
+	if curve != nil {
+		line := plotter.NewFunction(curve.Eval)
+		line.Color = color.RGBA{R: 200, B: 200, A: 255}
+		line.Width = vg.Points(2)
+		line.Samples = 500
+		p.Add(line)
+		p.Legend.Add(curve.String(), line)
+	}
+	if len(data) > 0 {
+		scatter, err := plotter.NewScatter(data)
+		if err == nil {
+			scatter.GlyphStyle.Shape = draw.CircleGlyph{}
+			scatter.GlyphStyle.Radius = vg.Points(4)
+			p.Add(scatter)
+			p.Legend.Add("data", scatter)
 		}
 	}
-	return math.NaN(), math.NaN(), false
 
+	p.X.Min = view.XMin
+	p.X.Max = view.XMax
+	p.Y.Min = view.YMin
+	p.Y.Max = view.YMax
+	p.Legend.ThumbnailWidth = 0.5 * vg.Inch
+
+	return p
 }
 
+// SubTicker puts labelled ticks every Major and unlabelled ones every Minor
+// in between.
 type SubTicker struct {
 	Major, Minor float64
 }
@@ -666,17 +1118,109 @@ type SubTicker struct {
 func (t SubTicker) Ticks(min, max float64) []plot.Tick {
 	var ticks []plot.Tick
 
-	majorStart := math.Ceil(min/t.Major) * t.Major
-	for x := majorStart; x <= max; x += t.Major {
-		ticks = append(ticks, plot.Tick{Value: x, Label: fmt.Sprintf("%.0f", x)})
+	// Enough decimals to tell the major ticks apart, and ticks are counted
+	// rather than added up so 0.1 steps don't drift to 0.30000000000000004
+	decimals := int(math.Max(0, -math.Floor(math.Log10(t.Major))))
+	for i := math.Ceil(min / t.Major); i*t.Major <= max; i++ {
+		x := i * t.Major
+		ticks = append(ticks, plot.Tick{Value: x, Label: strconv.FormatFloat(x, 'f', decimals, 64)})
 	}
 
-	minorStart := math.Ceil(min/t.Minor) * t.Minor
-	for x := minorStart; x <= max; x += t.Minor {
-		if math.Mod(x, t.Major) != 0 {
-			ticks = append(ticks, plot.Tick{Value: x})
+	perMajor := math.Round(t.Major / t.Minor)
+	for i := math.Ceil(min / t.Minor); i*t.Minor <= max; i++ {
+		if math.Mod(i, perMajor) != 0 {
+			ticks = append(ticks, plot.Tick{Value: i * t.Minor})
 		}
 	}
 
 	return ticks
 }
+
+// PolarGrid is the grid of polar graphs: circles around the origin every
+// Step, and rays out of it every π/6 labelled with their angle where they
+// leave the graph.
+type PolarGrid struct {
+	Step    float64
+	Circles draw.LineStyle
+	Rays    draw.LineStyle
+	Labels  draw.TextStyle
+}
+
+// polarRays is the number of rays of a PolarGrid, one every π/6.
+const polarRays = 12
+
+func (g PolarGrid) Plot(c draw.Canvas, plt *plot.Plot) {
+	trX, trY := plt.Transforms(&c)
+	xmin, xmax, ymin, ymax := plt.X.Min, plt.X.Max, plt.Y.Min, plt.Y.Max
+
+	// Circles from the one closest to the graph to the furthest corner
+	far := math.Max(math.Hypot(xmin, ymin), math.Max(math.Hypot(xmin, ymax),
+		math.Max(math.Hypot(xmax, ymin), math.Hypot(xmax, ymax))))
+	near := math.Hypot(math.Max(0, math.Max(xmin, -xmax)), math.Max(0, math.Max(ymin, -ymax)))
+
+	for i := math.Max(1, math.Ceil(near/g.Step)); i*g.Step <= far; i++ {
+		r := i * g.Step
+		circle := make([]vg.Point, 0, 181)
+		for j := 0; j <= 180; j++ {
+			a := float64(j) * 2 * math.Pi / 180
+			circle = append(circle, vg.Point{X: trX(r * math.Cos(a)), Y: trY(r * math.Sin(a))})
+		}
+		c.StrokeLines(g.Circles, c.ClipLinesXY(circle)...)
+	}
+
+	for k := 0; k < polarRays; k++ {
+		a := float64(k) * 2 * math.Pi / polarRays
+		cos, sin := math.Cos(a), math.Sin(a)
+		ray := []vg.Point{{X: trX(0), Y: trY(0)}, {X: trX(far * cos), Y: trY(far * sin)}}
+		c.StrokeLines(g.Rays, c.ClipLinesXY(ray)...)
+
+		// The ray is on the graph from enter to leave times its direction
+		enter, leave := 0.0, far
+		for _, axis := range []struct{ min, max, d float64 }{{xmin, xmax, cos}, {ymin, ymax, sin}} {
+			if math.Abs(axis.d) < 1e-12 {
+				if axis.min > 0 || axis.max < 0 {
+					leave = -1
+				}
+				continue
+			}
+			s0, s1 := axis.min/axis.d, axis.max/axis.d
+			enter, leave = math.Max(enter, math.Min(s0, s1)), math.Min(leave, math.Max(s0, s1))
+		}
+		if leave <= enter {
+			continue
+		}
+
+		// The label sits just inside the edge, unless the ray is too short
+		end := vg.Point{X: trX(leave * cos), Y: trY(leave * sin)}
+		start := vg.Point{X: trX(enter * cos), Y: trY(enter * sin)}
+		inset := end.Sub(start)
+		length := vg.Length(math.Hypot(float64(inset.X), float64(inset.Y)))
+		margin := g.Labels.Font.Size * 1.5
+		if length < 2*margin {
+			continue
+		}
+		c.FillText(g.Labels, end.Sub(inset.Scale(margin/length)), piText(k, polarRays/2))
+	}
+}
+
+// piText writes the angle n/d * π as a fraction of π, like 2π/3.
+func piText(n, d int) string {
+	if n == 0 {
+		return "0"
+	}
+
+	a, b := n, d
+	for b != 0 {
+		a, b = b, a%b
+	}
+	n, d = n/a, d/a
+
+	res := "π"
+	if n != 1 {
+		res = strconv.Itoa(n) + res
+	}
+	if d != 1 {
+		res += "/" + strconv.Itoa(d)
+	}
+	return res
+}
@@ -0,0 +1,177 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Node is a node in the abstract syntax tree of a compiled expression.
+type Node interface {
+	String() string
+}
+
+// LiteralNode is a number literal. Its value is parsed once at compile time.
+type LiteralNode struct {
+	Tok   *Token
+	Value *big.Rat
+}
+
+// IdentNode is a reference to a variable.
+type IdentNode struct {
+	Name string
+}
+
+// UnaryNode is a prefix or postfix operator applied to a single operand, like
+// -a, ~a or a!.
+type UnaryNode struct {
+	Op      *Token
+	Operand Node
+}
+
+// BinaryNode is an operator applied to a left and right hand side.
+type BinaryNode struct {
+	Op       *Token
+	Lhs, Rhs Node
+}
+
+// CondNode is a conditional expression, Cond ? Then : Else. Only the branch
+// that's picked gets evaluated.
+type CondNode struct {
+	Cond, Then, Else Node
+}
+
+// CallNode is a function call.
+type CallNode struct {
+	Name string
+	Args []Node
+}
+
+// AssignNode assigns the result of Value to the variable Name, using any of
+// the assignment operators.
+type AssignNode struct {
+	Op    *Token
+	Name  string
+	Value Node
+}
+
+// MatrixNode is a matrix literal like [1, 2; 3, 4], its elements listed by
+// row.
+type MatrixNode struct {
+	Rows [][]Node
+}
+
+// FuncDefNode defines a function Name with parameters Params, like
+// f(x, y) = x ^ 2 + y.
+type FuncDefNode struct {
+	Name   string
+	Params []string
+	Body   Node
+}
+
+func (n *LiteralNode) String() string {
+	return n.Tok.Value
+}
+
+func (n *IdentNode) String() string {
+	return n.Name
+}
+
+func (n *UnaryNode) String() string {
+	op := operators[n.Op.Type]
+
+	// Keep (-3)! and (3!)! apart from -3! and 3!!, and -(-3) from --3
+	_, nested := n.Operand.(*UnaryNode)
+	operand := paren(n.Operand, nested || precedence(n.Operand) < op.prec)
+
+	if op.kind == postfix {
+		return operand + n.Op.Value
+	}
+
+	return n.Op.Value + operand
+}
+
+func (n *BinaryNode) String() string {
+	op := operators[n.Op.Type]
+	lhs, rhs := precedence(n.Lhs), precedence(n.Rhs)
+
+	// Operands that bind as tight as the operator only need parentheses on the
+	// side it doesn't associate to, like a - (b - c)
+	return fmt.Sprintf("%s %s %s",
+		paren(n.Lhs, lhs < op.prec || lhs == op.prec && op.assoc == AssocRight),
+		n.Op.Value,
+		paren(n.Rhs, rhs < op.prec || rhs == op.prec && op.assoc == AssocLeft))
+}
+
+func (n *CondNode) String() string {
+	prec := operators[Question].prec
+
+	return fmt.Sprintf("%s ? %s : %s",
+		paren(n.Cond, precedence(n.Cond) <= prec),
+		paren(n.Then, precedence(n.Then) <= prec),
+		paren(n.Else, precedence(n.Else) < prec))
+}
+
+func (n *CallNode) String() string {
+	args := make([]string, len(n.Args))
+	for i, arg := range n.Args {
+		args[i] = arg.String()
+	}
+
+	return fmt.Sprintf("%s(%s)", n.Name, strings.Join(args, ", "))
+}
+
+func (n *MatrixNode) String() string {
+	rows := make([]string, len(n.Rows))
+	for i, row := range n.Rows {
+		elems := make([]string, len(row))
+		for j, elem := range row {
+			elems[j] = elem.String()
+		}
+		rows[i] = strings.Join(elems, ", ")
+	}
+
+	return "[" + strings.Join(rows, "; ") + "]"
+}
+
+func (n *AssignNode) String() string {
+	return fmt.Sprintf("%s %s %s", n.Name, n.Op.Value, n.Value)
+}
+
+func (n *FuncDefNode) String() string {
+	return fmt.Sprintf("%s(%s) = %s", n.Name, strings.Join(n.Params, ", "), n.Body)
+}
+
+// atomPrec is the precedence of nodes that never need parentheses, like
+// literals and function calls.
+const atomPrec = 15
+
+// precedence returns how tight a node binds, which decides whether it needs
+// parentheses as the operand of an operator.
+func precedence(n Node) int {
+	switch n := n.(type) {
+	case *UnaryNode:
+		return operators[n.Op.Type].prec
+	case *BinaryNode:
+		return operators[n.Op.Type].prec
+	case *CondNode:
+		return operators[Question].prec
+	case *AssignNode, *FuncDefNode:
+		return operators[Eq].prec
+	}
+
+	return atomPrec
+}
+
+// paren formats n, wrapped in parentheses if wrap is set.
+func paren(n Node, wrap bool) string {
+	if wrap {
+		return "(" + n.String() + ")"
+	}
+
+	return n.String()
+}
@@ -0,0 +1,141 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	e, err := Compile("x ^ 2 - 3 * x + max(x, 4) / 2")
+	if err != nil {
+		t.Fatalf("unexpected error compiling expression: %s", err)
+	}
+
+	for i := int64(-5); i <= 5; i++ {
+		x := big.NewRat(i, 1)
+		res, err := e.Eval(map[string]*big.Rat{"x": x})
+		if err != nil {
+			t.Errorf("unexpected error evaluating compiled expression: %s", err)
+			continue
+		}
+
+		expected, _ := Exec("x ^ 2 - 3 * x + max(x, 4) / 2", map[string]*big.Rat{"x": x})
+		if res.Cmp(expected) != 0 {
+			t.Errorf("wrong result for x = %d (expected %s, got %s)", i, expected, res)
+		}
+	}
+
+	// Evaluating a literal shouldn't change it for the next evaluation
+	e, _ = Compile("ceil(813.23) + floor(-50.23)")
+	for i := 0; i < 2; i++ {
+		res, err := e.Eval(nil)
+		if err != nil || res.Cmp(big.NewRat(763, 1)) != 0 {
+			t.Errorf("wrong result on evaluation %d (expected 763, got %s)", i, res)
+		}
+	}
+
+	badExpressions := []string{
+		"+", "2 + 2 +", ")", "(2 + 2 * 8", "~~2", "2 == ()", "5 ~ 3", "2 = 3",
+		"0x", "0b2",
+	}
+
+	for _, expr := range badExpressions {
+		if _, err := Compile(expr); err == nil {
+			t.Errorf("no error compiling bad expression '%s'", expr)
+		}
+	}
+}
+
+func TestCompileAssign(t *testing.T) {
+	vars := map[string]*big.Rat{"a": big.NewRat(2, 1)}
+
+	e, err := Compile("a += 3")
+	if err != nil {
+		t.Fatalf("unexpected error compiling expression: %s", err)
+	}
+
+	res, err := e.Eval(vars)
+	if err != nil || res.Cmp(big.NewRat(5, 1)) != 0 {
+		t.Errorf("wrong assignment result (expected 5, got %s)", res)
+	}
+
+	if vars["a"].Cmp(big.NewRat(2, 1)) != 0 {
+		t.Error("assignment in compiled expression changed passed variables")
+	}
+}
+
+func TestNodes(t *testing.T) {
+	e, err := Compile("a = -max(1, b) * (2 + 0x10)")
+	if err != nil {
+		t.Fatalf("unexpected error compiling expression: %s", err)
+	}
+
+	assign, ok := e.Root.(*AssignNode)
+	if !ok || assign.Name != "a" {
+		t.Fatalf("expected assignment to a at root, got %T", e.Root)
+	}
+
+	mul, ok := assign.Value.(*BinaryNode)
+	if !ok || !mul.Op.Is(Mul) {
+		t.Fatalf("expected multiplication, got %T", assign.Value)
+	}
+
+	neg, ok := mul.Lhs.(*UnaryNode)
+	if !ok || !neg.Op.Is(UnaryMin) {
+		t.Fatalf("expected unary minus, got %T", mul.Lhs)
+	}
+
+	if call, ok := neg.Operand.(*CallNode); !ok || call.Name != "max" || len(call.Args) != 2 {
+		t.Errorf("expected call to max with 2 arguments, got %s", neg.Operand)
+	}
+
+	if e.String() != "a = -max(1, b) * (2 + 0x10)" {
+		t.Errorf("wrong string representation '%s'", e)
+	}
+}
+
+func TestString(t *testing.T) {
+	representations := map[string]string{
+		"(a - b) - c":         "a - b - c",
+		"a - (b - c)":         "a - (b - c)",
+		"(a * b) / (c * d)":   "a * b / (c * d)",
+		"(a ^ b) ^ c":         "(a ^ b) ^ c",
+		"a ^ (b ^ c)":         "a ^ b ^ c",
+		"(-x) ^ 2":            "(-x) ^ 2",
+		"-(x ^ 2)":            "-x ^ 2",
+		"-(-x)":               "-(-x)",
+		"!(!x)":               "!(!x)",
+		"(3!)!":               "(3!)!",
+		"(1 + 2) * 3":         "(1 + 2) * 3",
+		"1 + (2 * 3)":         "1 + 2 * 3",
+		"(a == b) < c":        "(a == b) < c",
+		"a == (b < c)":        "a == b < c",
+		"(a ? b : c) ? d : e": "(a ? b : c) ? d : e",
+		"a ? b : (c ? d : e)": "a ? b : c ? d : e",
+		"(a ? b : c) + 1":     "(a ? b : c) + 1",
+		"max((a), (b + c))":   "max(a, b + c)",
+		"f(x) = (x + 1)":      "f(x) = x + 1",
+	}
+
+	for expr, expected := range representations {
+		e, err := Compile(expr)
+		if err != nil {
+			t.Errorf("unexpected error compiling '%s': %s", expr, err)
+			continue
+		}
+
+		if e.String() != expected {
+			t.Errorf("wrong string representation of '%s' (expected '%s', got '%s')", expr, expected, e)
+		}
+
+		// The representation has to parse back to the same tree
+		again, err := Compile(e.String())
+		if err != nil || again.String() != e.String() {
+			t.Errorf("string representation '%s' of '%s' doesn't parse back", e, expr)
+		}
+	}
+}
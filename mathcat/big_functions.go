@@ -14,6 +14,19 @@ func Factorial(n *big.Rat) *big.Rat {
 	return new(big.Rat).SetInt(fact)
 }
 
+// DoubleFactorial calculates the double factorial n!! = n * (n - 2) * ... of
+// rational number n, which ends in 1 or 2.
+func DoubleFactorial(n *big.Rat) *big.Rat {
+	integer := RationalToInteger(n)
+	fact := big.NewInt(1)
+
+	for i := new(big.Int).Set(integer); i.Cmp(big.NewInt(1)) > 0; i.Sub(i, big.NewInt(2)) {
+		fact.Mul(fact, i)
+	}
+
+	return new(big.Rat).SetInt(fact)
+}
+
 // Gcd calculates the greatest common divisor of the numbers x and y
 func Gcd(x, y *big.Rat) *big.Rat {
 	xInt := RationalToInteger(x)
@@ -48,7 +61,7 @@ func Floor(n *big.Rat) *big.Rat {
 
 // Ceil returns the ceil of a rational number
 func Ceil(n *big.Rat) *big.Rat {
-	floor := Floor(n.Neg(n))
+	floor := Floor(new(big.Rat).Neg(n))
 	return new(big.Rat).Neg(floor)
 }
 
@@ -58,3 +71,60 @@ func Mod(x, y *big.Rat) *big.Rat {
 	quo := Floor(res.Quo(x, y))
 	return res.Sub(x, res.Mul(y, quo))
 }
+
+// IntPow raises x to the integer power n exactly. x can't be zero for negative n.
+func IntPow(x *big.Rat, n *big.Int) *big.Rat {
+	abs := new(big.Int).Abs(n)
+	num := new(big.Int).Exp(x.Num(), abs, nil)
+	denom := new(big.Int).Exp(x.Denom(), abs, nil)
+
+	if n.Sign() < 0 {
+		num, denom = denom, num
+	}
+
+	return new(big.Rat).SetFrac(num, denom)
+}
+
+// Root returns the n-th root of a non-negative rational number x, and whether
+// it could be represented exactly. ok is false if x isn't a perfect n-th power.
+func Root(x *big.Rat, n uint) (root *big.Rat, ok bool) {
+	num, numOk := intRoot(x.Num(), n)
+	denom, denomOk := intRoot(x.Denom(), n)
+	if !numOk || !denomOk {
+		return nil, false
+	}
+
+	return new(big.Rat).SetFrac(num, denom), true
+}
+
+// intRoot returns the integer n-th root of a non-negative integer a, rounded
+// down, and whether it's exact.
+func intRoot(a *big.Int, n uint) (*big.Int, bool) {
+	one := big.NewInt(1)
+	if a.Sign() == 0 || a.Cmp(one) == 0 || n == 1 {
+		return new(big.Int).Set(a), true
+	}
+
+	// 2^n > a, so the root lies between 1 and 2
+	if n >= uint(a.BitLen()) {
+		return one, false
+	}
+
+	// Newton's method, starting from a power of two that's at least the root:
+	// x = ((n - 1) * x + a / x^(n - 1)) / n
+	bigN := new(big.Int).SetUint64(uint64(n))
+	nMinus1 := new(big.Int).Sub(bigN, one)
+	x := new(big.Int).Lsh(one, (uint(a.BitLen())+n-1)/n)
+	for {
+		y := new(big.Int).Exp(x, nMinus1, nil)
+		y.Quo(a, y)
+		y.Add(y, new(big.Int).Mul(nMinus1, x))
+		y.Quo(y, bigN)
+		if y.Cmp(x) >= 0 {
+			break
+		}
+		x = y
+	}
+
+	return x, new(big.Int).Exp(x, bigN, nil).Cmp(a) == 0
+}
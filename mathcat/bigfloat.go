@@ -0,0 +1,301 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import "math/big"
+
+// guardBits are the extra bits of precision intermediate results are computed
+// with, so rounding errors don't show up in the final result.
+const guardBits = 64
+
+func newFloat(prec uint) *big.Float {
+	return new(big.Float).SetPrec(prec)
+}
+
+// negligible reports whether adding term to sum no longer changes sum at
+// precision prec, which ends the series used below.
+func negligible(term, sum *big.Float, prec uint) bool {
+	if term.Sign() == 0 {
+		return true
+	}
+	if sum.Sign() == 0 {
+		return false
+	}
+
+	return term.MantExp(nil) < sum.MantExp(nil)-int(prec)-1
+}
+
+// atanhSeries computes atanh(z) = z + z^3/3 + z^5/5 + ... for small z.
+func atanhSeries(z *big.Float, prec uint) *big.Float {
+	z2 := newFloat(prec).Mul(z, z)
+	term := newFloat(prec).Set(z)
+	sum := newFloat(prec).Set(z)
+
+	for n := int64(3); ; n += 2 {
+		term.Mul(term, z2)
+		t := newFloat(prec).Quo(term, newFloat(prec).SetInt64(n))
+		sum.Add(sum, t)
+		if negligible(t, sum, prec) {
+			return sum
+		}
+	}
+}
+
+// atanSeries computes atan(z) = z - z^3/3 + z^5/5 - ... for small z.
+func atanSeries(z *big.Float, prec uint) *big.Float {
+	z2 := newFloat(prec).Mul(z, z)
+	term := newFloat(prec).Set(z)
+	sum := newFloat(prec).Set(z)
+
+	for n := int64(3); ; n += 2 {
+		term.Mul(term, z2).Neg(term)
+		t := newFloat(prec).Quo(term, newFloat(prec).SetInt64(n))
+		sum.Add(sum, t)
+		if negligible(t, sum, prec) {
+			return sum
+		}
+	}
+}
+
+// bigPi computes pi using Machin's formula, pi = 16 atan(1/5) - 4 atan(1/239).
+func bigPi(prec uint) *big.Float {
+	wp := prec + guardBits
+	one := newFloat(wp).SetInt64(1)
+
+	a := atanSeries(newFloat(wp).Quo(one, newFloat(wp).SetInt64(5)), wp)
+	b := atanSeries(newFloat(wp).Quo(one, newFloat(wp).SetInt64(239)), wp)
+	a.Mul(a, newFloat(wp).SetInt64(16))
+	b.Mul(b, newFloat(wp).SetInt64(4))
+
+	return a.Sub(a, b).SetPrec(prec)
+}
+
+// bigLn2 computes ln(2) = 2 atanh(1/3).
+func bigLn2(prec uint) *big.Float {
+	wp := prec + guardBits
+	third := newFloat(wp).Quo(newFloat(wp).SetInt64(1), newFloat(wp).SetInt64(3))
+	res := atanhSeries(third, wp)
+
+	return res.Add(res, res).SetPrec(prec)
+}
+
+// bigSqrt returns the square root of x, which can't be negative.
+func bigSqrt(x *big.Float) *big.Float {
+	return newFloat(x.Prec()).Sqrt(x)
+}
+
+// bigExp computes e^x at the precision of x.
+func bigExp(x *big.Float) *big.Float {
+	prec := x.Prec()
+
+	// Scale x down to below 2^-8 so the Taylor series converges quickly, and
+	// square the result back up afterwards: e^x = (e^(x / 2^k))^(2^k)
+	k := 0
+	if x.Sign() != 0 {
+		if exp := x.MantExp(nil); exp > -8 {
+			k = exp + 8
+		}
+	}
+
+	wp := prec + guardBits + uint(k)
+	r := newFloat(wp).SetMantExp(x, -k)
+
+	sum := newFloat(wp).SetInt64(1)
+	term := newFloat(wp).SetInt64(1)
+	for n := int64(1); ; n++ {
+		term.Mul(term, r)
+		term.Quo(term, newFloat(wp).SetInt64(n))
+		sum.Add(sum, term)
+		if negligible(term, sum, wp) {
+			break
+		}
+	}
+
+	for i := 0; i < k; i++ {
+		sum.Mul(sum, sum)
+	}
+
+	return sum.SetPrec(prec)
+}
+
+// bigLog computes the natural logarithm of x, which has to be positive.
+func bigLog(x *big.Float) *big.Float {
+	prec := x.Prec()
+	wp := prec + guardBits
+
+	// The series below doesn't cancel out exactly for ln(1)
+	if x.Cmp(newFloat(prec).SetInt64(1)) == 0 {
+		return newFloat(prec)
+	}
+
+	// x = m * 2^exp with m in [0.5, 1), so ln(x) = ln(m) + exp * ln(2) where
+	// ln(m) = 2 atanh((m - 1) / (m + 1))
+	m := newFloat(wp)
+	exp := x.MantExp(m)
+
+	one := newFloat(wp).SetInt64(1)
+	z := newFloat(wp).Sub(m, one)
+	z.Quo(z, newFloat(wp).Add(m, one))
+
+	res := atanhSeries(z, wp)
+	res.Add(res, res)
+	res.Add(res, newFloat(wp).Mul(bigLn2(wp), newFloat(wp).SetInt64(int64(exp))))
+
+	return res.SetPrec(prec)
+}
+
+// bigSinCos computes both sin(x) and cos(x) at the precision of x.
+func bigSinCos(x *big.Float) (sin, cos *big.Float) {
+	prec := x.Prec()
+
+	// Reducing x to [-pi, pi] costs as many bits as x's integer part has
+	extra := uint(0)
+	if x.Sign() != 0 && x.MantExp(nil) > 0 {
+		extra = uint(x.MantExp(nil))
+	}
+
+	wp := prec + guardBits + extra
+	twoPi := bigPi(wp)
+	twoPi.Add(twoPi, twoPi)
+
+	// r = x - 2pi * round(x / 2pi)
+	q := newFloat(wp).Quo(x, twoPi)
+	q.Add(q, newFloat(wp).SetFloat64(0.5))
+	n, _ := q.Int(nil)
+	if q.Sign() < 0 && !q.IsInt() {
+		n.Sub(n, big.NewInt(1))
+	}
+	r := newFloat(wp).Mul(twoPi, newFloat(wp).SetInt(n))
+	r.Sub(newFloat(wp).Set(x), r)
+
+	// Taylor series of both, with term = r^n / n!
+	sin = newFloat(wp)
+	cos = newFloat(wp)
+	term := newFloat(wp).SetInt64(1)
+	for n := int64(0); ; n++ {
+		switch n % 4 {
+		case 0:
+			cos.Add(cos, term)
+		case 1:
+			sin.Add(sin, term)
+		case 2:
+			cos.Sub(cos, term)
+		case 3:
+			sin.Sub(sin, term)
+		}
+
+		if n > 1 && negligible(term, newFloat(wp).SetInt64(1), wp) {
+			break
+		}
+
+		term.Mul(term, r)
+		term.Quo(term, newFloat(wp).SetInt64(n+1))
+	}
+
+	return sin.SetPrec(prec), cos.SetPrec(prec)
+}
+
+func bigSin(x *big.Float) *big.Float {
+	sin, _ := bigSinCos(x)
+	return sin
+}
+
+func bigCos(x *big.Float) *big.Float {
+	_, cos := bigSinCos(x)
+	return cos
+}
+
+// bigTan computes tan(x), cos(x) can't be zero.
+func bigTan(x *big.Float) *big.Float {
+	sin, cos := bigSinCos(newFloat(x.Prec() + guardBits).Set(x))
+	return sin.Quo(sin, cos).SetPrec(x.Prec())
+}
+
+// bigAtan computes atan(x) at the precision of x.
+func bigAtan(x *big.Float) *big.Float {
+	prec := x.Prec()
+	wp := prec + guardBits
+	one := newFloat(wp).SetInt64(1)
+
+	// Shrink x until it's below 1/16 so the series converges quickly using
+	// atan(x) = 2 atan(x / (1 + sqrt(1 + x^2)))
+	z := newFloat(wp).Set(x)
+	k := 0
+	for z.Sign() != 0 && z.MantExp(nil) > -4 {
+		s := newFloat(wp).Mul(z, z)
+		s.Add(s, one)
+		s.Sqrt(s)
+		s.Add(s, one)
+		z.Quo(z, s)
+		k++
+	}
+
+	res := atanSeries(z, wp)
+	return res.SetMantExp(res, k).SetPrec(prec)
+}
+
+// bigAsin computes asin(x), x has to be in [-1, 1].
+func bigAsin(x *big.Float) *big.Float {
+	prec := x.Prec()
+	wp := prec + guardBits
+
+	// asin(±1) = ±pi/2
+	if x.IsInt() && x.Sign() != 0 {
+		halfPi := bigPi(wp)
+		halfPi.SetMantExp(halfPi, -1)
+		if x.Sign() < 0 {
+			halfPi.Neg(halfPi)
+		}
+		return halfPi.SetPrec(prec)
+	}
+
+	// asin(x) = atan(x / sqrt(1 - x^2))
+	s := newFloat(wp).Mul(x, x)
+	s.Sub(newFloat(wp).SetInt64(1), s)
+	s.Sqrt(s)
+
+	return bigAtan(s.Quo(x, s)).SetPrec(prec)
+}
+
+// bigAcos computes acos(x) = pi/2 - asin(x), x has to be in [-1, 1].
+func bigAcos(x *big.Float) *big.Float {
+	prec := x.Prec()
+	wp := prec + guardBits
+
+	halfPi := bigPi(wp)
+	halfPi.SetMantExp(halfPi, -1)
+
+	return halfPi.Sub(halfPi, bigAsin(newFloat(wp).Set(x))).SetPrec(prec)
+}
+
+// bigAtan2 computes the angle between the positive x axis and the point (x, y)
+// in (-pi, pi].
+func bigAtan2(y, x *big.Float, prec uint) *big.Float {
+	wp := prec + guardBits
+
+	switch {
+	case x.Sign() > 0:
+		return bigAtan(newFloat(wp).Quo(y, x)).SetPrec(prec)
+	case x.Sign() == 0 && y.Sign() == 0:
+		return newFloat(prec)
+	case x.Sign() == 0:
+		halfPi := bigPi(prec)
+		halfPi.SetMantExp(halfPi, -1)
+		if y.Sign() < 0 {
+			halfPi.Neg(halfPi)
+		}
+		return halfPi
+	}
+
+	// Left half of the plane, atan(y / x) is off by pi
+	res := bigAtan(newFloat(wp).Quo(y, x))
+	if y.Sign() < 0 {
+		res.Sub(res, bigPi(wp))
+	} else {
+		res.Add(res, bigPi(wp))
+	}
+
+	return res.SetPrec(prec)
+}
@@ -0,0 +1,381 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// calcTolerance is the relative error deriv and integrate aim for. They're
+// computed with float64, whatever the precision of the parser.
+const calcTolerance = 1e-10
+
+// derivTolerance is the largest relative error estimate deriv accepts.
+const derivTolerance = 1e-6
+
+// maxIntervals limits how many pieces integrate splits its range into.
+const maxIntervals = 1000
+
+// realFunc turns body into a function of the variable bound, for deriv and
+// integrate.
+func (p *Parser) realFunc(name, bound string, body Node) func(x float64) (float64, error) {
+	return func(x float64) (float64, error) {
+		xRat, err := ratFromFloat(name, x)
+		if err != nil {
+			return 0, err
+		}
+
+		res, err := p.bind(bound, realComplex(xRat), body)
+		if err != nil {
+			return 0, err
+		}
+		if !res.IsReal() {
+			return 0, fmt.Errorf("Expecting real numbers for ‘%s’", name)
+		}
+
+		f, _ := res.Re.Float64()
+		return f, nil
+	}
+}
+
+// floatArg evaluates an argument that has to be a real number, like the point
+// of deriv or the bounds of integrate.
+func (p *Parser) floatArg(name string, n Node) (float64, error) {
+	res, err := p.evaluate(n)
+	if err != nil {
+		return 0, err
+	}
+
+	if !res.IsReal() {
+		return 0, fmt.Errorf("Expecting real numbers for ‘%s’", name)
+	}
+
+	f, _ := res.Re.Float64()
+	if math.IsInf(f, 0) {
+		return 0, fmt.Errorf("Argument of ‘%s’ out of range", name)
+	}
+
+	return f, nil
+}
+
+// deriv evaluates deriv(expr, x, at), the derivative of expr to x at x = at.
+func (p *Parser) deriv(args []Node) (*Complex, error) {
+	bound, err := boundVar("deriv", args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	at, err := p.floatArg("deriv", args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	res, errEst, err := derivative(p.realFunc("deriv", bound, args[0]), at)
+	if err != nil {
+		return nil, err
+	}
+
+	if math.IsNaN(res) || errEst > derivTolerance*math.Max(1, math.Abs(res)) {
+		return nil, fmt.Errorf("Derivative of ‘%s’ doesn't converge at %g", args[0], at)
+	}
+
+	return approxResult("deriv", res, errEst)
+}
+
+// integrate evaluates integrate(expr, x, a, b), the integral of expr to x from
+// a to b.
+func (p *Parser) integrate(args []Node) (*Complex, error) {
+	bound, err := boundVar("integrate", args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := p.floatArg("integrate", args[2])
+	if err != nil {
+		return nil, err
+	}
+	b, err := p.floatArg("integrate", args[3])
+	if err != nil {
+		return nil, err
+	}
+
+	res, errEst, err := integral(p.realFunc("integrate", bound, args[0]), a, b)
+	if err != nil {
+		return nil, err
+	}
+
+	if math.IsNaN(res) || errEst > calcTolerance*math.Max(1, math.Abs(res)) {
+		return nil, fmt.Errorf("Integral of ‘%s’ doesn't converge", args[0])
+	}
+
+	return approxResult("integrate", res, errEst)
+}
+
+// approxResult converts the result of a numeric method to a rational number.
+// Results that are within their error of a simple fraction are taken to be
+// that fraction, so integrate(sin(x), x, 0, pi) is 2 and not 1.9999999999999998.
+func approxResult(name string, x, errEst float64) (*Complex, error) {
+	scale := math.Max(1, math.Abs(x))
+	tol := math.Min(math.Max(errEst, 1e-14*scale), calcTolerance*scale)
+
+	if frac, ok := nearFraction(x, tol); ok {
+		return realComplex(frac), nil
+	}
+
+	res, err := ratFromFloat(name, x)
+	if err != nil {
+		return nil, err
+	}
+
+	return realComplex(res), nil
+}
+
+// nearFraction looks for a fraction with a denominator of at most 1000 within
+// tol of x, using the convergents of the continued fraction of x.
+func nearFraction(x, tol float64) (*big.Rat, bool) {
+	if math.Abs(x) > 1e12 {
+		return nil, false
+	}
+
+	// h/k are the convergents, starting from 0/1 and 1/0
+	h0, h1 := 0.0, 1.0
+	k0, k1 := 1.0, 0.0
+	r := x
+
+	for {
+		a := math.Floor(r)
+		h := a*h1 + h0
+		k := a*k1 + k0
+		if k > 1000 {
+			return nil, false
+		}
+
+		if math.Abs(h/k-x) <= tol {
+			return big.NewRat(int64(h), int64(k)), true
+		}
+
+		if r == a {
+			return nil, false
+		}
+
+		r = 1 / (r - a)
+		h0, h1 = h1, h
+		k0, k1 = k1, k
+	}
+}
+
+// derivative computes the derivative of f at x with Ridders' method, which
+// extrapolates central differences with shrinking step sizes to a step size of
+// zero. It returns the derivative and an estimate of its error.
+//
+// The first step is 0.1 * |x|. If that steps over or close to a pole, like for
+// tan(x) at 1.5, the differences blow up or f fails, so it starts over with a
+// step ten times smaller, a few times.
+func derivative(f func(float64) (float64, error), x float64) (float64, float64, error) {
+	const starts = 4
+
+	h := 0.1
+	if x != 0 {
+		h = 0.1 * math.Abs(x)
+	}
+
+	res, errEst := math.NaN(), math.Inf(1)
+	var err error
+	for i := 0; i < starts; i++ {
+		d, e, rerr := ridders(f, x, h)
+		if rerr == nil && !math.IsNaN(d) && !math.IsInf(d, 0) && e < errEst {
+			res, errEst = d, e
+		} else if rerr != nil {
+			err = rerr
+		}
+
+		if errEst <= derivTolerance*math.Max(1, math.Abs(res)) {
+			break
+		}
+		h /= 10
+	}
+
+	if math.IsNaN(res) && err != nil {
+		return 0, 0, err
+	}
+
+	return res, errEst, nil
+}
+
+// ridders extrapolates the central differences of f at x, starting at step h.
+func ridders(f func(float64) (float64, error), x, h float64) (float64, float64, error) {
+	const (
+		shrink = 1.4
+		steps  = 10
+	)
+
+	central := func(h float64) (float64, error) {
+		fp, err := f(x + h)
+		if err != nil {
+			return 0, err
+		}
+		fm, err := f(x - h)
+		if err != nil {
+			return 0, err
+		}
+
+		return (fp - fm) / (2 * h), nil
+	}
+
+	// table[j][i] is the central difference at step i, extrapolated j times
+	var table [steps][steps]float64
+	d, err := central(h)
+	if err != nil {
+		return 0, 0, err
+	}
+	table[0][0] = d
+
+	res, errEst := d, math.Inf(1)
+	for i := 1; i < steps; i++ {
+		h /= shrink
+		if table[0][i], err = central(h); err != nil {
+			return 0, 0, err
+		}
+
+		fac := shrink * shrink
+		for j := 1; j <= i; j++ {
+			table[j][i] = (table[j-1][i]*fac - table[j-1][i-1]) / (fac - 1)
+			fac *= shrink * shrink
+
+			e := math.Max(math.Abs(table[j][i]-table[j-1][i]), math.Abs(table[j][i]-table[j-1][i-1]))
+			if e <= errEst {
+				res, errEst = table[j][i], e
+			}
+		}
+
+		// Stop once a higher order makes things worse, rounding errors are
+		// taking over
+		if math.Abs(table[i][i]-table[i-1][i-1]) >= 2*errEst {
+			break
+		}
+	}
+
+	return res, errEst, nil
+}
+
+// Nodes and weights of the 15 point Gauss–Kronrod rule on [-1, 1], which
+// includes the 7 point Gauss rule at every other node. The rule is symmetric,
+// so only the nodes in [0, 1) are listed.
+var (
+	kronrodNodes = [8]float64{
+		0.991455371120812639206854697526329, 0.949107912342758524526189684047851,
+		0.864864423359769072789712788640926, 0.741531185599394439863864773280788,
+		0.586087235467691130294144845693013, 0.405845151377397166906606412076961,
+		0.207784955007898467600689403773245, 0,
+	}
+	kronrodWeights = [8]float64{
+		0.022935322010529224963732008058970, 0.063092092629978553290700663189204,
+		0.104790010322250183839876322541518, 0.140653259715525918745189590510238,
+		0.169004726639267902826583426598550, 0.190350578064785409913256402421014,
+		0.204432940075298892414161999234649, 0.209482141084727828012999174891714,
+	}
+	gaussWeights = [4]float64{
+		0.129484966168869693270611432679082, 0.279705391489276667901467771423780,
+		0.381830050505118944950369775488975, 0.417959183673469387755102040816327,
+	}
+)
+
+// interval is a piece of the range of an integral, with its estimated value
+// and error.
+type interval struct {
+	a, b, value, err float64
+}
+
+// gaussKronrod estimates the integral of f over [a, b] with the 15 point
+// Kronrod rule, and its error as the difference with the 7 point Gauss rule.
+func gaussKronrod(f func(float64) (float64, error), a, b float64) (interval, error) {
+	center := (a + b) / 2
+	half := (b - a) / 2
+
+	fc, err := f(center)
+	if err != nil {
+		return interval{}, err
+	}
+	kronrod := fc * kronrodWeights[7]
+	gauss := fc * gaussWeights[3]
+
+	for i := 0; i < 7; i++ {
+		fl, err := f(center - half*kronrodNodes[i])
+		if err != nil {
+			return interval{}, err
+		}
+		fr, err := f(center + half*kronrodNodes[i])
+		if err != nil {
+			return interval{}, err
+		}
+
+		kronrod += (fl + fr) * kronrodWeights[i]
+		if i%2 == 1 {
+			gauss += (fl + fr) * gaussWeights[i/2]
+		}
+	}
+
+	return interval{a, b, kronrod * half, math.Abs((kronrod - gauss) * half)}, nil
+}
+
+// integral computes the integral of f from a to b with adaptive Gauss–Kronrod
+// quadrature: the piece with the largest error is split in two until the
+// total error is small enough. It returns the integral and an estimate of its
+// error.
+func integral(f func(float64) (float64, error), a, b float64) (float64, float64, error) {
+	if a == b {
+		return 0, 0, nil
+	}
+	if a > b {
+		res, errEst, err := integral(f, b, a)
+		return -res, errEst, err
+	}
+
+	whole, err := gaussKronrod(f, a, b)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	intervals := []interval{whole}
+	value, errEst := whole.value, whole.err
+
+	for len(intervals) < maxIntervals && errEst > calcTolerance*math.Max(1, math.Abs(value))/10 {
+		worst := 0
+		for i, in := range intervals {
+			if in.err > intervals[worst].err {
+				worst = i
+			}
+		}
+
+		in := intervals[worst]
+		mid := (in.a + in.b) / 2
+		if mid <= in.a || mid >= in.b {
+			// Can't be split any further in float64
+			break
+		}
+
+		left, err := gaussKronrod(f, in.a, mid)
+		if err != nil {
+			return 0, 0, err
+		}
+		right, err := gaussKronrod(f, mid, in.b)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		intervals[worst] = left
+		intervals = append(intervals, right)
+
+		value, errEst = 0, 0
+		for _, in := range intervals {
+			value += in.value
+			errEst += in.err
+		}
+	}
+
+	return value, errEst, nil
+}
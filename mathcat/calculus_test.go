@@ -0,0 +1,102 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestCalculus(t *testing.T) {
+	exactExpressions := map[string]*big.Rat{
+		"integrate(sin(x), x, 0, pi)":          big.NewRat(2, 1),
+		"integrate(sin(x), x, pi, 0)":          big.NewRat(-2, 1),
+		"integrate(x^2, x, 0, 1)":              big.NewRat(1, 3),
+		"integrate(abs(x), x, -1, 2)":          big.NewRat(5, 2),
+		"integrate(1 / sqrt(x), x, 0, 1)":      big.NewRat(2, 1),
+		"integrate(ln(x), x, 0, 1)":            big.NewRat(-1, 1),
+		"integrate(x < 0 ? 0 : 1, x, -1, 1)":   big.NewRat(1, 1),
+		"integrate(x, x, 3, 3)":                big.NewRat(0, 1),
+		"deriv(x^2, x, 3)":                     big.NewRat(6, 1),
+		"deriv(sin(x), x, 0)":                  big.NewRat(1, 1),
+		"deriv(ln(x), x, 0.001)":               big.NewRat(1000, 1),
+		"deriv(x^3, x, 10^5)":                  big.NewRat(30000000000, 1),
+		"deriv(integrate(t^2, t, 0, x), x, 2)": big.NewRat(4, 1),
+		"deriv(x < 1 ? x : 2x, x, 3)":          big.NewRat(2, 1),
+		"deriv(1 / (x - 1), x, 1.05)":          big.NewRat(-400, 1),
+	}
+
+	for expr, expected := range exactExpressions {
+		res, err := Eval(expr)
+		if err != nil {
+			t.Errorf("unexpected error in '%s': %s", expr, err)
+			continue
+		}
+
+		if expected.Cmp(res) != 0 {
+			t.Errorf("wrong result in expression '%s' (expected %s, got %s)", expr, expected, res)
+		}
+	}
+
+	approxExpressions := map[string]float64{
+		"integrate(exp(x), x, 0, 1)":            math.E - 1,
+		"integrate(exp(-x^2), x, -10, 10)":      math.Sqrt(math.Pi),
+		"integrate(sin(x)^2, x, 0, 100)":        50 - math.Sin(200)/4,
+		"integrate(sum(k, 0, 3, x^k), x, 0, 2)": 2 + 2 + 8.0/3 + 4,
+		"deriv(exp(x), x, 1)":                   math.E,
+		"deriv(atan(x), x, 0.5)":                0.8,
+		"integrate(4 / (1 + x^2), x, 0, 1)":     math.Pi,
+		"deriv(x^x, x, 2)":                      4 + 4*math.Ln2,
+		"deriv(tan(x), x, 1.5)":                 1 / (math.Cos(1.5) * math.Cos(1.5)),
+		"deriv(tan(x), x, 1.57)":                1 / (math.Cos(1.57) * math.Cos(1.57)),
+		"deriv(1 / (x - 2), x, 1.99)":           -10000,
+	}
+
+	for expr, expected := range approxExpressions {
+		res, err := Eval(expr)
+		if err != nil {
+			t.Errorf("unexpected error in '%s': %s", expr, err)
+			continue
+		}
+
+		if f, _ := res.Float64(); math.Abs(f-expected) > 1e-9*math.Max(1, math.Abs(expected)) {
+			t.Errorf("wrong result in expression '%s' (expected %g, got %g)", expr, expected, f)
+		}
+	}
+
+	badExpressions := []string{
+		"integrate(1 / x, x, -1, 1)",
+		"integrate(sin(x), x, 0)",
+		"integrate(sin(x), 2, 0, 1)",
+		"integrate(sqrt(x), x, -1, 1)",
+		"integrate(sin(1 / x), x, 0, 1)",
+		"integrate(x, x, 0, i)",
+		"deriv(sqrt(x), x, 0)",
+		"deriv(1 / x, x, 0)",
+		"deriv(x < 0 ? 0 : 1, x, 0)",
+		"deriv(x, y, 0)",
+	}
+
+	for _, expr := range badExpressions {
+		if _, err := Eval(expr); err == nil {
+			t.Errorf("no error in bad expression '%s'", expr)
+		}
+	}
+
+	p := New()
+	p.SetAngleMode(Degrees)
+	res, err := p.Run("deriv(sin(x), x, 0) * 180 / pi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f, _ := res.Float64(); math.Abs(f-1) > 1e-9 {
+		t.Errorf("wrong derivative of sin in degrees (expected 1, got %g)", f)
+	}
+
+	if _, err := p.GetVar("x"); err == nil {
+		t.Errorf("bound variable x leaked into the parser's variables")
+	}
+}
@@ -12,12 +12,13 @@ import (
 	"runtime"
 
 	"github.com/chzyer/readline"
-	"github.com/soudy/mathcat"
+	"opencalcc/mathcat"
 )
 
 var (
-	precision   = flag.Uint("precision", 64, "bits of precision used in decimal float results")
+	precision   = flag.Uint("precision", 64, "bits of precision used for functions and decimal float results")
 	literalMode = flag.String("mode", "decimal", "type of literal used as result. can be decimal (default), hex, binary or octal")
+	angle       = flag.String("angle", "radians", "unit of angles used by trigonometric functions. can be radians (default), degrees or gradians")
 )
 
 func getHomeDir() string {
@@ -33,8 +34,10 @@ func getHomeDir() string {
 	return os.Getenv("HOME")
 }
 
-func repl(mode Mode) {
+func repl(mode Mode, angleMode mathcat.AngleMode) {
 	p := mathcat.New()
+	p.SetPrecision(*precision)
+	p.SetAngleMode(angleMode)
 	rl, err := readline.NewEx(&readline.Config{
 		Prompt:      "mc> ",
 		HistoryFile: getHomeDir() + "/.mathcat_history",
@@ -52,30 +55,41 @@ func repl(mode Mode) {
 			break
 		}
 
-		res, err := p.Run(line)
+		res, err := p.RunComplex(line)
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err.Error())
 			continue
 		}
 
+		// Function definitions don't have a result to print
+		if res == nil {
+			continue
+		}
+
 		switch mode {
 		case Decimal:
-			if res.IsInt() {
-				fmt.Println(res.Num())
-			} else {
-				stringResult := new(big.Float).
+			fmt.Println(res.Text(func(x *big.Rat) string {
+				if x.IsInt() {
+					return x.Num().String()
+				}
+
+				return new(big.Float).
 					SetPrec(*precision).
-					SetRat(res).
+					SetRat(x).
 					Text('f', -1)
-				fmt.Println(stringResult)
-			}
+			}))
 		case Hex, Binary, Octal:
+			if !res.IsReal() {
+				fmt.Fprintln(os.Stderr, mathcat.ErrNotReal.Error())
+				continue
+			}
+
 			formats := map[Mode]string{
 				Hex:    "%#x",
 				Binary: "%b",
 				Octal:  "%#o",
 			}
-			integer := mathcat.RationalToInteger(res)
+			integer := mathcat.RationalToInteger(res.Re)
 			fmt.Printf(formats[mode]+"\n", integer)
 		}
 	}
@@ -83,6 +97,7 @@ func repl(mode Mode) {
 
 func main() {
 	var mode Mode
+	var angleMode mathcat.AngleMode
 	var ok bool
 
 	flag.Parse()
@@ -92,5 +107,10 @@ func main() {
 		os.Exit(-1)
 	}
 
-	repl(mode)
+	if angleMode, ok = angleModes[*angle]; !ok {
+		fmt.Fprintf(os.Stderr, "Invalid angle mode ‘%s’\n", *angle)
+		os.Exit(-1)
+	}
+
+	repl(mode, angleMode)
 }
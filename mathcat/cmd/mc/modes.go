@@ -4,6 +4,8 @@
 
 package main
 
+import "opencalcc/mathcat"
+
 type Mode int
 
 const (
@@ -19,3 +21,9 @@ var modes = map[string]Mode{
 	"binary":  Binary,
 	"octal":   Octal,
 }
+
+var angleModes = map[string]mathcat.AngleMode{
+	"radians":  mathcat.Radians,
+	"degrees":  mathcat.Degrees,
+	"gradians": mathcat.Gradians,
+}
@@ -0,0 +1,411 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// Complex is a complex number with a rational real and imaginary part. Real
+// numbers have an imaginary part of zero.
+//
+// Matrices are values too: for those Matrix is set, and Re and Im are nil.
+type Complex struct {
+	Re, Im *big.Rat
+	Matrix Matrix
+}
+
+var (
+	ErrNotReal = errors.New("Result is not a real number")
+	ErrMatrix  = errors.New("Result is a matrix")
+)
+
+// NewComplex returns the complex number re + im * i.
+func NewComplex(re, im *big.Rat) *Complex {
+	return &Complex{Re: re, Im: im}
+}
+
+// realComplex returns x as a complex number without an imaginary part.
+func realComplex(x *big.Rat) *Complex {
+	return &Complex{Re: x, Im: new(big.Rat)}
+}
+
+// IsReal reports whether z has no imaginary part. Matrices aren't real.
+func (z *Complex) IsReal() bool {
+	return z.Matrix == nil && z.Im.Sign() == 0
+}
+
+// IsMatrix reports whether z is a matrix rather than a number.
+func (z *Complex) IsMatrix() bool {
+	return z.Matrix != nil
+}
+
+func (z *Complex) String() string {
+	return z.Text((*big.Rat).RatString)
+}
+
+// Text formats z as a + bi, with both parts formatted by format. Parts that
+// are zero are left out, so real numbers are formatted like a and imaginary
+// numbers like bi. Matrices are formatted like [1, 2; 3, 4].
+//
+// Example:
+//
+//	z.Text(func(x *big.Rat) string { return x.FloatString(2) }) // 1.50 - 0.25i
+func (z *Complex) Text(format func(*big.Rat) string) string {
+	if z.IsMatrix() {
+		return z.Matrix.Text(format)
+	}
+
+	if z.IsReal() {
+		return format(z.Re)
+	}
+
+	im := new(big.Rat).Abs(z.Im)
+	imText := format(im) + "i"
+	if im.Cmp(big.NewRat(1, 1)) == 0 {
+		imText = "i"
+	}
+
+	switch {
+	case z.Re.Sign() == 0 && z.Im.Sign() < 0:
+		return "-" + imText
+	case z.Re.Sign() == 0:
+		return imText
+	case z.Im.Sign() < 0:
+		return format(z.Re) + " - " + imText
+	}
+
+	return format(z.Re) + " + " + imText
+}
+
+func (z *Complex) add(w *Complex) *Complex {
+	return &Complex{
+		Re: new(big.Rat).Add(z.Re, w.Re),
+		Im: new(big.Rat).Add(z.Im, w.Im),
+	}
+}
+
+func (z *Complex) sub(w *Complex) *Complex {
+	return &Complex{
+		Re: new(big.Rat).Sub(z.Re, w.Re),
+		Im: new(big.Rat).Sub(z.Im, w.Im),
+	}
+}
+
+func (z *Complex) neg() *Complex {
+	return &Complex{Re: new(big.Rat).Neg(z.Re), Im: new(big.Rat).Neg(z.Im)}
+}
+
+func (z *Complex) conj() *Complex {
+	return &Complex{Re: z.Re, Im: new(big.Rat).Neg(z.Im)}
+}
+
+// (a + bi)(c + di) = (ac - bd) + (ad + bc)i
+func (z *Complex) mul(w *Complex) *Complex {
+	re := new(big.Rat).Mul(z.Re, w.Re)
+	re.Sub(re, new(big.Rat).Mul(z.Im, w.Im))
+	im := new(big.Rat).Mul(z.Re, w.Im)
+	im.Add(im, new(big.Rat).Mul(z.Im, w.Re))
+
+	return &Complex{Re: re, Im: im}
+}
+
+// z / w = z * conj(w) / |w|^2
+func (z *Complex) quo(w *Complex) (*Complex, error) {
+	abs2 := w.abs2()
+	if abs2.Sign() == 0 {
+		return nil, ErrDivisionByZero
+	}
+
+	res := z.mul(w.conj())
+	res.Re.Quo(res.Re, abs2)
+	res.Im.Quo(res.Im, abs2)
+
+	return res, nil
+}
+
+// abs2 returns the square of the absolute value of z, which is always exact.
+func (z *Complex) abs2() *big.Rat {
+	res := new(big.Rat).Mul(z.Re, z.Re)
+	return res.Add(res, new(big.Rat).Mul(z.Im, z.Im))
+}
+
+func (z *Complex) equal(w *Complex) bool {
+	return z.Re.Cmp(w.Re) == 0 && z.Im.Cmp(w.Im) == 0
+}
+
+func (z *Complex) isZero() bool {
+	return z.Re.Sign() == 0 && z.Im.Sign() == 0
+}
+
+// intPow raises z to the integer power n exactly, by repeated squaring.
+func (z *Complex) intPow(n *big.Int) (*Complex, error) {
+	res := realComplex(big.NewRat(1, 1))
+	square := z
+
+	// Bit reads negative numbers in two's complement, so the bits are those
+	// of |n| and the result is inverted afterwards
+	abs := new(big.Int).Abs(n)
+	for i := 0; i < abs.BitLen(); i++ {
+		if abs.Bit(i) == 1 {
+			res = res.mul(square)
+		}
+		square = square.mul(square)
+	}
+
+	if n.Sign() < 0 {
+		return realComplex(big.NewRat(1, 1)).quo(res)
+	}
+
+	return res, nil
+}
+
+// execute evaluates a unary or binary operator. Operators on real numbers are
+// left to executeExpression, only arithmetic and (in)equality are defined for
+// complex numbers. lhs is nil for unary operators and plain assignment.
+func (p *Parser) execute(operator *Token, lhs, rhs *Complex) (*Complex, error) {
+	if (lhs != nil && lhs.IsMatrix()) || rhs.IsMatrix() {
+		return p.executeMatrix(operator, lhs, rhs)
+	}
+
+	if (lhs == nil || lhs.IsReal()) && rhs.IsReal() {
+		var lhsRat *big.Rat
+		if lhs != nil {
+			lhsRat = lhs.Re
+		}
+
+		res, err := executeExpression(operator, lhsRat, rhs.Re, p.prec)
+		switch {
+		case err == ErrNegativeBase:
+			// A fractional power of a negative number is complex
+			return p.pow(lhs, rhs)
+		case err != nil:
+			return nil, err
+		}
+
+		return realComplex(res), nil
+	}
+
+	switch operator.Type {
+	case Add, AddEq:
+		return lhs.add(rhs), nil
+	case Sub, SubEq:
+		return lhs.sub(rhs), nil
+	case UnaryMin:
+		return rhs.neg(), nil
+	case Percent:
+		return rhs.quo(realComplex(big.NewRat(100, 1)))
+	case Mul, MulEq:
+		return lhs.mul(rhs), nil
+	case Div, DivEq:
+		return lhs.quo(rhs)
+	case LeftDiv:
+		return rhs.quo(lhs)
+	case Pow, PowEq:
+		return p.pow(lhs, rhs)
+	case Eq:
+		return rhs, nil
+	case EqEq:
+		return realComplex(boolToRat(lhs.equal(rhs))), nil
+	case NotEq:
+		return realComplex(boolToRat(!lhs.equal(rhs))), nil
+	}
+
+	return nil, fmt.Errorf("Expecting real numbers for ‘%s’", operator)
+}
+
+// pow raises z to the power of w. Integer powers and square roots of negative
+// numbers are exact, other powers are the principal value of e^(w * ln(z)).
+func (p *Parser) pow(z, w *Complex) (*Complex, error) {
+	if w.IsReal() && w.Re.IsInt() {
+		return z.intPow(w.Re.Num())
+	}
+
+	if z.Re.Sign() == 0 && z.IsReal() {
+		if w.Re.Sign() > 0 {
+			return realComplex(new(big.Rat)), nil
+		}
+		return nil, ErrDivisionByZero
+	}
+
+	// (-x)^(n/2) = x^(n/2) * i^n
+	if z.IsReal() && z.Re.Sign() < 0 && w.IsReal() && w.Re.Denom().Cmp(big.NewInt(2)) == 0 {
+		abs, err := pow(new(big.Rat).Neg(z.Re), w.Re, p.prec)
+		if err != nil {
+			return nil, err
+		}
+
+		if Mod(new(big.Rat).SetInt(w.Re.Num()), big.NewRat(4, 1)).Cmp(big.NewRat(1, 1)) == 0 {
+			return &Complex{Re: new(big.Rat), Im: abs}, nil
+		}
+		return &Complex{Re: new(big.Rat), Im: abs.Neg(abs)}, nil
+	}
+
+	ln, err := p.ln(z)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.exp(ln.mul(w))
+}
+
+// abs returns the absolute value of z, which is exact for real numbers and
+// when |z|^2 is a perfect square, like |3 + 4i| = 5.
+func (p *Parser) abs(z *Complex) (*big.Rat, error) {
+	if z.IsReal() {
+		return new(big.Rat).Abs(z.Re), nil
+	}
+
+	return p.sqrt(z.abs2())
+}
+
+// arg returns the angle between the positive real axis and z, in (-pi, pi].
+func (p *Parser) arg(z *Complex) (*big.Rat, error) {
+	if p.prec == 0 {
+		re, _ := z.Re.Float64()
+		im, _ := z.Im.Float64()
+		return ratFromFloat("arg", math.Atan2(im, re))
+	}
+
+	res := bigAtan2(p.float(z.Im), p.float(z.Re), p.prec)
+	rat, _ := res.Rat(nil)
+	return rat, nil
+}
+
+// sqrt returns the square root of a non-negative rational number, exactly if
+// it's a perfect square.
+func (p *Parser) sqrt(x *big.Rat) (*big.Rat, error) {
+	if root, ok := Root(x, 2); ok {
+		return root, nil
+	}
+
+	return p.approx("sqrt", x, math.Sqrt, bigSqrt)
+}
+
+// csqrt returns the principal square root of z:
+// sqrt(z) = sqrt((|z| + re) / 2) + sqrt((|z| - re) / 2) * sign(im) * i
+func (p *Parser) csqrt(z *Complex) (*Complex, error) {
+	if z.IsReal() {
+		if z.Re.Sign() >= 0 {
+			root, err := p.sqrt(z.Re)
+			if err != nil {
+				return nil, err
+			}
+			return realComplex(root), nil
+		}
+
+		root, err := p.sqrt(new(big.Rat).Neg(z.Re))
+		if err != nil {
+			return nil, err
+		}
+		return &Complex{Re: new(big.Rat), Im: root}, nil
+	}
+
+	abs, err := p.abs(z)
+	if err != nil {
+		return nil, err
+	}
+
+	half := big.NewRat(1, 2)
+	re, err := p.sqrt(new(big.Rat).Mul(new(big.Rat).Add(abs, z.Re), half))
+	if err != nil {
+		return nil, err
+	}
+	im, err := p.sqrt(new(big.Rat).Mul(new(big.Rat).Sub(abs, z.Re), half))
+	if err != nil {
+		return nil, err
+	}
+	if z.Im.Sign() < 0 {
+		im.Neg(im)
+	}
+
+	return &Complex{Re: re, Im: im}, nil
+}
+
+// ln returns the principal natural logarithm of z, ln(|z|) + arg(z) * i.
+func (p *Parser) ln(z *Complex) (*Complex, error) {
+	if z.IsReal() && z.Re.Sign() > 0 {
+		res, err := p.approx("ln", z.Re, math.Log, bigLog)
+		if err != nil {
+			return nil, err
+		}
+		return realComplex(res), nil
+	}
+
+	if z.Re.Sign() == 0 && z.IsReal() {
+		return nil, errors.New("ln of zero")
+	}
+
+	// ln(|z|) = ln(|z|^2) / 2
+	re, err := p.approx("ln", z.abs2(), math.Log, bigLog)
+	if err != nil {
+		return nil, err
+	}
+	re.Mul(re, big.NewRat(1, 2))
+
+	im, err := p.arg(z)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Complex{Re: re, Im: im}, nil
+}
+
+// exp returns e^z = e^re * (cos(im) + sin(im) * i).
+func (p *Parser) exp(z *Complex) (*Complex, error) {
+	abs, err := p.approx("exp", z.Re, math.Exp, bigExp)
+	if err != nil {
+		return nil, err
+	}
+	if z.IsReal() {
+		return realComplex(abs), nil
+	}
+
+	cos, err := p.approx("exp", z.Im, math.Cos, bigCos)
+	if err != nil {
+		return nil, err
+	}
+	sin, err := p.approx("exp", z.Im, math.Sin, bigSin)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.clean(&Complex{Re: cos.Mul(cos, abs), Im: sin.Mul(sin, abs)}), nil
+}
+
+// clean drops the real or imaginary part of an approximated result if it's
+// negligible next to the other part, like the imaginary part of e^(pi * i).
+func (p *Parser) clean(z *Complex) *Complex {
+	re := new(big.Rat).Abs(z.Re)
+	im := new(big.Rat).Abs(z.Im)
+
+	if im.Cmp(new(big.Rat).Mul(re, p.epsilon())) < 0 {
+		z.Im = new(big.Rat)
+	} else if re.Cmp(new(big.Rat).Mul(im, p.epsilon())) < 0 {
+		z.Re = new(big.Rat)
+	}
+
+	return z
+}
+
+// realArgs converts the arguments of a function that only takes real numbers.
+func realArgs(name string, args []*Complex) ([]*big.Rat, error) {
+	res := make([]*big.Rat, len(args))
+
+	for i, arg := range args {
+		if arg.IsMatrix() {
+			return nil, fmt.Errorf("Expecting numbers for '%s', got a matrix", name)
+		}
+		if !arg.IsReal() {
+			return nil, fmt.Errorf("Expecting real numbers for '%s'", name)
+		}
+		res[i] = arg.Re
+	}
+
+	return res, nil
+}
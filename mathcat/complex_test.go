@@ -0,0 +1,105 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestComplex(t *testing.T) {
+	exactExpressions := map[string]string{
+		"i":                     "i",
+		"i ^ 2":                 "-1",
+		"i ^ -1":                "-i",
+		"i ^ -3":                "i",
+		"i ^ -4":                "1",
+		"(1 + i) ^ -3":          "-1/4 - 1/4i",
+		"(1 + i) ^ -5":          "-1/8 + 1/8i",
+		"sqrt(-1)":              "i",
+		"sqrt(-4) + 1":          "1 + 2i",
+		"(-9) ^ 1.5":            "-27i",
+		"(1 + 2 * i) * (3 - i)": "5 + 5i",
+		"(1 + i) / (1 - i)":     "i",
+		"(2 + 3 * i) ^ 2":       "-5 + 12i",
+		"1 / 2 - i / 4":         "1/2 - 1/4i",
+		"re(3 - 4 * i)":         "3",
+		"im(3 - 4 * i)":         "-4",
+		"conj(3 - 4 * i)":       "3 + 4i",
+		"abs(3 - 4 * i)":        "5",
+		"abs(-7)":               "7",
+		"sqrt(3 + 4 * i)":       "2 + i",
+		"sqrt(-3 - 4 * i)":      "1 - 2i",
+		"arg(1)":                "0",
+		"i == sqrt(-1)":         "1",
+		"i != -i":               "1",
+	}
+
+	for expr, expected := range exactExpressions {
+		res, err := New().RunComplex(expr)
+		if err != nil {
+			t.Errorf("unexpected error in '%s': %s", expr, err)
+			continue
+		}
+
+		if res.String() != expected {
+			t.Errorf("wrong result in expression '%s' (expected %s, got %s)", expr, expected, res)
+		}
+	}
+
+	float := func(x *big.Rat) string { return x.FloatString(6) }
+	approxExpressions := map[string]string{
+		"exp(i * pi)":      "-1.000000",
+		"e ^ (i * pi / 2)": "i",
+		"ln(-1)":           "3.141593i",
+		"ln(i)":            "1.570796i",
+		"log(-10)":         "1.000000 + 1.364376i",
+		"arg(-1 - i)":      "-2.356194",
+		"(-8) ^ (1 / 4)":   "1.189207 + 1.189207i",
+		"i ^ i":            "0.207880",
+		"abs(1 + i)":       "1.414214",
+	}
+
+	for expr, expected := range approxExpressions {
+		res, err := New().RunComplex(expr)
+		if err != nil {
+			t.Errorf("unexpected error in '%s': %s", expr, err)
+			continue
+		}
+
+		if text := res.Text(float); text != expected {
+			t.Errorf("wrong result in expression '%s' (expected %s, got %s)", expr, expected, text)
+		}
+	}
+
+	p := New()
+	p.RunComplex("z = 1 + i")
+	p.RunComplex("z *= z")
+	if z, err := p.RunComplex("z"); err != nil || z.String() != "2i" {
+		t.Errorf("wrong result of complex assignment (expected 2i, got %s)", z)
+	}
+
+	if _, err := p.Run("z"); err != ErrNotReal {
+		t.Errorf("expected ErrNotReal from Run, got %v", err)
+	}
+
+	if res, err := p.Run("z * z"); err != nil || res.Cmp(big.NewRat(-4, 1)) != 0 {
+		t.Errorf("wrong real result from complex expression (expected -4, got %s)", res)
+	}
+
+	p.SetPrecision(128)
+	pi, _ := p.Run("pi")
+	if res, err := p.RunComplex("ln(-1)"); err != nil || res.Re.Sign() != 0 || res.Im.Cmp(pi) != 0 {
+		t.Errorf("wrong result of ln(-1) at 128 bits (expected pi * i, got %s)", res)
+	}
+
+	badExpressions := []string{"i < 1", "i & 1", "max(i, 1)", "1 / (i - i)", "0 ^ i"}
+
+	for _, expr := range badExpressions {
+		if _, err := New().RunComplex(expr); err == nil {
+			t.Errorf("no error in bad expression '%s'", expr)
+		}
+	}
+}
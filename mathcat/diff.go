@@ -0,0 +1,805 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// maxInlineDepth limits how deep user defined functions are inlined when
+// differentiating, so recursive definitions fail instead of growing forever.
+const maxInlineDepth = 100
+
+// Diff returns the derivative of expr to the variable name, with angles in
+// radians. Other variables are treated as constants.
+//
+// Example:
+//
+//	e, err := mathcat.Diff("x ^ 2 * sin(x)", "x")
+//	fmt.Println(e) // 2 * x * sin(x) + x ^ 2 * cos(x)
+func Diff(expr, name string) (*Expr, error) {
+	e, err := Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.Diff(name)
+}
+
+// Diff returns the derivative of e to the variable name, with angles in
+// radians.
+func (e *Expr) Diff(name string) (*Expr, error) {
+	return differ{name: name}.expr(e.Root)
+}
+
+// Diff returns the derivative of expr to the variable name like the package
+// Diff, but with angles in the parser's angle mode, and the functions defined
+// on p are differentiated too.
+func (p *Parser) Diff(expr, name string) (*Expr, error) {
+	e, err := Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return differ{name: name, funcs: p.functions, angle: p.angle}.expr(e.Root)
+}
+
+// differ differentiates syntax trees to the variable name.
+type differ struct {
+	name string
+	// funcs holds user defined functions that get inlined before
+	// differentiating
+	funcs functions
+	angle AngleMode
+}
+
+func (d differ) expr(root Node) (*Expr, error) {
+	if root == nil {
+		return nil, fmt.Errorf("Nothing to differentiate")
+	}
+
+	root, err := d.inline(root, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := d.diff(root)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Expr{Root: simplify(res)}, nil
+}
+
+// inline replaces calls to user defined functions by their body, with the
+// parameters replaced by the arguments.
+func (d differ) inline(n Node, depth int) (Node, error) {
+	if depth > maxInlineDepth {
+		return nil, ErrMaxCallDepth
+	}
+
+	n, err := mapNode(n, func(child Node) (Node, error) {
+		return d.inline(child, depth)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	call, ok := n.(*CallNode)
+	if !ok {
+		return n, nil
+	}
+
+	def := d.funcs[call.Name].def
+	if def == nil {
+		return n, nil
+	}
+	if len(call.Args) != len(def.Params) {
+		return nil, fmt.Errorf("Invalid argument count for '%s' (expected %d, got %d)",
+			call.Name, len(def.Params), len(call.Args))
+	}
+
+	args := make(map[string]Node, len(def.Params))
+	for i, param := range def.Params {
+		args[param] = call.Args[i]
+	}
+
+	return d.inline(substitute(def.Body, args), depth+1)
+}
+
+// binders holds the builtin functions that bind a variable, with the position
+// of the variable and of the expression it's bound in, and their number of
+// arguments.
+var binders = map[string]struct{ bound, body, args int }{
+	"sum":       {0, 3, 4},
+	"prod":      {0, 3, 4},
+	"iterate":   {0, 3, 4},
+	"deriv":     {1, 0, 3},
+	"integrate": {1, 0, 4},
+}
+
+// boundName returns the variable a call to a binder like sum binds, if any.
+func boundName(call *CallNode) (string, bool) {
+	b, ok := binders[call.Name]
+	if !ok || len(call.Args) != b.args {
+		return "", false
+	}
+
+	ident, ok := call.Args[b.bound].(*IdentNode)
+	if !ok {
+		return "", false
+	}
+
+	return ident.Name, true
+}
+
+// mapNode returns a copy of n with f applied to all of its direct children.
+func mapNode(n Node, f func(Node) (Node, error)) (Node, error) {
+	var err error
+
+	switch n := n.(type) {
+	case *UnaryNode:
+		res := &UnaryNode{Op: n.Op}
+		res.Operand, err = f(n.Operand)
+		return res, err
+	case *BinaryNode:
+		res := &BinaryNode{Op: n.Op}
+		if res.Lhs, err = f(n.Lhs); err != nil {
+			return nil, err
+		}
+		res.Rhs, err = f(n.Rhs)
+		return res, err
+	case *CondNode:
+		res := &CondNode{}
+		if res.Cond, err = f(n.Cond); err != nil {
+			return nil, err
+		}
+		if res.Then, err = f(n.Then); err != nil {
+			return nil, err
+		}
+		res.Else, err = f(n.Else)
+		return res, err
+	case *CallNode:
+		res := &CallNode{Name: n.Name, Args: make([]Node, len(n.Args))}
+		for i, arg := range n.Args {
+			if res.Args[i], err = f(arg); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	case *AssignNode:
+		res := &AssignNode{Op: n.Op, Name: n.Name}
+		res.Value, err = f(n.Value)
+		return res, err
+	case *MatrixNode:
+		res := &MatrixNode{Rows: make([][]Node, len(n.Rows))}
+		for i, row := range n.Rows {
+			res.Rows[i] = make([]Node, len(row))
+			for j, elem := range row {
+				if res.Rows[i][j], err = f(elem); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return res, nil
+	}
+
+	return n, nil
+}
+
+// substitute replaces the variables in n by the nodes in vars. Variables bound
+// by functions like sum are left alone.
+func substitute(n Node, vars map[string]Node) Node {
+	if ident, ok := n.(*IdentNode); ok {
+		if val, ok := vars[ident.Name]; ok {
+			return val
+		}
+		return n
+	}
+
+	if call, ok := n.(*CallNode); ok {
+		if bound, ok := boundName(call); ok {
+			if _, shadowed := vars[bound]; shadowed {
+				b := binders[call.Name]
+				inner := make(map[string]Node, len(vars))
+				for k, v := range vars {
+					if k != bound {
+						inner[k] = v
+					}
+				}
+
+				res := &CallNode{Name: call.Name, Args: make([]Node, len(call.Args))}
+				for i, arg := range call.Args {
+					switch i {
+					case b.bound:
+						res.Args[i] = arg
+					case b.body:
+						res.Args[i] = substitute(arg, inner)
+					default:
+						res.Args[i] = substitute(arg, vars)
+					}
+				}
+				return res
+			}
+		}
+	}
+
+	res, _ := mapNode(n, func(child Node) (Node, error) {
+		return substitute(child, vars), nil
+	})
+	return res
+}
+
+// dependsOn reports whether the value of n depends on the variable name.
+func dependsOn(n Node, name string) bool {
+	switch n := n.(type) {
+	case *IdentNode:
+		return n.Name == name
+	case *CallNode:
+		if n.Name == "rand" {
+			return false
+		}
+
+		bound, isBinder := boundName(n)
+		for i, arg := range n.Args {
+			b := binders[n.Name]
+			if isBinder && (i == b.bound || i == b.body && bound == name) {
+				continue
+			}
+			if dependsOn(arg, name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	depends := false
+	mapNode(n, func(child Node) (Node, error) {
+		depends = depends || dependsOn(child, name)
+		return child, nil
+	})
+	return depends
+}
+
+// IsConstant reports whether e doesn't depend on any variables besides the
+// predefined ones, like 2pi + 1 or sum(k, 1, 3, k), so it can be evaluated
+// without knowing any. A function definition depends on its parameters.
+func (e *Expr) IsConstant() bool {
+	root := e.Root
+	if def, ok := root.(*FuncDefNode); ok {
+		if len(def.Params) > 0 {
+			return false
+		}
+		root = def.Body
+	}
+
+	constant := true
+	var visit func(Node) (Node, error)
+	visit = func(n Node) (Node, error) {
+		if ident, ok := n.(*IdentNode); ok {
+			_, predefined := defaultVariables[ident.Name]
+			constant = constant && (predefined || !dependsOn(root, ident.Name))
+			return n, nil
+		}
+		return mapNode(n, visit)
+	}
+	visit(root)
+
+	return constant
+}
+
+// diff returns the derivative of n.
+func (d differ) diff(n Node) (Node, error) {
+	switch n.(type) {
+	case *AssignNode, *FuncDefNode:
+		return nil, fmt.Errorf("Can't differentiate ‘%s’", n)
+	}
+
+	if !dependsOn(n, d.name) {
+		return number(new(big.Rat)), nil
+	}
+
+	switch n := n.(type) {
+	case *IdentNode:
+		return number(big.NewRat(1, 1)), nil
+	case *UnaryNode:
+		switch n.Op.Type {
+		case UnaryMin:
+			du, err := d.diff(n.Operand)
+			if err != nil {
+				return nil, err
+			}
+			return negNode(du), nil
+		case Percent:
+			du, err := d.diff(n.Operand)
+			if err != nil {
+				return nil, err
+			}
+			return divNode(du, number(big.NewRat(100, 1))), nil
+		case LogNot:
+			// Logical operators are constant wherever they're continuous
+			return number(new(big.Rat)), nil
+		}
+	case *BinaryNode:
+		return d.binary(n)
+	case *CondNode:
+		then, err := d.diff(n.Then)
+		if err != nil {
+			return nil, err
+		}
+		els, err := d.diff(n.Else)
+		if err != nil {
+			return nil, err
+		}
+		return &CondNode{Cond: n.Cond, Then: then, Else: els}, nil
+	case *CallNode:
+		return d.call(n)
+	case *MatrixNode:
+		// Matrices are differentiated element by element
+		return mapNode(n, d.diff)
+	}
+
+	return nil, fmt.Errorf("Can't differentiate ‘%s’", n)
+}
+
+func (d differ) binary(n *BinaryNode) (Node, error) {
+	switch n.Op.Type {
+	case NotEq, EqEq, Gt, GtEq, Lt, LtEq, LogAnd, LogOr:
+		return number(new(big.Rat)), nil
+	case Add, Sub, Mul, Div, Pow:
+	default:
+		return nil, fmt.Errorf("Can't differentiate ‘%s’", n)
+	}
+
+	u, v := n.Lhs, n.Rhs
+	du, err := d.diff(u)
+	if err != nil {
+		return nil, err
+	}
+	dv, err := d.diff(v)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.Op.Type {
+	case Add:
+		return addNode(du, dv), nil
+	case Sub:
+		return subNode(du, dv), nil
+	case Mul:
+		// (uv)' = u'v + uv'
+		return addNode(mulNode(du, v), mulNode(u, dv)), nil
+	case Div:
+		// (u / v)' = (u'v - uv') / v^2
+		if !dependsOn(v, d.name) {
+			return divNode(du, v), nil
+		}
+		return divNode(subNode(mulNode(du, v), mulNode(u, dv)), powNode(v, number(big.NewRat(2, 1)))), nil
+	}
+
+	switch {
+	case !dependsOn(v, d.name):
+		// (u^c)' = c * u^(c - 1) * u'
+		return mulNode(mulNode(v, powNode(u, subNode(v, number(big.NewRat(1, 1))))), du), nil
+	case isVar(u, "e"):
+		// (e^v)' = e^v * v'
+		return mulNode(n, dv), nil
+	case !dependsOn(u, d.name):
+		// (c^v)' = c^v * ln(c) * v'
+		return mulNode(mulNode(n, callNode("ln", u)), dv), nil
+	}
+
+	// (u^v)' = u^v * (v' * ln(u) + v * u' / u)
+	return mulNode(n, addNode(mulNode(dv, callNode("ln", u)), divNode(mulNode(v, du), u))), nil
+}
+
+// derivatives holds the derivatives of builtin functions of one argument, as
+// a function of their arguments. Extra arguments, like the 1 of sin(30, 1),
+// are passed on to calls in the derivative.
+var derivatives = map[string]func(args []Node) Node{
+	"exp": func(args []Node) Node { return callNode("exp", args...) },
+	"ln":  func(args []Node) Node { return divNode(one(), args[0]) },
+	"log": func(args []Node) Node {
+		return divNode(one(), mulNode(args[0], callNode("ln", number(big.NewRat(10, 1)))))
+	},
+	"sqrt": func(args []Node) Node {
+		return divNode(one(), mulNode(number(big.NewRat(2, 1)), callNode("sqrt", args...)))
+	},
+	"abs": func(args []Node) Node { return divNode(args[0], callNode("abs", args...)) },
+	"sin": func(args []Node) Node { return callNode("cos", args...) },
+	"cos": func(args []Node) Node { return negNode(callNode("sin", args...)) },
+	"tan": func(args []Node) Node {
+		return powNode(callNode("sec", args...), number(big.NewRat(2, 1)))
+	},
+	"csc": func(args []Node) Node {
+		return negNode(mulNode(callNode("csc", args...), callNode("cot", args...)))
+	},
+	"sec": func(args []Node) Node {
+		return mulNode(callNode("sec", args...), callNode("tan", args...))
+	},
+	"cot": func(args []Node) Node {
+		return negNode(powNode(callNode("csc", args...), number(big.NewRat(2, 1))))
+	},
+	"asin": func(args []Node) Node {
+		return divNode(one(), callNode("sqrt", subNode(one(), square(args[0]))))
+	},
+	"acos": func(args []Node) Node {
+		return negNode(divNode(one(), callNode("sqrt", subNode(one(), square(args[0])))))
+	},
+	"atan": func(args []Node) Node { return divNode(one(), addNode(one(), square(args[0]))) },
+	"acsc": func(args []Node) Node {
+		return negNode(divNode(one(), mulNode(callNode("abs", args[0]),
+			callNode("sqrt", subNode(square(args[0]), one())))))
+	},
+	"asec": func(args []Node) Node {
+		return divNode(one(), mulNode(callNode("abs", args[0]),
+			callNode("sqrt", subNode(square(args[0]), one()))))
+	},
+	"acot": func(args []Node) Node {
+		return negNode(divNode(one(), addNode(one(), square(args[0]))))
+	},
+	"deg2rad": func(args []Node) Node {
+		return divNode(&IdentNode{Name: "pi"}, number(big.NewRat(180, 1)))
+	},
+	"rad2deg": func(args []Node) Node {
+		return divNode(number(big.NewRat(180, 1)), &IdentNode{Name: "pi"})
+	},
+	"ceil":  func(args []Node) Node { return number(new(big.Rat)) },
+	"floor": func(args []Node) Node { return number(new(big.Rat)) },
+}
+
+// angleFuncs and inverseAngleFuncs are the functions that take or return an
+// angle, which changes their derivative in degrees and gradians.
+var (
+	angleFuncs = map[string]bool{
+		"sin": true, "cos": true, "tan": true, "csc": true, "sec": true, "cot": true,
+	}
+	inverseAngleFuncs = map[string]bool{
+		"asin": true, "acos": true, "atan": true, "acsc": true, "asec": true, "acot": true,
+	}
+)
+
+func (d differ) call(n *CallNode) (Node, error) {
+	if len(n.Args) == 0 {
+		return nil, fmt.Errorf("Can't differentiate ‘%s’", n)
+	}
+
+	if derivative, ok := derivatives[n.Name]; ok {
+		du, err := d.diff(n.Args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		res := mulNode(d.angleFactor(n), derivative(n.Args))
+		return mulNode(res, du), nil
+	}
+
+	switch n.Name {
+	case "max", "min", "if":
+		return d.choice(n)
+	case "logn":
+		if len(n.Args) == 2 {
+			// logn(k, x) = ln(x) / ln(k)
+			return d.diff(divNode(callNode("ln", n.Args[1]), callNode("ln", n.Args[0])))
+		}
+	case "sum", "mean":
+		return d.sum(n)
+	case "integrate":
+		return d.integral(n)
+	}
+
+	return nil, fmt.Errorf("Can't differentiate ‘%s’", n)
+}
+
+// angleFactor is the derivative of the conversion of an angle to radians for
+// functions that take an angle, and of the inverse for functions that return
+// one.
+func (d differ) angleFactor(n *CallNode) Node {
+	pi := &IdentNode{Name: "pi"}
+
+	switch {
+	case angleFuncs[n.Name] && len(n.Args) == 2:
+		return divNode(pi, number(big.NewRat(180, 1)))
+	case angleFuncs[n.Name] && d.angle != Radians:
+		return divNode(pi, number(big.NewRat(halfTurns[d.angle], 1)))
+	case inverseAngleFuncs[n.Name] && d.angle != Radians:
+		return divNode(number(big.NewRat(halfTurns[d.angle], 1)), pi)
+	}
+
+	return one()
+}
+
+// choice differentiates functions that pick one of their arguments, like
+// max(a, b) and if(c, a, b).
+func (d differ) choice(n *CallNode) (Node, error) {
+	args := make([]Node, len(n.Args))
+	for i, arg := range n.Args {
+		// The condition of if is kept, not differentiated
+		if n.Name == "if" && i == 0 {
+			args[i] = arg
+			continue
+		}
+
+		darg, err := d.diff(arg)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = darg
+	}
+
+	switch {
+	case n.Name == "if" && len(args) == 3:
+		return callNode("if", args...), nil
+	case n.Name == "max" && len(args) == 2:
+		return &CondNode{Cond: &BinaryNode{Op: op(GtEq), Lhs: n.Args[0], Rhs: n.Args[1]}, Then: args[0], Else: args[1]}, nil
+	case n.Name == "min" && len(args) == 2:
+		return &CondNode{Cond: &BinaryNode{Op: op(LtEq), Lhs: n.Args[0], Rhs: n.Args[1]}, Then: args[0], Else: args[1]}, nil
+	}
+
+	return nil, fmt.Errorf("Can't differentiate ‘%s’", n)
+}
+
+// sum differentiates sum(k, a, b, e) term by term, which only works if the
+// bounds don't depend on the variable. Sums and means of a list of values,
+// like sum(x, x^2) or mean([x, 2x]), are differentiated value by value.
+func (d differ) sum(n *CallNode) (Node, error) {
+	if !isFold(n) {
+		args := make([]Node, len(n.Args))
+		for i, arg := range n.Args {
+			darg, err := d.diff(arg)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = darg
+		}
+		return callNode(n.Name, args...), nil
+	}
+
+	if dependsOn(n.Args[1], d.name) || dependsOn(n.Args[2], d.name) {
+		return nil, fmt.Errorf("Can't differentiate ‘%s’", n)
+	}
+
+	de, err := d.diff(n.Args[3])
+	if err != nil {
+		return nil, err
+	}
+
+	return callNode("sum", n.Args[0], n.Args[1], n.Args[2], de), nil
+}
+
+// integral differentiates integrate(e, t, a, b) with the fundamental theorem
+// of calculus: e(b) * b' - e(a) * a' + integrate(e', t, a, b).
+func (d differ) integral(n *CallNode) (Node, error) {
+	bound, ok := boundName(n)
+	if !ok || len(n.Args) != 4 {
+		return nil, fmt.Errorf("Can't differentiate ‘%s’", n)
+	}
+	e, a, b := n.Args[0], n.Args[2], n.Args[3]
+
+	da, err := d.diff(a)
+	if err != nil {
+		return nil, err
+	}
+	db, err := d.diff(b)
+	if err != nil {
+		return nil, err
+	}
+
+	res := subNode(
+		mulNode(substitute(e, map[string]Node{bound: b}), db),
+		mulNode(substitute(e, map[string]Node{bound: a}), da),
+	)
+
+	if bound != d.name && dependsOn(e, d.name) {
+		de, err := d.diff(e)
+		if err != nil {
+			return nil, err
+		}
+		res = addNode(res, callNode("integrate", de, n.Args[1], a, b))
+	}
+
+	return res, nil
+}
+
+// The functions below build nodes for derivatives, leaving out the terms that
+// vanish like 0 + a, 1 * a and a ^ 1 so the results stay readable.
+
+func op(t TokenType) *Token {
+	return &Token{Type: t, Value: t.String()}
+}
+
+// number returns a node for x, as a division for fractions and a negation for
+// negative numbers so it prints as something that can be parsed again.
+func number(x *big.Rat) Node {
+	if x.Sign() < 0 {
+		return &UnaryNode{Op: op(UnaryMin), Operand: number(new(big.Rat).Neg(x))}
+	}
+
+	if !x.IsInt() {
+		return &BinaryNode{
+			Op:  op(Div),
+			Lhs: number(new(big.Rat).SetInt(x.Num())),
+			Rhs: number(new(big.Rat).SetInt(x.Denom())),
+		}
+	}
+
+	return &LiteralNode{Tok: &Token{Type: Decimal, Value: x.RatString()}, Value: new(big.Rat).Set(x)}
+}
+
+func one() Node {
+	return number(big.NewRat(1, 1))
+}
+
+// numberValue returns the value of a node built by number, or a literal.
+func numberValue(n Node) (*big.Rat, bool) {
+	switch n := n.(type) {
+	case *LiteralNode:
+		return n.Value, true
+	case *UnaryNode:
+		if n.Op.Is(UnaryMin) {
+			if x, ok := numberValue(n.Operand); ok {
+				return new(big.Rat).Neg(x), true
+			}
+		}
+	case *BinaryNode:
+		if n.Op.Is(Div) {
+			num, ok1 := n.Lhs.(*LiteralNode)
+			den, ok2 := n.Rhs.(*LiteralNode)
+			if ok1 && ok2 && den.Value.Sign() != 0 {
+				return new(big.Rat).Quo(num.Value, den.Value), true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+func isConst(n Node, x int64) bool {
+	val, ok := numberValue(n)
+	return ok && val.Cmp(big.NewRat(x, 1)) == 0
+}
+
+func isVar(n Node, name string) bool {
+	ident, ok := n.(*IdentNode)
+	return ok && ident.Name == name
+}
+
+func addNode(a, b Node) Node {
+	x, xok := numberValue(a)
+	y, yok := numberValue(b)
+
+	switch {
+	case xok && yok:
+		return number(new(big.Rat).Add(x, y))
+	case xok && x.Sign() == 0:
+		return b
+	case yok && y.Sign() == 0:
+		return a
+	case yok && y.Sign() < 0:
+		return subNode(a, number(new(big.Rat).Neg(y)))
+	}
+
+	if neg, ok := b.(*UnaryNode); ok && neg.Op.Is(UnaryMin) {
+		return subNode(a, neg.Operand)
+	}
+
+	return &BinaryNode{Op: op(Add), Lhs: a, Rhs: b}
+}
+
+func subNode(a, b Node) Node {
+	x, xok := numberValue(a)
+	y, yok := numberValue(b)
+
+	switch {
+	case xok && yok:
+		return number(new(big.Rat).Sub(x, y))
+	case yok && y.Sign() == 0:
+		return a
+	case xok && x.Sign() == 0:
+		return negNode(b)
+	case yok && y.Sign() < 0:
+		return addNode(a, number(new(big.Rat).Neg(y)))
+	}
+
+	if neg, ok := b.(*UnaryNode); ok && neg.Op.Is(UnaryMin) {
+		return addNode(a, neg.Operand)
+	}
+
+	return &BinaryNode{Op: op(Sub), Lhs: a, Rhs: b}
+}
+
+func mulNode(a, b Node) Node {
+	x, xok := numberValue(a)
+	y, yok := numberValue(b)
+
+	switch {
+	case xok && yok:
+		return number(new(big.Rat).Mul(x, y))
+	case xok && x.Sign() == 0, yok && y.Sign() == 0:
+		return number(new(big.Rat))
+	case isConst(a, 1):
+		return b
+	case isConst(b, 1):
+		return a
+	case isConst(a, -1):
+		return negNode(b)
+	case isConst(b, -1):
+		return negNode(a)
+	case yok:
+		// Constants go in front, 2 * x rather than x * 2
+		return mulNode(b, a)
+	}
+
+	// -a * b = -(a * b)
+	if neg, ok := a.(*UnaryNode); ok && neg.Op.Is(UnaryMin) {
+		return negNode(mulNode(neg.Operand, b))
+	}
+	if neg, ok := b.(*UnaryNode); ok && neg.Op.Is(UnaryMin) {
+		return negNode(mulNode(a, neg.Operand))
+	}
+
+	// 2 * (3 * x) = 6 * x
+	if inner, ok := b.(*BinaryNode); ok && xok && inner.Op.Is(Mul) {
+		if z, ok := numberValue(inner.Lhs); ok {
+			return mulNode(number(new(big.Rat).Mul(x, z)), inner.Rhs)
+		}
+	}
+
+	return &BinaryNode{Op: op(Mul), Lhs: a, Rhs: b}
+}
+
+func divNode(a, b Node) Node {
+	x, xok := numberValue(a)
+	y, yok := numberValue(b)
+
+	switch {
+	case xok && yok && y.Sign() != 0:
+		return number(new(big.Rat).Quo(x, y))
+	case xok && x.Sign() == 0:
+		return number(new(big.Rat))
+	case isConst(b, 1):
+		return a
+	case isConst(b, -1):
+		return negNode(a)
+	}
+
+	if neg, ok := a.(*UnaryNode); ok && neg.Op.Is(UnaryMin) {
+		return negNode(divNode(neg.Operand, b))
+	}
+
+	return &BinaryNode{Op: op(Div), Lhs: a, Rhs: b}
+}
+
+func powNode(a, b Node) Node {
+	switch {
+	case isConst(b, 0):
+		return one()
+	case isConst(b, 1):
+		return a
+	}
+
+	return &BinaryNode{Op: op(Pow), Lhs: a, Rhs: b}
+}
+
+func square(a Node) Node {
+	return powNode(a, number(big.NewRat(2, 1)))
+}
+
+func negNode(a Node) Node {
+	if x, ok := numberValue(a); ok {
+		return number(new(big.Rat).Neg(x))
+	}
+
+	if neg, ok := a.(*UnaryNode); ok && neg.Op.Is(UnaryMin) {
+		return neg.Operand
+	}
+
+	return &UnaryNode{Op: op(UnaryMin), Operand: a}
+}
+
+func callNode(name string, args ...Node) Node {
+	return &CallNode{Name: name, Args: args}
+}
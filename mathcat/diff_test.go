@@ -0,0 +1,162 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	derivatives := map[string]string{
+		"5":                       "0",
+		"y * x":                   "y",
+		"x ^ 2":                   "2 * x",
+		"3x^2 + 2x + 1":           "6 * x + 2",
+		"x ^ 2 * sin(x)":          "2 * x * sin(x) + x ^ 2 * cos(x)",
+		"[x ^ 2, 1; 3x, sin(x)]":  "[2 * x, 0; 3, cos(x)]",
+		"sum(x, x ^ 2, 3)":        "sum(1, 2 * x, 0)",
+		"sum([x, 1, 2, 3])":       "sum([1, 0, 0, 0])",
+		"sum(x, 1, 2, 3)":         "0",
+		"mean([x, x ^ 2])":        "mean([1, 2 * x])",
+		"sin(x) / x":              "(cos(x) * x - sin(x)) / x ^ 2",
+		"e ^ x":                   "e ^ x",
+		"2 ^ x":                   "2 ^ x * ln(2)",
+		"ln(x)":                   "1 / x",
+		"1 / x":                   "-1 / x ^ 2",
+		"sin(cos(x))":             "-cos(cos(x)) * sin(x)",
+		"(x + 1) ^ 3":             "3 * (x + 1) ^ 2",
+		"x < 0 ? -x : x ^ 2":      "x < 0 ? -1 : 2 * x",
+		"sum(k, 1, 3, x ^ k)":     "sum(k, 1, 3, k * x ^ (k - 1))",
+		"integrate(t, t, 0, x^2)": "2 * x ^ 3",
+		"sin(x, 1)":               "pi * cos(x, 1) / 180",
+	}
+
+	for expr, expected := range derivatives {
+		res, err := Diff(expr, "x")
+		if err != nil {
+			t.Errorf("unexpected error differentiating '%s': %s", expr, err)
+			continue
+		}
+
+		if res.String() != expected {
+			t.Errorf("wrong derivative of '%s' (expected '%s', got '%s')", expr, expected, res)
+		}
+	}
+
+	// Compare the derivative with the numeric one at a few points
+	functions := []string{
+		"x ^ x", "sqrt(x)", "tan(x)", "asin(x / 4)", "acos(x / 4)", "atan(2x)",
+		"csc(x)", "sec(x)", "cot(x)", "acsc(x + 3)", "asec(x + 3)", "acot(x)",
+		"log(x)", "logn(2, x)", "abs(x - 1)", "exp(-x ^ 2)", "x ^ (1 / 3)",
+		"1 / x", "max(x, 2) * x", "min(x, 2)", "if(x > 2, x ^ 2, -x)", "50% * x",
+		"deg2rad(x ^ 2)", "rad2deg(x)", "2 ^ sin(x)", "floor(x) + x", "sin(x) ^ cos(x)",
+		"integrate(sin(t * x), t, 0, x)", "sum(k, 0, 4, x ^ k / k!)",
+	}
+
+	for _, expr := range functions {
+		res, err := Diff(expr, "x")
+		if err != nil {
+			t.Errorf("unexpected error differentiating '%s': %s", expr, err)
+			continue
+		}
+
+		for _, x := range []float64{0.3, 1.7, 2.5} {
+			xRat := new(big.Rat).SetFloat64(x)
+			expected, err := Exec("deriv("+expr+", x, a)", map[string]*big.Rat{"a": xRat})
+			if err != nil {
+				t.Errorf("unexpected error in numeric derivative of '%s': %s", expr, err)
+				continue
+			}
+
+			// The printed derivative has to parse back to the same function
+			got, err := Exec(res.String(), map[string]*big.Rat{"x": xRat})
+			if err != nil {
+				t.Errorf("unexpected error evaluating '%s': %s", res, err)
+				continue
+			}
+
+			e, _ := expected.Float64()
+			g, _ := got.Float64()
+			if math.Abs(e-g) > 1e-6*math.Max(1, math.Abs(e)) {
+				t.Errorf("wrong derivative '%s' of '%s' at x = %g (expected %g, got %g)",
+					res, expr, x, e, g)
+			}
+		}
+	}
+
+	badExpressions := []string{
+		"x!", "fact(x)", "x % 3", "x & 1", "a = x", "f(x) = x", "foo(x)", "gcd(x, 2)",
+		"prod(k, 1, 3, x)", "sum(k, 1, x, k)", "deriv(x ^ 2, x, x)", "re(x)", "",
+	}
+
+	for _, expr := range badExpressions {
+		if _, err := Diff(expr, "x"); err == nil {
+			t.Errorf("no error differentiating bad expression '%s'", expr)
+		}
+	}
+}
+
+func TestParserDiff(t *testing.T) {
+	p := New()
+
+	if _, err := p.Run("f(t) = t ^ 3 + a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Run("g(x) = f(2x)"); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := p.Diff("g(x) + sum(x, 1, 2, x)", "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.String() != "24 * x ^ 2" {
+		t.Errorf("wrong derivative of g(x) '%s'", res)
+	}
+
+	if _, err := p.Run("r(x) = r(x) + 1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Diff("r(x)", "x"); err == nil {
+		t.Errorf("no error differentiating recursive function")
+	}
+
+	p.SetAngleMode(Degrees)
+	res, err = p.Diff("sin(x) + atan(x)", "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.String() != "pi * cos(x) / 180 + 180 / (pi * (1 + x ^ 2))" {
+		t.Errorf("wrong derivative in degrees '%s'", res)
+	}
+}
+
+func TestIsConstant(t *testing.T) {
+	exprs := map[string]bool{
+		"1 + 2":            true,
+		"2pi + e ^ i":      true,
+		"sum(k, 1, 3, k)":  true,
+		"deriv(x^2, x, 3)": true,
+		"rand()":           true,
+		"2x + x":           false,
+		"sum(k, 1, n, k)":  false,
+		"a = 3":            true,
+		"a = b":            false,
+		"f(x) = 2":         false,
+		"[1, 2; y, 4]":     false,
+	}
+
+	for expr, expected := range exprs {
+		e, err := Compile(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.IsConstant() != expected {
+			t.Errorf("wrong IsConstant of '%s' (expected %t)", expr, expected)
+		}
+	}
+}
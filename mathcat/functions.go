@@ -5,6 +5,7 @@
 package mathcat
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"math/big"
@@ -13,232 +14,575 @@ import (
 
 type function struct {
 	arity int
-	fn    func(args []*big.Rat) *big.Rat
+	fn    func(p *Parser, args []*big.Rat) (*big.Rat, error)
+	// cfn is used instead of fn by functions that take complex arguments
+	cfn func(p *Parser, args []*Complex) (*Complex, error)
+	// matrix is set for functions whose cfn takes matrices, like det. Other
+	// functions are applied to every element of a matrix.
+	matrix bool
+	// lazy is used by functions that get their arguments unevaluated, like
+	// if(cond, a, b)
+	lazy func(p *Parser, args []Node) (*Complex, error)
+	// def is the definition of a function defined in an expression
+	def *FuncDefNode
 }
 
 type functions map[string]function
 
+// maxCallDepth limits how deep user defined functions can call each other, so
+// recursive definitions like f(x) = f(x) fail instead of exhausting the stack.
+const maxCallDepth = 1000
+
 // FunctionNames holds all the function names that are available for use
 var FunctionNames []string
 
 var funcs = make(functions)
 
 func (f functions) register(name string, function function) {
-	FunctionNames = append(FunctionNames, name)
+	if _, ok := f[name]; !ok {
+		FunctionNames = append(FunctionNames, name)
+	}
 	f[name] = function
 }
 
+// RegisterFunction adds a function to the builtin functions available to all
+// parsers, or replaces an existing one. An arity of -1 allows any number of
+// arguments. Errors returned by fn are returned from the expression being
+// evaluated.
+//
+// Example:
+//
+//	mathcat.RegisterFunction("double", 1, func(args []*big.Rat) (*big.Rat, error) {
+//	    return new(big.Rat).Mul(args[0], big.NewRat(2, 1)), nil
+//	})
+//	res, err := mathcat.Eval("double(21)") // 42
+func RegisterFunction(name string, arity int, fn func(args []*big.Rat) (*big.Rat, error)) {
+	checkFunction(name, arity)
+	funcs.register(name, function{arity: arity, fn: wrap(fn)})
+}
+
+// RegisterFunction adds a function to this parser only, leaving other parsers
+// and the builtin functions untouched. Functions registered on the parser take
+// precedence over builtin functions with the same name.
+func (p *Parser) RegisterFunction(name string, arity int, fn func(args []*big.Rat) (*big.Rat, error)) {
+	checkFunction(name, arity)
+	p.functions[name] = function{arity: arity, fn: wrap(fn)}
+}
+
+// wrap adapts a function registered by a host program, which doesn't need the
+// parser evaluating it.
+func wrap(fn func(args []*big.Rat) (*big.Rat, error)) func(*Parser, []*big.Rat) (*big.Rat, error) {
+	return func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+		return fn(args)
+	}
+}
+
+func checkFunction(name string, arity int) {
+	if name == "" || !IsValidIdent(name) {
+		panic(fmt.Sprintf("mathcat: invalid function name ‘%s’", name))
+	}
+	if arity < -1 {
+		panic(fmt.Sprintf("mathcat: invalid arity %d for ‘%s’", arity, name))
+	}
+}
+
+// maxFloatExp limits the magnitude of results computed with big.Float, since
+// converting something like e^(10^9) to a rational number takes gigabytes.
+const maxFloatExp = 1 << 16
+
+// ratFromFloat converts the float64 result of a function to a rational
+// number. big.Rat can't hold NaN or infinity, so those become an error.
+func ratFromFloat(name string, f float64) (*big.Rat, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("%s result is not a finite number", name)
+	}
+
+	return new(big.Rat).SetFloat64(f), nil
+}
+
+// approx evaluates a function that can't be computed exactly, with float64 if
+// the parser has no precision set or with big.Float at its precision otherwise.
+func (p *Parser) approx(name string, x *big.Rat, f func(float64) float64,
+	bigf func(*big.Float) *big.Float) (*big.Rat, error) {
+	if p.prec == 0 {
+		float, _ := x.Float64()
+		return ratFromFloat(name, f(float))
+	}
+
+	res := bigf(p.float(x)).SetPrec(p.prec)
+	if res.IsInf() || res.MantExp(nil) > maxFloatExp {
+		return nil, fmt.Errorf("%s result is not a finite number", name)
+	}
+
+	rat, _ := res.Rat(nil)
+	return rat, nil
+}
+
+// float converts x to a big.Float at the parser's precision, plus the bits
+// needed for the integer part of x.
+func (p *Parser) float(x *big.Rat) *big.Float {
+	prec := p.prec
+	if bits := x.Num().BitLen() - x.Denom().BitLen(); bits > 0 {
+		prec += uint(bits)
+	}
+
+	return newFloat(prec).SetRat(x)
+}
+
+// isZero reports whether the result of a function that can't be computed
+// exactly is indistinguishable from zero, like sin(pi).
+func (p *Parser) isZero(x *big.Rat) bool {
+	return new(big.Rat).Abs(x).Cmp(p.epsilon()) < 0
+}
+
+// epsilon is the error results that can't be computed exactly are assumed to
+// have.
+func (p *Parser) epsilon() *big.Rat {
+	if p.prec == 0 {
+		return new(big.Rat).SetFloat64(1e-10)
+	}
+
+	return new(big.Rat).SetFrac(big.NewInt(1), new(big.Int).Lsh(big.NewInt(1), p.prec-10))
+}
+
+// pi returns pi at the parser's precision.
+func (p *Parser) pi() *big.Rat {
+	if p.prec == 0 {
+		return defaultVariables["pi"].Re
+	}
+
+	return p.constants["pi"].Re
+}
+
+// angleArg returns the angle passed to a trigonometric function in radians,
+// converted from the parser's angle mode. The angle is always in degrees if a
+// second argument is passed, like sin(30, 1).
+func (p *Parser) angleArg(name string, args []*big.Rat) (*big.Rat, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("Invalid argument count for '%s' (expected 1 or 2, got %d)",
+			name, len(args))
+	}
+
+	if len(args) == 2 {
+		return degToRad(args[0], p.pi()), nil
+	}
+
+	return p.toRadians(args[0]), nil
+}
+
+// halfTurns holds the size of a half turn, pi radians, in each angle mode other
+// than radians.
+var halfTurns = map[AngleMode]int64{
+	Degrees:  180,
+	Gradians: 200,
+}
+
+// toRadians converts an angle in the parser's angle mode to radians.
+func (p *Parser) toRadians(x *big.Rat) *big.Rat {
+	if p.angle == Radians {
+		return x
+	}
+
+	res := new(big.Rat).Mul(x, p.pi())
+	return res.Quo(res, big.NewRat(halfTurns[p.angle], 1))
+}
+
+// fromRadians converts an angle in radians to the parser's angle mode.
+func (p *Parser) fromRadians(x *big.Rat) *big.Rat {
+	if p.angle == Radians {
+		return x
+	}
+
+	res := new(big.Rat).Mul(x, big.NewRat(halfTurns[p.angle], 1))
+	return res.Quo(res, p.pi())
+}
+
+// inverseTrig evaluates an inverse trigonometric function and converts the
+// resulting angle to the parser's angle mode.
+func (p *Parser) inverseTrig(name string, x *big.Rat, f func(float64) float64,
+	bigf func(*big.Float) *big.Float) (*big.Rat, error) {
+	res, err := p.approx(name, x, f, bigf)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.fromRadians(res), nil
+}
+
+func degToRad(x, pi *big.Rat) *big.Rat {
+	res := new(big.Rat).Mul(x, pi)
+	return res.Quo(res, big.NewRat(180, 1))
+}
+
+// checkUnit returns an error if x is outside of [-1, 1].
+func checkUnit(name string, x *big.Rat) error {
+	if x.Cmp(big.NewRat(-1, 1)) < 0 || x.Cmp(big.NewRat(1, 1)) > 0 {
+		return fmt.Errorf("%s argument outside of [-1, 1]", name)
+	}
+
+	return nil
+}
+
 func init() {
 	funcs.register("abs", function{
 		arity: 1,
-		fn: func(args []*big.Rat) *big.Rat {
-			return new(big.Rat).Abs(args[0])
+		cfn: func(p *Parser, args []*Complex) (*Complex, error) {
+			abs, err := p.abs(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return realComplex(abs), nil
 		},
 	})
 	funcs.register("ceil", function{
 		arity: 1,
-		fn: func(args []*big.Rat) *big.Rat {
-			return Ceil(args[0])
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			return Ceil(args[0]), nil
 		},
 	})
 	funcs.register("floor", function{
 		arity: 1,
-		fn: func(args []*big.Rat) *big.Rat {
-			return Floor(args[0])
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			return Floor(args[0]), nil
 		},
 	})
 	funcs.register("sin", function{
 		arity: -1,
-		fn: func(args []*big.Rat) *big.Rat {
-			if len(args) < 1 || len(args) > 2 {
-				return new(big.Rat).SetFloat64(math.NaN())
-			}
-			float, _ := args[0].Float64()
-			if len(args) == 2 {
-				return new(big.Rat).SetFloat64(math.Sin(float * (math.Pi / 180)))
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			angle, err := p.angleArg("sin", args)
+			if err != nil {
+				return nil, err
 			}
-			return new(big.Rat).SetFloat64(math.Sin(float))
+			return p.approx("sin", angle, math.Sin, bigSin)
 		},
 	})
 	funcs.register("cos", function{
 		arity: -1,
-		fn: func(args []*big.Rat) *big.Rat {
-			if len(args) < 1 || len(args) > 2 {
-				return new(big.Rat).SetFloat64(math.NaN())
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			angle, err := p.angleArg("cos", args)
+			if err != nil {
+				return nil, err
 			}
-			float, _ := args[0].Float64()
-			if len(args) == 2 {
-				return new(big.Rat).SetFloat64(math.Cos(float * (math.Pi / 180)))
-			}
-			return new(big.Rat).SetFloat64(math.Cos(float))
+			return p.approx("cos", angle, math.Cos, bigCos)
 		},
 	})
 	funcs.register("tan", function{
 		arity: -1,
-		fn: func(args []*big.Rat) *big.Rat {
-			if len(args) < 1 || len(args) > 2 {
-				return new(big.Rat).SetFloat64(math.NaN())
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			angle, err := p.angleArg("tan", args)
+			if err != nil {
+				return nil, err
+			}
+			cos, err := p.approx("tan", angle, math.Cos, bigCos)
+			if err != nil {
+				return nil, err
 			}
-			float, _ := args[0].Float64()
-			if len(args) == 2 {
-				return new(big.Rat).SetFloat64(math.Tan(float * (math.Pi / 180)))
+			if p.isZero(cos) {
+				return nil, errors.New("tan undefined at odd multiples of pi/2")
 			}
-			return new(big.Rat).SetFloat64(math.Tan(float))
+			return p.approx("tan", angle, math.Tan, bigTan)
 		},
 	})
 	funcs.register("asin", function{
 		arity: 1,
-		fn: func(args []*big.Rat) *big.Rat {
-			float, _ := args[0].Float64()
-			return new(big.Rat).SetFloat64(math.Asin(float))
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			if err := checkUnit("asin", args[0]); err != nil {
+				return nil, err
+			}
+			return p.inverseTrig("asin", args[0], math.Asin, bigAsin)
 		},
 	})
 	funcs.register("acos", function{
 		arity: 1,
-		fn: func(args []*big.Rat) *big.Rat {
-			float, _ := args[0].Float64()
-			return new(big.Rat).SetFloat64(math.Acos(float))
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			if err := checkUnit("acos", args[0]); err != nil {
+				return nil, err
+			}
+			return p.inverseTrig("acos", args[0], math.Acos, bigAcos)
 		},
 	})
 	funcs.register("atan", function{
 		arity: 1,
-		fn: func(args []*big.Rat) *big.Rat {
-			float, _ := args[0].Float64()
-			return new(big.Rat).SetFloat64(math.Atan(float))
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			return p.inverseTrig("atan", args[0], math.Atan, bigAtan)
+		},
+	})
+	funcs.register("exp", function{
+		arity: 1,
+		cfn: func(p *Parser, args []*Complex) (*Complex, error) {
+			return p.exp(args[0])
 		},
 	})
 	funcs.register("ln", function{
 		arity: 1,
-		fn: func(args []*big.Rat) *big.Rat {
-			float, _ := args[0].Float64()
-			return new(big.Rat).SetFloat64(math.Log(float))
+		cfn: func(p *Parser, args []*Complex) (*Complex, error) {
+			return p.ln(args[0])
 		},
 	})
 	funcs.register("log", function{
 		arity: 1,
-		fn: func(args []*big.Rat) *big.Rat {
-			float, _ := args[0].Float64()
-			return new(big.Rat).SetFloat64(math.Log10(float))
+		cfn: func(p *Parser, args []*Complex) (*Complex, error) {
+			if args[0].IsReal() && args[0].Re.Sign() > 0 {
+				res, err := p.approx("log", args[0].Re, math.Log10, func(x *big.Float) *big.Float {
+					ln10 := bigLog(newFloat(x.Prec()).SetInt64(10))
+					return ln10.Quo(bigLog(x), ln10)
+				})
+				if err != nil {
+					return nil, err
+				}
+				return realComplex(res), nil
+			}
+
+			if args[0].Re.Sign() == 0 && args[0].IsReal() {
+				return nil, errors.New("log of zero")
+			}
+			ln, err := p.ln(args[0])
+			if err != nil {
+				return nil, err
+			}
+			ln10, err := p.approx("log", big.NewRat(10, 1), math.Log, bigLog)
+			if err != nil {
+				return nil, err
+			}
+			return ln.quo(realComplex(ln10))
 		},
 	})
 	funcs.register("logn", function{
 		arity: 2,
-		fn: func(args []*big.Rat) *big.Rat {
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			if args[0].Sign() <= 0 || args[0].Cmp(big.NewRat(1, 1)) == 0 {
+				return nil, errors.New("logn base has to be positive and not 1")
+			}
+			if args[1].Sign() <= 0 {
+				return nil, errors.New("logn of non-positive number")
+			}
 			base, _ := args[0].Float64()
-			arg, _ := args[1].Float64()
-			return new(big.Rat).SetFloat64(math.Log10(arg) / math.Log10(base))
-		},
-	})
-	funcs.register("max", function{
-		arity: 2,
-		fn: func(args []*big.Rat) *big.Rat {
-			return Max(args[0], args[1])
-		},
-	})
-	funcs.register("min", function{
-		arity: 2,
-		fn: func(args []*big.Rat) *big.Rat {
-			return Min(args[0], args[1])
+			return p.approx("logn", args[1], func(x float64) float64 {
+				return math.Log10(x) / math.Log10(base)
+			}, func(x *big.Float) *big.Float {
+				lnBase := bigLog(p.float(args[0]).SetPrec(x.Prec()))
+				return lnBase.Quo(bigLog(x), lnBase)
+			})
 		},
 	})
 	funcs.register("sqrt", function{
 		arity: 1,
-		fn: func(args []*big.Rat) *big.Rat {
-			float, _ := args[0].Float64()
-			return new(big.Rat).SetFloat64(math.Sqrt(float))
+		cfn: func(p *Parser, args []*Complex) (*Complex, error) {
+			return p.csqrt(args[0])
 		},
 	})
 	funcs.register("rand", function{
 		arity: 0,
-		fn: func(_ []*big.Rat) *big.Rat {
-			return new(big.Rat).SetFloat64(rand.Float64())
+		fn: func(_ *Parser, _ []*big.Rat) (*big.Rat, error) {
+			return new(big.Rat).SetFloat64(rand.Float64()), nil
 		},
 	})
 	funcs.register("fact", function{
 		arity: 1,
-		fn: func(args []*big.Rat) *big.Rat {
-			return Factorial(args[0])
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			if args[0].Sign() < 0 {
+				return nil, errors.New("fact of negative number")
+			}
+			return Factorial(args[0]), nil
 		},
 	})
 	funcs.register("gcd", function{
 		arity: 2,
-		fn: func(args []*big.Rat) *big.Rat {
-			return Gcd(args[0], args[1])
+		fn: func(_ *Parser, args []*big.Rat) (*big.Rat, error) {
+			return Gcd(args[0], args[1]), nil
 		},
 	})
 	funcs.register("list", function{
 		arity: 0,
-		fn: func(_ []*big.Rat) *big.Rat {
+		fn: func(_ *Parser, _ []*big.Rat) (*big.Rat, error) {
 			for _, name := range FunctionNames {
 				fmt.Print(name + " ")
 			}
 			fmt.Println()
-			return RatTrue
+			return RatTrue, nil
+		},
+	})
+
+	funcs.register("if", function{
+		arity: 3,
+		lazy: func(p *Parser, args []Node) (*Complex, error) {
+			return p.evaluate(&CondNode{Cond: args[0], Then: args[1], Else: args[2]})
+		},
+	})
+	// sum and prod either bind a variable, like sum(k, 1, n, k^2), or add up
+	// or multiply a list of values, like sum(1, 2, 3) or sum([1, 2, 3])
+	funcs.register("sum", function{
+		arity: -1,
+		lazy: func(p *Parser, args []Node) (*Complex, error) {
+			if isFold(&CallNode{Name: "sum", Args: args}) {
+				return p.fold("sum", args, realComplex(new(big.Rat)), Add)
+			}
+			return p.aggregate("sum", args, realComplex(new(big.Rat)), (*Complex).add)
+		},
+	})
+	funcs.register("prod", function{
+		arity: -1,
+		lazy: func(p *Parser, args []Node) (*Complex, error) {
+			if isFold(&CallNode{Name: "prod", Args: args}) {
+				return p.fold("prod", args, realComplex(big.NewRat(1, 1)), Mul)
+			}
+			return p.aggregate("prod", args, realComplex(big.NewRat(1, 1)), (*Complex).mul)
+		},
+	})
+	funcs.register("iterate", function{
+		arity: 4,
+		lazy: func(p *Parser, args []Node) (*Complex, error) {
+			return p.iterate(args)
+		},
+	})
+	funcs.register("deriv", function{
+		arity: 3,
+		lazy: func(p *Parser, args []Node) (*Complex, error) {
+			return p.deriv(args)
+		},
+	})
+	funcs.register("integrate", function{
+		arity: 4,
+		lazy: func(p *Parser, args []Node) (*Complex, error) {
+			return p.integrate(args)
+		},
+	})
+
+	// complex numbers
+	funcs.register("re", function{
+		arity: 1,
+		cfn: func(_ *Parser, args []*Complex) (*Complex, error) {
+			return realComplex(args[0].Re), nil
+		},
+	})
+	funcs.register("im", function{
+		arity: 1,
+		cfn: func(_ *Parser, args []*Complex) (*Complex, error) {
+			return realComplex(args[0].Im), nil
+		},
+	})
+	funcs.register("conj", function{
+		arity: 1,
+		cfn: func(_ *Parser, args []*Complex) (*Complex, error) {
+			return args[0].conj(), nil
+		},
+	})
+	funcs.register("arg", function{
+		arity: 1,
+		cfn: func(p *Parser, args []*Complex) (*Complex, error) {
+			if args[0].Re.Sign() == 0 && args[0].IsReal() {
+				return nil, errors.New("arg of zero")
+			}
+			arg, err := p.arg(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return realComplex(p.fromRadians(arg)), nil
 		},
 	})
 
 	// custom functions
 	funcs.register("csc", function{
 		arity: -1,
-		fn: func(args []*big.Rat) *big.Rat {
-			if len(args) < 1 || len(args) > 2 {
-				return new(big.Rat).SetFloat64(math.NaN())
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			angle, err := p.angleArg("csc", args)
+			if err != nil {
+				return nil, err
 			}
-			float, _ := args[0].Float64()
-			var sinVal float64
-			if len(args) == 2 {
-				sinVal = math.Sin(float * (math.Pi / 180))
-			} else {
-				sinVal = math.Sin(float)
+			sin, err := p.approx("csc", angle, math.Sin, bigSin)
+			if err != nil {
+				return nil, err
 			}
-			if math.Abs(sinVal) < 1e-10 {
-				return new(big.Rat).SetFloat64(math.NaN())
+			if p.isZero(sin) {
+				return nil, errors.New("csc undefined at multiples of pi")
 			}
-			return new(big.Rat).SetFloat64(1 / sinVal)
+			return sin.Inv(sin), nil
 		},
 	})
 
 	funcs.register("sec", function{
 		arity: -1,
-		fn: func(args []*big.Rat) *big.Rat {
-			if len(args) < 1 || len(args) > 2 {
-				return new(big.Rat).SetFloat64(math.NaN())
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			angle, err := p.angleArg("sec", args)
+			if err != nil {
+				return nil, err
 			}
-			float, _ := args[0].Float64()
-			if len(args) == 2 {
-				return new(big.Rat).SetFloat64(1 / math.Cos(float*(math.Pi/180)))
+			cos, err := p.approx("sec", angle, math.Cos, bigCos)
+			if err != nil {
+				return nil, err
 			}
-			return new(big.Rat).SetFloat64(1 / math.Cos(float))
+			if p.isZero(cos) {
+				return nil, errors.New("sec undefined at odd multiples of pi/2")
+			}
+			return cos.Inv(cos), nil
 		},
 	})
 
 	funcs.register("cot", function{
 		arity: -1,
-		fn: func(args []*big.Rat) *big.Rat {
-			if len(args) < 1 || len(args) > 2 {
-				return new(big.Rat).SetFloat64(math.NaN())
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			angle, err := p.angleArg("cot", args)
+			if err != nil {
+				return nil, err
+			}
+			sin, err := p.approx("cot", angle, math.Sin, bigSin)
+			if err != nil {
+				return nil, err
+			}
+			if p.isZero(sin) {
+				return nil, errors.New("cot undefined at multiples of pi")
 			}
-			float, _ := args[0].Float64()
-			if len(args) == 2 {
-				return new(big.Rat).SetFloat64(1 / math.Tan(float*(math.Pi/180)))
+			cos, err := p.approx("cot", angle, math.Cos, bigCos)
+			if err != nil {
+				return nil, err
+			}
+			return cos.Quo(cos, sin), nil
+		},
+	})
+
+	funcs.register("acsc", function{
+		arity: 1,
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			if new(big.Rat).Abs(args[0]).Cmp(big.NewRat(1, 1)) < 0 {
+				return nil, errors.New("acsc argument inside of (-1, 1)")
+			}
+			return p.inverseTrig("acsc", new(big.Rat).Inv(args[0]), math.Asin, bigAsin)
+		},
+	})
+
+	funcs.register("asec", function{
+		arity: 1,
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			if new(big.Rat).Abs(args[0]).Cmp(big.NewRat(1, 1)) < 0 {
+				return nil, errors.New("asec argument inside of (-1, 1)")
+			}
+			return p.inverseTrig("asec", new(big.Rat).Inv(args[0]), math.Acos, bigAcos)
+		},
+	})
+
+	// acot(x) = atan(1 / x), or pi/2 for x = 0
+	funcs.register("acot", function{
+		arity: 1,
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			if args[0].Sign() == 0 {
+				return p.fromRadians(new(big.Rat).Mul(p.pi(), big.NewRat(1, 2))), nil
 			}
-			return new(big.Rat).SetFloat64(1 / math.Tan(float))
+			return p.inverseTrig("acot", new(big.Rat).Inv(args[0]), math.Atan, bigAtan)
 		},
 	})
 
 	funcs.register("deg2rad", function{
 		arity: 1,
-		fn: func(args []*big.Rat) *big.Rat {
-			float, _ := args[0].Float64()
-			return new(big.Rat).SetFloat64(float * (math.Pi / 180))
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			return degToRad(args[0], p.pi()), nil
 		},
 	})
 
 	funcs.register("rad2deg", function{
 		arity: 1,
-		fn: func(args []*big.Rat) *big.Rat {
-			float, _ := args[0].Float64()
-			return new(big.Rat).SetFloat64(float * 180 / math.Pi)
+		fn: func(p *Parser, args []*big.Rat) (*big.Rat, error) {
+			res := new(big.Rat).Mul(args[0], big.NewRat(180, 1))
+			return res.Quo(res, p.pi()), nil
 		},
 	})
 }
@@ -5,14 +5,17 @@
 package mathcat
 
 import (
+	"errors"
+	"math"
 	"math/big"
+	"strings"
 	"testing"
 )
 
 func TestFunctions(t *testing.T) {
 	badCalls := []string{
 		"a()", "a(1, 2, 3)", "2 + 6 * (a(1, 2))", "abs(1, 2)", "abs()",
-		"max(1)", "min(2)",
+		"max()", "min()",
 	}
 
 	for _, expr := range badCalls {
@@ -23,7 +26,7 @@ func TestFunctions(t *testing.T) {
 	}
 
 	okCalls := []string{
-		"abs(-300)", "max(8, 8)", "8 * cos(pi) - 6", "tan(8 * 8 * (7**7))",
+		"abs(-300)", "max(8, 8)", "8 * cos(pi) - 6", "tan(8 * 8 * (7^7))",
 		"tan(cos(8) / sin(3))",
 	}
 
@@ -39,7 +42,7 @@ func TestFunctionsResult(t *testing.T) {
 	calls := map[string]*big.Rat{
 		"abs(-700)":                         big.NewRat(700, 1),
 		"ceil(813.23)":                      big.NewRat(814, 1),
-		"ceil(ceil(10 ** 16 + 0.1))":        big.NewRat(10000000000000001, 1),
+		"ceil(ceil(10 ^ 16 + 0.1))":         big.NewRat(10000000000000001, 1),
 		"floor(813.23)":                     big.NewRat(813, 1),
 		"floor(-50.23)":                     big.NewRat(-51, 1),
 		"floor(-50)":                        big.NewRat(-50, 1),
@@ -58,7 +61,7 @@ func TestFunctionsResult(t *testing.T) {
 		"tan(144) + tan(-3) + sin(5)":       big.NewRat(-49720712606960177, 36028797018963968),
 		"fact(6) * fact(7) == fact(10)":     big.NewRat(1, 1),
 		"fact(6.5) * fact(7.3) == fact(10)": big.NewRat(1, 1),
-		"list()": big.NewRat(1, 1),
+		"list()":                            big.NewRat(1, 1),
 	}
 
 	for expr, expected := range calls {
@@ -73,3 +76,313 @@ func TestFunctionsResult(t *testing.T) {
 		}
 	}
 }
+
+func TestUserFunctions(t *testing.T) {
+	p := New()
+
+	if res, err := p.Run("f(x, y) = x ^ 2 + y"); err != nil || res != nil {
+		t.Fatalf("unexpected result defining function: %v, %v", res, err)
+	}
+
+	p.Run("a = 2")
+	p.Run("g(x) = a * x + f(x, x)")
+	p.Run("h() = a")
+
+	calls := map[string]*big.Rat{
+		"f(3, 4)":         big.NewRat(13, 1),
+		"f(-2, 1/2) * 2":  big.NewRat(9, 1),
+		"g(3)":            big.NewRat(18, 1),
+		"h() + f(h(), a)": big.NewRat(8, 1),
+	}
+
+	for expr, expected := range calls {
+		res, err := p.Run(expr)
+		if err != nil {
+			t.Errorf("unexpected error calling user function '%s': %s", expr, err)
+			continue
+		}
+
+		if res.Cmp(expected) != 0 {
+			t.Errorf("wrong result in user function call '%s' (expected %s, got %s)",
+				expr, expected, res)
+		}
+	}
+
+	// Functions see variables as they are when called
+	p.Run("a = 3")
+	if res, err := p.Run("g(3)"); err != nil || res.Cmp(big.NewRat(21, 1)) != 0 {
+		t.Errorf("wrong result after changing variable (expected 21, got %v)", res)
+	}
+
+	// Parameters don't leak into the parser's variables
+	if _, err := p.GetVar("x"); err == nil {
+		t.Error("function parameter leaked into variables")
+	}
+
+	p.Run("f(x) = x += 1")
+	if res, err := p.Run("f(1)"); err != nil || res.Cmp(big.NewRat(2, 1)) != 0 {
+		t.Errorf("wrong result redefining function (expected 2, got %v)", res)
+	}
+
+	p.Run("r(x) = r(x + 1)")
+
+	badCalls := []string{
+		"f()", "f(1, 2)", "g(1, 2)", "h(1)", "r(1)", "x", "f(2) = 3",
+		"f(x, x) = x", "f(x) += 1", "f(x + 1) = x",
+	}
+
+	for _, expr := range badCalls {
+		if _, err := p.Run(expr); err == nil {
+			t.Errorf("expected error on bad user function call '%s'", expr)
+		}
+	}
+
+	if _, err := New().Run("f(3, 4)"); err == nil {
+		t.Error("user function leaked to another parser")
+	}
+}
+
+// unregister removes a function added with RegisterFunction, so it doesn't
+// leak into other tests.
+func unregister(name string) {
+	delete(funcs, name)
+	for i, n := range FunctionNames {
+		if n == name {
+			FunctionNames = append(FunctionNames[:i:i], FunctionNames[i+1:]...)
+			break
+		}
+	}
+}
+
+func TestRegisterFunction(t *testing.T) {
+	RegisterFunction("double", 1, func(args []*big.Rat) (*big.Rat, error) {
+		return new(big.Rat).Mul(args[0], big.NewRat(2, 1)), nil
+	})
+	t.Cleanup(func() { unregister("double") })
+
+	if res, err := Eval("double(21)"); err != nil || res.Cmp(big.NewRat(42, 1)) != 0 {
+		t.Errorf("wrong result calling registered function (expected 42, got %v, %v)", res, err)
+	}
+
+	p, other := New(), New()
+	p.RegisterFunction("failing", -1, func(args []*big.Rat) (*big.Rat, error) {
+		return nil, errors.New("failing function")
+	})
+	p.RegisterFunction("abs", 1, func(args []*big.Rat) (*big.Rat, error) {
+		return big.NewRat(-1, 1), nil
+	})
+
+	if _, err := p.Run("1 + failing(1, 2, 3)"); err == nil || err.Error() != "failing function" {
+		t.Errorf("expected error from registered function, got %v", err)
+	}
+
+	if res, err := p.Run("abs(5)"); err != nil || res.Cmp(big.NewRat(-1, 1)) != 0 {
+		t.Errorf("parser function didn't take precedence over builtin, got %v", res)
+	}
+
+	if _, err := other.Run("failing()"); err == nil {
+		t.Error("function registered on parser leaked to another parser")
+	}
+
+	if res, err := other.Run("abs(-5)"); err != nil || res.Cmp(big.NewRat(5, 1)) != 0 {
+		t.Errorf("function registered on parser changed builtin, got %v", res)
+	}
+
+	for _, name := range []string{"", "2a", "+"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic registering function '%s'", name)
+				}
+			}()
+			RegisterFunction(name, 0, nil)
+		}()
+	}
+}
+
+func TestFunctionErrors(t *testing.T) {
+	domainErrors := map[string]string{
+		"sqrt(-4)":      "Result is not a real number",
+		"csc(0)":        "csc undefined at multiples of pi",
+		"csc(pi)":       "csc undefined at multiples of pi",
+		"csc(180, 1)":   "csc undefined at multiples of pi",
+		"cot(-pi)":      "cot undefined at multiples of pi",
+		"sec(pi / 2)":   "sec undefined at odd multiples of pi/2",
+		"tan(90, 1)":    "tan undefined at odd multiples of pi/2",
+		"ln(0)":         "ln of zero",
+		"log(0)":        "log of zero",
+		"arg(0)":        "arg of zero",
+		"sin(i)":        "Expecting real numbers for 'sin'",
+		"logn(1, 5)":    "logn base has to be positive and not 1",
+		"logn(2, 0)":    "logn of non-positive number",
+		"asin(2)":       "asin argument outside of [-1, 1]",
+		"acos(-1.5)":    "acos argument outside of [-1, 1]",
+		"fact(-3)":      "fact of negative number",
+		"sin()":         "Invalid argument count for 'sin' (expected 1 or 2, got 0)",
+		"cos(1, 2, 3)":  "Invalid argument count for 'cos' (expected 1 or 2, got 3)",
+		"sin(10 ^ 400)": "sin result is not a finite number",
+		"log(-10^400)":  "ln result is not a finite number",
+	}
+
+	for expr, expected := range domainErrors {
+		res, err := Eval(expr)
+		if err == nil {
+			t.Errorf("expected error in '%s', got %s", expr, res)
+			continue
+		}
+
+		if err.Error() != expected {
+			t.Errorf("wrong error in '%s' (expected '%s', got '%s')", expr, expected, err)
+		}
+	}
+
+	p := New()
+	p.RegisterFunction("nothing", 0, func(_ []*big.Rat) (*big.Rat, error) {
+		return nil, nil
+	})
+
+	if _, err := p.Run("nothing() + 1"); err == nil {
+		t.Error("expected error on function without result")
+	}
+}
+
+func TestPrecision(t *testing.T) {
+	p := New()
+	p.SetPrecision(256)
+
+	// Expected results are truncated to 55 decimal places, about 183 bits
+	calls := map[string]string{
+		"pi":              "3.1415926535897932384626433832795028841971693993751058209",
+		"e":               "2.7182818284590452353602874713526624977572470936999595749",
+		"tau / 2":         "3.1415926535897932384626433832795028841971693993751058209",
+		"phi":             "1.6180339887498948482045868343656381177203091798057628621",
+		"sqrt(2)":         "1.4142135623730950488016887242096980785696718753769480731",
+		"2 ^ 0.5":         "1.4142135623730950488016887242096980785696718753769480731",
+		"ln(2)":           "0.6931471805599453094172321214581765680755001343602552541",
+		"exp(1)":          "2.7182818284590452353602874713526624977572470936999595749",
+		"exp(ln(10))":     "10.0000000000000000000000000000000000000000000000000000000",
+		"log(1000)":       "3.0000000000000000000000000000000000000000000000000000000",
+		"logn(2, 1024)":   "10.0000000000000000000000000000000000000000000000000000000",
+		"sin(1)":          "0.8414709848078965066525023216302989996225630607983710656",
+		"cos(1)":          "0.5403023058681397174009366074429766037323104206179222276",
+		"tan(1)":          "1.5574077246549022305069748074583601730872507723815200383",
+		"sin(30, 1)":      "0.5000000000000000000000000000000000000000000000000000000",
+		"sin(100)":        "-0.5063656411097587936565576104597854320650327212906573234",
+		"4 * atan(1)":     "3.1415926535897932384626433832795028841971693993751058209",
+		"6 * asin(0.5)":   "3.1415926535897932384626433832795028841971693993751058209",
+		"2 * acos(0)":     "3.1415926535897932384626433832795028841971693993751058209",
+		"2 * asin(1)":     "3.1415926535897932384626433832795028841971693993751058209",
+		"rad2deg(pi)":     "180.0000000000000000000000000000000000000000000000000000000",
+		"sqrt(2) ^ 2":     "2.0000000000000000000000000000000000000000000000000000000",
+		"(-8) ^ 3":        "-512.0000000000000000000000000000000000000000000000000000000",
+		"0.25 ^ -0.5":     "2.0000000000000000000000000000000000000000000000000000000",
+		"atan(10 ^ 30)":   "1.5707963267948966192313216916387514420985846996875529104",
+		"ln(1 / 10 ^ 30)": "-69.0775527898213705205397436405309262280330446588631892809",
+	}
+
+	for expr, expected := range calls {
+		res, err := p.Run(expr)
+		if err != nil {
+			t.Errorf("unexpected error in '%s': %s", expr, err)
+			continue
+		}
+
+		text := new(big.Float).SetPrec(256).SetRat(res).Text('f', 60)
+		// Allow the last digit to be rounded either way
+		if !strings.HasPrefix(text, expected[:len(expected)-1]) {
+			t.Errorf("wrong result in '%s' (expected %s, got %s)", expr, expected, text)
+		}
+	}
+
+	// Assigned constants are left alone, the others go back to float64
+	p.Run("e = 3")
+	p.SetPrecision(0)
+	if e, _ := p.GetVar("e"); e.Cmp(big.NewRat(3, 1)) != 0 {
+		t.Errorf("SetPrecision overwrote assigned constant, got %s", e)
+	}
+	if pi, _ := p.GetVar("pi"); pi.Cmp(defaultVariables["pi"].Re) != 0 {
+		t.Errorf("SetPrecision didn't restore pi, got %s", pi)
+	}
+
+	// Tiny precisions are raised to MinPrecision, and still give results
+	p.SetPrecision(8)
+	if p.Precision() != MinPrecision {
+		t.Errorf("wrong precision after setting 8 bits (expected %d, got %d)", MinPrecision, p.Precision())
+	}
+	res, err := p.Run("tan(1)")
+	if err != nil {
+		t.Fatalf("unexpected error in tan(1) at a low precision: %s", err)
+	}
+	if f, _ := res.Float64(); math.Abs(f-math.Tan(1)) > 1e-3 {
+		t.Errorf("wrong result of tan(1) at a low precision (got %g)", f)
+	}
+
+	for _, expr := range []string{"sin(pi)", "csc(pi)", "tan(pi / 2)", "exp(10 ^ 10)"} {
+		p.SetPrecision(128)
+		if res, err := p.Run(expr); expr != "sin(pi)" && err == nil {
+			t.Errorf("expected error in '%s' at 128 bits, got %s", expr, res)
+		}
+	}
+}
+
+func TestAngleMode(t *testing.T) {
+	calls := map[AngleMode]map[string]float64{
+		Radians: {
+			"sin(pi / 2)": 1,
+			"acos(-1)":    math.Pi,
+			"acot(0)":     math.Pi / 2,
+			"sin(90, 1)":  1,
+			"arg(i)":      math.Pi / 2,
+		},
+		Degrees: {
+			"sin(30)":    0.5,
+			"cos(60)":    0.5,
+			"tan(45)":    1,
+			"csc(30)":    2,
+			"sec(60)":    2,
+			"cot(45)":    1,
+			"asin(1)":    90,
+			"acos(0)":    90,
+			"atan(1)":    45,
+			"acsc(2)":    30,
+			"asec(2)":    60,
+			"acot(1)":    45,
+			"acot(0)":    90,
+			"sin(90, 1)": 1,
+			"arg(i)":     90,
+			"arg(-1)":    180,
+		},
+		Gradians: {
+			"sin(100)":   1,
+			"cos(200)":   -1,
+			"asin(1)":    100,
+			"atan(-1)":   -50,
+			"sin(90, 1)": 1,
+		},
+	}
+
+	for mode, exprs := range calls {
+		p := New()
+		p.SetAngleMode(mode)
+
+		for expr, expected := range exprs {
+			res, err := p.Run(expr)
+			if err != nil {
+				t.Errorf("unexpected error in '%s' (mode %d): %s", expr, mode, err)
+				continue
+			}
+
+			if f, _ := res.Float64(); math.Abs(f-expected) > 1e-9 {
+				t.Errorf("wrong result in '%s' (mode %d, expected %g, got %g)", expr, mode, expected, f)
+			}
+		}
+	}
+
+	p := New()
+	p.SetAngleMode(Degrees)
+	for _, expr := range []string{"tan(90)", "csc(180)", "acsc(0.5)", "asec(0)"} {
+		if res, err := p.Run(expr); err == nil {
+			t.Errorf("expected error in '%s' in degrees, got %s", expr, res)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// maxIterations limits how many times sum, prod and iterate evaluate their
+// body, so a typo like sum(k, 1, 10^12, k) fails instead of hanging.
+const maxIterations = 1000000
+
+// boundVar returns the name of the variable a lazy function like sum binds.
+func boundVar(name string, n Node) (string, error) {
+	ident, ok := n.(*IdentNode)
+	if !ok {
+		return "", fmt.Errorf("Expecting a variable name for ‘%s’, got ‘%s’", name, n)
+	}
+
+	return ident.Name, nil
+}
+
+// intArg evaluates an argument that has to be an integer, like the bounds of
+// sum and prod.
+func (p *Parser) intArg(name string, n Node) (*big.Int, error) {
+	res, err := p.evaluate(n)
+	if err != nil {
+		return nil, err
+	}
+
+	if !res.IsReal() || !res.Re.IsInt() {
+		return nil, fmt.Errorf("Expecting an integer for ‘%s’, got ‘%s’", name, res)
+	}
+
+	return new(big.Int).Set(res.Re.Num()), nil
+}
+
+// checkIterations makes sure a loop of count iterations stays within
+// maxIterations.
+func checkIterations(name string, count *big.Int) error {
+	if count.Cmp(big.NewInt(maxIterations)) > 0 {
+		return fmt.Errorf("Too many iterations for ‘%s’ (at most %d)", name, maxIterations)
+	}
+
+	return nil
+}
+
+// bind evaluates body with the local variable name set to val. The variables
+// and function parameters around it stay visible, but name doesn't leak out
+// of it.
+func (p *Parser) bind(name string, val *Complex, body Node) (*Complex, error) {
+	locals := make(map[string]*Complex, len(p.locals)+1)
+	for k, v := range p.locals {
+		locals[k] = v
+	}
+	locals[name] = val
+
+	outerLocals := p.locals
+	p.locals = locals
+	defer func() {
+		p.locals = outerLocals
+	}()
+
+	return p.evaluate(body)
+}
+
+// isFold reports whether call is sum or prod binding a variable, like
+// sum(k, 1, n, k^2), rather than adding up or multiplying a list of values.
+// That only depends on the arguments' syntax, never on which variables are
+// defined, so sum(k, 1, 3, 2) is 6 even after k = 5.
+func isFold(call *CallNode) bool {
+	_, ok := boundName(call)
+	return ok && (call.Name == "sum" || call.Name == "prod")
+}
+
+// fold evaluates body for every integer from lower to upper bound of
+// args = (var, from, to, body), and combines the results with the operator
+// step starting at init. It's used for sum(k, 1, n, expr) and
+// prod(k, 1, n, expr), which also work for matrices.
+func (p *Parser) fold(name string, args []Node, init *Complex, step TokenType) (*Complex, error) {
+	bound, err := boundVar(name, args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := p.intArg(name, args[1])
+	if err != nil {
+		return nil, err
+	}
+	to, err := p.intArg(name, args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	count := new(big.Int).Sub(to, from)
+	if err := checkIterations(name, count.Add(count, big.NewInt(1))); err != nil {
+		return nil, err
+	}
+
+	acc := init
+	for k := from; k.Cmp(to) <= 0; k = new(big.Int).Add(k, big.NewInt(1)) {
+		term, err := p.bind(bound, realComplex(new(big.Rat).SetInt(k)), args[3])
+		if err != nil {
+			return nil, err
+		}
+		if acc, err = p.execute(op(step), acc, term); err != nil {
+			return nil, err
+		}
+	}
+
+	return acc, nil
+}
+
+// iterate evaluates iterate(x, x0, n, expr), which starts with x = x0 and
+// then sets x to expr n times.
+func (p *Parser) iterate(args []Node) (*Complex, error) {
+	bound, err := boundVar("iterate", args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	x, err := p.evaluate(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := p.intArg("iterate", args[2])
+	if err != nil {
+		return nil, err
+	}
+	if n.Sign() < 0 {
+		return nil, fmt.Errorf("Expecting a non-negative integer for ‘iterate’, got ‘%s’", n)
+	}
+	if err := checkIterations("iterate", n); err != nil {
+		return nil, err
+	}
+
+	for i := int64(0); i < n.Int64(); i++ {
+		if x, err = p.bind(bound, x, args[3]); err != nil {
+			return nil, err
+		}
+	}
+
+	return x, nil
+}
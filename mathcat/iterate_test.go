@@ -0,0 +1,130 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestIterate(t *testing.T) {
+	okExpressions := map[string]*big.Rat{
+		"sum(k, 1, 100, k)":                   big.NewRat(5050, 1),
+		"sum(k, 1, 100, k^2)":                 big.NewRat(338350, 1),
+		"sum(k, 1, 0, k)":                     big.NewRat(0, 1),
+		"sum(k, -2, 2, k^3)":                  big.NewRat(0, 1),
+		"sum(k, 0, 3, 1 / 2^k)":               big.NewRat(15, 8),
+		"sum(i, 1, 3, sum(j, 1, i, i * j))":   big.NewRat(25, 1),
+		"prod(k, 1, 5, k)":                    big.NewRat(120, 1),
+		"prod(k, 3, 2, k)":                    big.NewRat(1, 1),
+		"prod(k, 1, 4, 1 - 1 / (k + 1))":      big.NewRat(1, 5),
+		"iterate(x, 1, 0, x + 1)":             big.NewRat(1, 1),
+		"iterate(x, 0, 10, x + 2)":            big.NewRat(20, 1),
+		"iterate(x, 1, 3, x / 2 + 1 / x)":     big.NewRat(577, 408),
+		"2 sum(k, 1, 3, k) + 1":               big.NewRat(13, 1),
+		"sum(k, 1, 3, k == 2 ? 10 : k)":       big.NewRat(14, 1),
+		"sum(k, 1, 4, k!)":                    big.NewRat(33, 1),
+		"sum(n, 1, 10, if(n % 2, n, 0))":      big.NewRat(25, 1),
+		"iterate(k, 2, 2, sum(k, 1, k, k))":   big.NewRat(6, 1),
+		"sum(k, 1, 3, k = k * 2)":             big.NewRat(12, 1),
+		"prod(k, 1, 3, sum(j, 1, k, 1))":      big.NewRat(6, 1),
+		"sum(k, 1, 3, prod(j, 1, 2, k))":      big.NewRat(14, 1),
+		"sum(k, 10 - 9, 2 + 1, k) * 2 - 1":    big.NewRat(11, 1),
+		"sum(k, 1, 2, sum(k, 1, 2, k))":       big.NewRat(6, 1),
+		"iterate(a, 1, 4, a * 2) == 2^4":      RatTrue,
+		"sum(k, 1, 3, iterate(x, k, 1, x^2))": big.NewRat(14, 1),
+	}
+
+	for expr, expected := range okExpressions {
+		res, err := Eval(expr)
+		if err != nil {
+			t.Errorf("unexpected error in '%s': %s", expr, err)
+			continue
+		}
+
+		if expected.Cmp(res) != 0 {
+			t.Errorf("wrong result in expression '%s' (expected %s, got %s)", expr, expected, res)
+		}
+	}
+
+	badExpressions := []string{
+		"sum(k, 1, 3)",
+		"sum(1, 1, 3, k)",
+		"sum(k, 1, 2.5, k)",
+		"sum(k, 1, 10^7, k)",
+		"sum(k, i, 3, k)",
+		"prod(k + 1, 1, 3, k)",
+		"iterate(x, 1, -1, x)",
+		"iterate(x, 1, 1/2, x)",
+		"iterate(x, 1, 10^7, x)",
+		"sum(k, 1, 3, j)",
+		"sum(k, 1, 1 / 0, k)",
+		"sum(k, 1, 10, k > 5 ? 1 / 0 : k)",
+	}
+
+	for _, expr := range badExpressions {
+		if _, err := Eval(expr); err == nil {
+			t.Errorf("no error in bad expression '%s'", expr)
+		}
+	}
+}
+
+func TestIterateScope(t *testing.T) {
+	p := New()
+
+	if _, err := p.Run("k = 7"); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := p.Run("sum(k, 1, 4, k)")
+	if err != nil || res.Cmp(big.NewRat(10, 1)) != 0 {
+		t.Errorf("wrong result for sum (expected 10, got %s, %v)", res, err)
+	}
+
+	if _, err := p.Run("sum(j, 1, 4, j)"); err != nil {
+		t.Fatal(err)
+	}
+
+	if k, _ := p.GetVar("k"); k.Cmp(big.NewRat(7, 1)) != 0 {
+		t.Errorf("bound variable changed k to %s", k)
+	}
+
+	if _, err := p.GetVar("j"); err == nil {
+		t.Errorf("bound variable j leaked into the parser's variables")
+	}
+
+	// The bound variable and the parameters of a function are both visible
+	if _, err := p.Run("f(n, x) = sum(k, 0, n, x^k)"); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err = p.Run("f(3, 2)")
+	if err != nil || res.Cmp(big.NewRat(15, 1)) != 0 {
+		t.Errorf("wrong result for f(3, 2) (expected 15, got %s, %v)", res, err)
+	}
+
+	// Variables the body assigns to other than the bound one still end up in
+	// the parser's variables
+	if _, err := p.Run("sum(k, 1, 3, total = k)"); err != nil {
+		t.Fatal(err)
+	}
+
+	if total, err := p.GetVar("total"); err != nil || total.Cmp(big.NewRat(3, 1)) != 0 {
+		t.Errorf("wrong value for total (expected 3, got %s, %v)", total, err)
+	}
+
+	e, err := Compile("sum(k, 0, 10, x^k / k!)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err = e.Eval(map[string]*big.Rat{"x": big.NewRat(1, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f, _ := res.Float64(); f < 2.718281 || f > 2.718282 {
+		t.Errorf("wrong result for compiled sum (expected e, got %f)", f)
+	}
+}
@@ -77,8 +77,10 @@ loop:
 
 		switch {
 		case isIdent(l.ch):
+			l.implicitMul(false)
 			l.readIdent()
 		case isNumber(l.ch):
+			l.implicitMul(true)
 			l.readNumber()
 		case isWhitespace(l.ch):
 			l.skipWhitespace()
@@ -96,6 +98,8 @@ loop:
 				l.switchEq(Sub, SubEq)
 			case '/':
 				l.switchEq(Div, DivEq)
+			case '\\':
+				l.emit(LeftDiv)
 			case '*':
 				l.switchEq(Mul, MulEq)
 			case '^':
@@ -106,10 +110,24 @@ loop:
 					l.switchEq(Pow, PowEq)
 				}
 			case '%':
+				if l.isPercent() {
+					l.emit(Percent)
+					break
+				}
 				l.switchEq(Rem, RemEq)
 			case '&':
+				if l.peek() == '&' {
+					l.eat()
+					l.emit(LogAnd)
+					break
+				}
 				l.switchEq(And, AndEq)
 			case '|':
+				if l.peek() == '|' {
+					l.eat()
+					l.emit(LogOr)
+					break
+				}
 				l.switchEq(Or, OrEq)
 			case '<':
 				if l.peek() == '<' {
@@ -130,17 +148,38 @@ loop:
 			case '=':
 				l.switchEq(Eq, EqEq)
 			case '!':
-				if l.peek() != '=' {
-					return nil, fmt.Errorf("Invalid operation ‘%s’", string(l.ch))
+				// Like minus, a ! in front of an operand is a prefix operator,
+				// after an operand it's a factorial
+				switch {
+				case l.peek() == '=':
+					l.eat()
+					l.emit(NotEq)
+				case l.isNegation():
+					l.emit(LogNot)
+				case l.peek() == '!':
+					l.eat()
+					l.emit(DoubleFact)
+				default:
+					l.emit(Fact)
 				}
-				l.eat()
-				l.emit(NotEq)
+			case '?':
+				l.emit(Question)
+			case ':':
+				l.emit(Colon)
 			case '(':
+				l.implicitMul(false)
 				l.emit(Lparen)
 			case ')':
 				l.emit(Rparen)
 			case ',':
 				l.emit(Comma)
+			case '[':
+				l.implicitMul(false)
+				l.emit(Lbracket)
+			case ']':
+				l.emit(Rbracket)
+			case ';':
+				l.emit(Semicolon)
 			case '#', eol:
 				// Comment or EOL, stop scanning for tokens
 				l.emit(Eol)
@@ -230,7 +269,7 @@ func (l *lexer) readNumber() {
 	}
 
 	// Decimal literals
-	for isNumber(l.peek()) || l.peek() == 'e' || l.peek() == 'E' {
+	for isNumber(l.peek()) || l.isExponent() {
 		l.eat()
 		if (l.ch == 'e' || l.ch == 'E') && l.peek() == '-' {
 			l.eat()
@@ -240,8 +279,71 @@ func (l *lexer) readNumber() {
 	l.emit(Decimal)
 }
 
+// isExponent checks if the next character starts the exponent of a decimal
+// literal, like in 2e-10. An e that isn't followed by a number is the constant
+// e, like in 2e.
+func (l lexer) isExponent() bool {
+	if l.peek() != 'e' && l.peek() != 'E' {
+		return false
+	}
+
+	next := l.expr[l.pos+1]
+	if next == '-' {
+		next = l.expr[l.pos+2]
+	}
+
+	return next >= '0' && next <= '9'
+}
+
+// implicitMul emits a multiplication between two operands that follow each
+// other without an operator in between, like 2x, 3(x + 1) and (x + 1)(x - 1).
+// Called before an identifier, number, left parenthesis or bracket gets
+// emitted. A
+// number can't directly follow another number, and an identifier followed by
+// a parenthesis is a function call.
+func (l *lexer) implicitMul(number bool) {
+	if l.tokens == nil {
+		return
+	}
+
+	prev := l.prev()
+	afterNumber := prev.IsLiteral() && !prev.Is(Ident)
+	if prev.Is(Rparen) || prev.Is(Rbracket) || (afterNumber && !number) {
+		l.tokens = append(l.tokens, &Token{Type: Mul, Value: "*", Pos: l.start})
+	}
+}
+
+// isPercent checks if a % is the percent operator rather than the remainder,
+// which is the case when no operand follows it, like in 50% or 20% * x. A minus
+// right after it is a negation unless it's followed by whitespace, so 7 % -3 is
+// a remainder while 50% - 3 is a percentage.
+func (l lexer) isPercent() bool {
+	i := l.pos
+	for isWhitespace(l.expr[i]) {
+		i++
+	}
+
+	switch c := l.expr[i]; {
+	case c == '=' && i == l.pos && l.expr[i+1] != '=':
+		// %=
+		return false
+	case c == '-':
+		j := i + 1
+		for isWhitespace(l.expr[j]) {
+			j++
+		}
+		return j > i+1 && l.expr[j] != eol
+	case isIdent(c) || isNumber(c) || c == '(' || c == '[' || c == '~':
+		return false
+	}
+
+	return true
+}
+
 func (l lexer) isNegation() bool {
-	return l.tokens == nil || l.prev().Is(Lparen) || l.prev().IsOperator()
+	return l.tokens == nil || l.prev().Is(Lparen) || l.prev().Is(Comma) ||
+		l.prev().Is(Lbracket) || l.prev().Is(Semicolon) ||
+		(l.prev().IsOperator() && operators[l.prev().Type].kind != postfix)
 }
 
 func (l *lexer) switchEq(tokA, tokB TokenType) {
@@ -7,7 +7,7 @@ package mathcat
 import "testing"
 
 func TestLex(t *testing.T) {
-	res, err := Lex("some_var123 **= (.5 ** (3 + 4 - 2)) <<= 1.23 % -0.3")
+	res, err := Lex("some_var123 ^= (.5 ^ (3 + 4 - 2)) <<= 1.23 % -0.3")
 	expected := []TokenType{
 		Ident, PowEq, Lparen, Decimal, Pow, Lparen, Decimal, Add, Decimal, Sub,
 		Decimal, Rparen, Rparen, LshEq, Decimal, Rem, UnaryMin, Decimal, Eol,
@@ -43,8 +43,8 @@ func TestLiterals(t *testing.T) {
 
 func TestOperators(t *testing.T) {
 	// We add a number before - sign so it doesn't see it as unary
-	res, err := Lex(`= += -= /= *= **= %= &= |=  ^= <<= >>= == != > >= < <= | ^
-	& << >> ~ + 5 - * / ** % -`)
+	res, err := Lex(`= += -= /= *= ^= %= &= |=  ^^= <<= >>= == != > >= < <= | ^^
+	& << >> ~ + 5 - * / ^ % -`)
 	expected := []TokenType{
 		Eq, AddEq, SubEq, DivEq, MulEq, PowEq, RemEq, AndEq, OrEq,
 		XorEq, LshEq, RshEq, EqEq, NotEq, Gt, GtEq, Lt, LtEq, Or, Xor,
@@ -62,8 +62,110 @@ func TestOperators(t *testing.T) {
 	}
 }
 
+func TestMatrixTokens(t *testing.T) {
+	res, err := Lex("[1, -2; 3, 4]\\[5; 6], 2[x]")
+	expected := []TokenType{
+		Lbracket, Decimal, Comma, UnaryMin, Decimal, Semicolon, Decimal, Comma,
+		Decimal, Rbracket, LeftDiv, Lbracket, Decimal, Semicolon, Decimal,
+		Rbracket, Comma, Decimal, Mul, Lbracket, Ident, Rbracket, Eol,
+	}
+
+	if err != nil {
+		t.Errorf("unexpected lexer error occured: %s", err)
+	}
+
+	for k, v := range res {
+		if expected[k] != v.Type {
+			t.Errorf("mismatched token: expected %s, got %s", expected[k], v.Type)
+		}
+	}
+}
+
+func TestPostfixOperators(t *testing.T) {
+	res, err := Lex("5!, 3!! != 50%, 7 % 3, x %= 2, 50% - 3, 7 % -3, (5%)")
+	expected := []TokenType{
+		Decimal, Fact, Comma, Decimal, DoubleFact, NotEq, Decimal, Percent, Comma,
+		Decimal, Rem, Decimal, Comma, Ident, RemEq, Decimal, Comma, Decimal,
+		Percent, Sub, Decimal, Comma, Decimal, Rem, UnaryMin, Decimal, Comma,
+		Lparen, Decimal, Percent, Rparen, Eol,
+	}
+
+	if err != nil {
+		t.Errorf("unexpected lexer error occured: %s", err)
+	}
+
+	for k, v := range res {
+		if expected[k] != v.Type {
+			t.Errorf("mismatched token: expected %s, got %s", expected[k], v.Type)
+		}
+	}
+}
+
+func TestLogicOperators(t *testing.T) {
+	res, err := Lex("!a && b || !(c)? 1 : 0, 2 & 3 | 4, !!5, 6!")
+	expected := []TokenType{
+		LogNot, Ident, LogAnd, Ident, LogOr, LogNot, Lparen, Ident, Rparen,
+		Question, Decimal, Colon, Decimal, Comma, Decimal, And, Decimal, Or,
+		Decimal, Comma, LogNot, LogNot, Decimal, Comma, Decimal, Fact, Eol,
+	}
+
+	if err != nil {
+		t.Errorf("unexpected lexer error occured: %s", err)
+	}
+
+	if len(res) != len(expected) {
+		t.Fatalf("wrong amount of tokens: expected %d, got %d", len(expected), len(res))
+	}
+
+	for k, v := range res {
+		if expected[k] != v.Type {
+			t.Errorf("mismatched token: expected %s, got %s", expected[k], v.Type)
+		}
+	}
+}
+
 func TestUTF8(t *testing.T) {
 	if !isIdent('Å') || !isIdent('Ś') {
 		t.Error("isIdent doesn't recognize unicode characters")
 	}
 }
+
+func TestLexImplicitMul(t *testing.T) {
+	tests := map[string][]TokenType{
+		"2x":             {Decimal, Mul, Ident, Eol},
+		"2 pi":           {Decimal, Mul, Ident, Eol},
+		"2e":             {Decimal, Mul, Ident, Eol},
+		"2e3":            {Decimal, Eol},
+		"2e-3x":          {Decimal, Mul, Ident, Eol},
+		"0x10y":          {Hex, Eol},
+		"2x^2":           {Decimal, Mul, Ident, Pow, Decimal, Eol},
+		"-2x":            {UnaryMin, Decimal, Mul, Ident, Eol},
+		"3(x + 1)":       {Decimal, Mul, Lparen, Ident, Add, Decimal, Rparen, Eol},
+		"(x + 1)(x - 1)": {Lparen, Ident, Add, Decimal, Rparen, Mul, Lparen, Ident, Sub, Decimal, Rparen, Eol},
+		"(x)2":           {Lparen, Ident, Rparen, Mul, Decimal, Eol},
+		"(x)y":           {Lparen, Ident, Rparen, Mul, Ident, Eol},
+		"2sin(x)":        {Decimal, Mul, Ident, Lparen, Ident, Rparen, Eol},
+		"sin(x)":         {Ident, Lparen, Ident, Rparen, Eol},
+		"x2":             {Ident, Eol},
+		"2 3":            {Decimal, Decimal, Eol},
+	}
+
+	for expr, expected := range tests {
+		res, err := Lex(expr)
+		if err != nil {
+			t.Errorf("unexpected lexer error in '%s': %s", expr, err)
+			continue
+		}
+
+		if len(res) != len(expected) {
+			t.Errorf("wrong number of tokens in '%s' (expected %d, got %d)", expr, len(expected), len(res))
+			continue
+		}
+
+		for k, v := range res {
+			if expected[k] != v.Type {
+				t.Errorf("mismatched token in '%s': expected %s, got %s", expr, expected[k], v.Type)
+			}
+		}
+	}
+}
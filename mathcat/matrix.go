@@ -0,0 +1,545 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Matrix is a matrix of complex numbers, as a slice of rows that all have the
+// same length. Vectors are matrices with a single row or column, like
+// [1, 2, 3] or [1; 2; 3]. A 1x1 matrix is just a number.
+type Matrix [][]*Complex
+
+var (
+	ErrSingular    = errors.New("Matrix is singular")
+	ErrEmptyMatrix = errors.New("Empty matrix")
+)
+
+// newMatrix returns a rows x cols matrix of zeros.
+func newMatrix(rows, cols int) Matrix {
+	m := make(Matrix, rows)
+	for i := range m {
+		m[i] = make([]*Complex, cols)
+		for j := range m[i] {
+			m[i][j] = realComplex(new(big.Rat))
+		}
+	}
+
+	return m
+}
+
+// identity returns the n x n identity matrix.
+func identity(n int) Matrix {
+	m := newMatrix(n, n)
+	for i := range m {
+		m[i][i] = realComplex(big.NewRat(1, 1))
+	}
+
+	return m
+}
+
+// matrixValue returns m as a value, which is a number for 1x1 matrices.
+func matrixValue(m Matrix) *Complex {
+	if m.rows() == 1 && m.cols() == 1 {
+		return m[0][0]
+	}
+
+	return &Complex{Matrix: m}
+}
+
+// asMatrix returns z as a matrix, a number is a 1x1 matrix.
+func asMatrix(z *Complex) Matrix {
+	if z.IsMatrix() {
+		return z.Matrix
+	}
+
+	return Matrix{{z}}
+}
+
+func (m Matrix) rows() int {
+	return len(m)
+}
+
+func (m Matrix) cols() int {
+	return len(m[0])
+}
+
+// size formats the dimensions of m for error messages, like 2x3.
+func (m Matrix) size() string {
+	return fmt.Sprintf("%dx%d", m.rows(), m.cols())
+}
+
+func (m Matrix) String() string {
+	return m.Text((*big.Rat).RatString)
+}
+
+// Text formats m like [1, 2; 3, 4], with the elements formatted like
+// Complex.Text.
+func (m Matrix) Text(format func(*big.Rat) string) string {
+	rows := make([]string, m.rows())
+	for i, row := range m {
+		elems := make([]string, len(row))
+		for j, x := range row {
+			elems[j] = x.Text(format)
+		}
+		rows[i] = strings.Join(elems, ", ")
+	}
+
+	return "[" + strings.Join(rows, "; ") + "]"
+}
+
+// vector returns the elements of m if it's a row or column vector.
+func (m Matrix) vector() ([]*Complex, bool) {
+	switch {
+	case m.rows() == 1:
+		return m[0], true
+	case m.cols() == 1:
+		elems := make([]*Complex, m.rows())
+		for i, row := range m {
+			elems[i] = row[0]
+		}
+		return elems, true
+	}
+
+	return nil, false
+}
+
+func (m Matrix) equal(n Matrix) bool {
+	if m.rows() != n.rows() || m.cols() != n.cols() {
+		return false
+	}
+
+	for i, row := range m {
+		for j, x := range row {
+			if !x.equal(n[i][j]) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func (m Matrix) transpose() Matrix {
+	res := make(Matrix, m.cols())
+	for j := range res {
+		res[j] = make([]*Complex, m.rows())
+		for i := range m {
+			res[j][i] = m[i][j]
+		}
+	}
+
+	return res
+}
+
+// mul returns the matrix product of m and n, the columns of m have to match
+// the rows of n.
+func (m Matrix) mul(n Matrix) Matrix {
+	res := newMatrix(m.rows(), n.cols())
+	for i := range res {
+		for j := range res[i] {
+			for k := range n {
+				res[i][j] = res[i][j].add(m[i][k].mul(n[k][j]))
+			}
+		}
+	}
+
+	return res
+}
+
+// rref returns the reduced row echelon form of m and its rank, computed
+// exactly with Gauss–Jordan elimination. For square matrices it also returns
+// the determinant, which is the product of the pivots, negated for every row
+// swap.
+func (m Matrix) rref() (Matrix, int, *Complex) {
+	res := make(Matrix, m.rows())
+	for i, row := range m {
+		res[i] = append([]*Complex(nil), row...)
+	}
+
+	det := realComplex(big.NewRat(1, 1))
+	rank := 0
+
+	for col := 0; col < m.cols() && rank < m.rows(); col++ {
+		pivot := -1
+		for i := rank; i < m.rows(); i++ {
+			if !res[i][col].isZero() {
+				pivot = i
+				break
+			}
+		}
+		if pivot == -1 {
+			continue
+		}
+
+		if pivot != rank {
+			res[pivot], res[rank] = res[rank], res[pivot]
+			det = det.neg()
+		}
+
+		// Scale the pivot to 1, the pivot isn't zero so quo can't fail
+		p := res[rank][col]
+		det = det.mul(p)
+		for j := range res[rank] {
+			res[rank][j], _ = res[rank][j].quo(p)
+		}
+
+		for i := range res {
+			if i == rank || res[i][col].isZero() {
+				continue
+			}
+
+			f := res[i][col]
+			for j := range res[i] {
+				res[i][j] = res[i][j].sub(f.mul(res[rank][j]))
+			}
+		}
+
+		rank++
+	}
+
+	if rank < m.rows() {
+		det = realComplex(new(big.Rat))
+	}
+
+	return res, rank, det
+}
+
+// augment returns m with the columns of n added to the right.
+func (m Matrix) augment(n Matrix) Matrix {
+	res := make(Matrix, m.rows())
+	for i := range m {
+		res[i] = append(append([]*Complex(nil), m[i]...), n[i]...)
+	}
+
+	return res
+}
+
+// solve returns x for m * x = n, with m square. The left part of the
+// reduced [m | n] is the identity matrix unless m is singular, which shows in
+// its last pivot.
+func (m Matrix) solve(n Matrix) (Matrix, error) {
+	reduced, _, _ := m.augment(n).rref()
+	if reduced[m.rows()-1][m.cols()-1].isZero() {
+		return nil, ErrSingular
+	}
+
+	res := make(Matrix, m.rows())
+	for i, row := range reduced {
+		res[i] = row[m.cols():]
+	}
+
+	return res, nil
+}
+
+// square checks that m is square, for the function or operator name.
+func (m Matrix) square(name string) error {
+	if m.rows() != m.cols() {
+		return fmt.Errorf("Expecting a square matrix for ‘%s’, got %s", name, m.size())
+	}
+
+	return nil
+}
+
+// dimensionError is returned when the matrices of an operator don't fit.
+func dimensionError(operator *Token, a, b Matrix) error {
+	return fmt.Errorf("Matrix dimensions don't match for ‘%s’ (%s and %s)", operator, a.size(), b.size())
+}
+
+// evaluateMatrix evaluates the elements of a matrix literal.
+func (p *Parser) evaluateMatrix(n *MatrixNode) (*Complex, error) {
+	m := make(Matrix, len(n.Rows))
+
+	for i, row := range n.Rows {
+		m[i] = make([]*Complex, len(row))
+		for j, elem := range row {
+			x, err := p.evaluate(elem)
+			if err != nil {
+				return nil, err
+			}
+			if x.IsMatrix() {
+				return nil, fmt.Errorf("Expecting numbers in a matrix, got ‘%s’", x)
+			}
+			m[i][j] = x
+		}
+	}
+
+	return matrixValue(m), nil
+}
+
+// executeMatrix evaluates an operator of which at least one operand is a
+// matrix. Adding, subtracting and scaling work element by element, * is the
+// matrix product, / multiplies by the inverse, a \ b solves a * x = b and ^
+// takes integer powers of square matrices.
+func (p *Parser) executeMatrix(operator *Token, lhs, rhs *Complex) (*Complex, error) {
+	switch operator.Type {
+	case Eq:
+		return rhs, nil
+	case UnaryMin, Add, AddEq, Sub, SubEq:
+		return p.elementwise(operator, lhs, rhs)
+	case Mul, MulEq:
+		if !lhs.IsMatrix() || !rhs.IsMatrix() {
+			return p.elementwise(operator, lhs, rhs)
+		}
+		if lhs.Matrix.cols() != rhs.Matrix.rows() {
+			return nil, dimensionError(operator, lhs.Matrix, rhs.Matrix)
+		}
+		return matrixValue(lhs.Matrix.mul(rhs.Matrix)), nil
+	case Div, DivEq:
+		if !rhs.IsMatrix() {
+			return p.elementwise(operator, lhs, rhs)
+		}
+		if err := rhs.Matrix.square(operator.Value); err != nil {
+			return nil, err
+		}
+		inv, err := rhs.Matrix.solve(identity(rhs.Matrix.rows()))
+		if err != nil {
+			return nil, err
+		}
+		return p.execute(&Token{Type: Mul, Value: "*"}, lhs, matrixValue(inv))
+	case LeftDiv:
+		if !lhs.IsMatrix() {
+			return p.elementwise(operator, lhs, rhs)
+		}
+		return p.leftDiv(operator, lhs.Matrix, asMatrix(rhs))
+	case Pow, PowEq:
+		return p.matrixPow(operator, lhs, rhs)
+	case EqEq:
+		return realComplex(boolToRat(lhs.IsMatrix() && rhs.IsMatrix() && lhs.Matrix.equal(rhs.Matrix))), nil
+	case NotEq:
+		return realComplex(boolToRat(!lhs.IsMatrix() || !rhs.IsMatrix() || !lhs.Matrix.equal(rhs.Matrix))), nil
+	}
+
+	return nil, fmt.Errorf("Can't use ‘%s’ with matrices", operator)
+}
+
+// elementwise applies operator to every element of a matrix. A number on
+// either side is used for every element, two matrices need the same size.
+func (p *Parser) elementwise(operator *Token, lhs, rhs *Complex) (*Complex, error) {
+	var a, b Matrix
+	if lhs != nil && lhs.IsMatrix() {
+		a = lhs.Matrix
+	}
+	if rhs.IsMatrix() {
+		b = rhs.Matrix
+	}
+	if a != nil && b != nil && (a.rows() != b.rows() || a.cols() != b.cols()) {
+		return nil, dimensionError(operator, a, b)
+	}
+
+	size := a
+	if size == nil {
+		size = b
+	}
+
+	res := make(Matrix, size.rows())
+	for i := range res {
+		res[i] = make([]*Complex, size.cols())
+		for j := range res[i] {
+			x, y := lhs, rhs
+			if a != nil {
+				x = a[i][j]
+			}
+			if b != nil {
+				y = b[i][j]
+			}
+
+			elem, err := p.execute(operator, x, y)
+			if err != nil {
+				return nil, err
+			}
+			res[i][j] = elem
+		}
+	}
+
+	return matrixValue(res), nil
+}
+
+// leftDiv solves a * x = b. A row vector b is taken as a column, and the
+// solution is returned in the same shape.
+func (p *Parser) leftDiv(operator *Token, a, b Matrix) (*Complex, error) {
+	if err := a.square(operator.Value); err != nil {
+		return nil, err
+	}
+
+	row := b.rows() == 1 && b.cols() == a.rows()
+	if row {
+		b = b.transpose()
+	}
+	if b.rows() != a.rows() {
+		return nil, dimensionError(operator, a, b)
+	}
+
+	x, err := a.solve(b)
+	if err != nil {
+		return nil, err
+	}
+	if row {
+		x = x.transpose()
+	}
+
+	return matrixValue(x), nil
+}
+
+// matrixPow raises a square matrix to an integer power, negative powers are
+// powers of the inverse.
+func (p *Parser) matrixPow(operator *Token, lhs, rhs *Complex) (*Complex, error) {
+	if rhs.IsMatrix() {
+		return nil, fmt.Errorf("Can't raise to the power of a matrix with ‘%s’", operator)
+	}
+	if !rhs.IsReal() || !rhs.Re.IsInt() {
+		return nil, fmt.Errorf("Expecting an integer power of a matrix, got ‘%s’", rhs)
+	}
+
+	m := lhs.Matrix
+	if err := m.square(operator.Value); err != nil {
+		return nil, err
+	}
+
+	n := new(big.Int).Set(rhs.Re.Num())
+	if n.Sign() < 0 {
+		inv, err := m.solve(identity(m.rows()))
+		if err != nil {
+			return nil, err
+		}
+		m = inv
+		n.Neg(n)
+	}
+
+	res, square := identity(m.rows()), m
+	for i := 0; i < n.BitLen(); i++ {
+		if n.Bit(i) == 1 {
+			res = res.mul(square)
+		}
+		square = square.mul(square)
+	}
+
+	return matrixValue(res), nil
+}
+
+// mapElements calls a function that takes numbers for every element of the
+// matrix that is its first argument, like sqrt([4, 9]) = [2, 3].
+func mapElements(args []*Complex, f func(args []*Complex) (*Complex, error)) (*Complex, error) {
+	m := args[0].Matrix
+	res := make(Matrix, m.rows())
+
+	for i, row := range m {
+		res[i] = make([]*Complex, len(row))
+		for j, x := range row {
+			elemArgs := append([]*Complex{x}, args[1:]...)
+			elem, err := f(elemArgs)
+			if err != nil {
+				return nil, err
+			}
+			res[i][j] = elem
+		}
+	}
+
+	return matrixValue(res), nil
+}
+
+// vectorArgs returns the elements of two vectors of the same length, for dot
+// and cross.
+func vectorArgs(name string, args []*Complex) ([]*Complex, []*Complex, error) {
+	u, uOk := asMatrix(args[0]).vector()
+	v, vOk := asMatrix(args[1]).vector()
+	if !uOk || !vOk {
+		return nil, nil, fmt.Errorf("Expecting vectors for ‘%s’", name)
+	}
+	if len(u) != len(v) {
+		return nil, nil, fmt.Errorf("Expecting vectors of the same length for ‘%s’", name)
+	}
+
+	return u, v, nil
+}
+
+func init() {
+	funcs.register("det", function{
+		arity:  1,
+		matrix: true,
+		cfn: func(_ *Parser, args []*Complex) (*Complex, error) {
+			m := asMatrix(args[0])
+			if err := m.square("det"); err != nil {
+				return nil, err
+			}
+			_, _, det := m.rref()
+			return det, nil
+		},
+	})
+	funcs.register("inv", function{
+		arity:  1,
+		matrix: true,
+		cfn: func(_ *Parser, args []*Complex) (*Complex, error) {
+			m := asMatrix(args[0])
+			if err := m.square("inv"); err != nil {
+				return nil, err
+			}
+			inv, err := m.solve(identity(m.rows()))
+			if err != nil {
+				return nil, err
+			}
+			return matrixValue(inv), nil
+		},
+	})
+	funcs.register("transpose", function{
+		arity:  1,
+		matrix: true,
+		cfn: func(_ *Parser, args []*Complex) (*Complex, error) {
+			return matrixValue(asMatrix(args[0]).transpose()), nil
+		},
+	})
+	funcs.register("rref", function{
+		arity:  1,
+		matrix: true,
+		cfn: func(_ *Parser, args []*Complex) (*Complex, error) {
+			res, _, _ := asMatrix(args[0]).rref()
+			return matrixValue(res), nil
+		},
+	})
+	funcs.register("dot", function{
+		arity:  2,
+		matrix: true,
+		cfn: func(_ *Parser, args []*Complex) (*Complex, error) {
+			u, v, err := vectorArgs("dot", args)
+			if err != nil {
+				return nil, err
+			}
+			res := realComplex(new(big.Rat))
+			for i := range u {
+				res = res.add(u[i].mul(v[i]))
+			}
+			return res, nil
+		},
+	})
+	funcs.register("cross", function{
+		arity:  2,
+		matrix: true,
+		cfn: func(_ *Parser, args []*Complex) (*Complex, error) {
+			u, v, err := vectorArgs("cross", args)
+			if err != nil {
+				return nil, err
+			}
+			if len(u) != 3 {
+				return nil, errors.New("Expecting vectors of length 3 for ‘cross’")
+			}
+
+			res := make([]*Complex, 3)
+			for i := range res {
+				j, k := (i+1)%3, (i+2)%3
+				res[i] = u[j].mul(v[k]).sub(u[k].mul(v[j]))
+			}
+
+			// The result has the shape of the first vector
+			if args[0].Matrix.rows() == 1 {
+				return matrixValue(Matrix{res}), nil
+			}
+			return matrixValue(Matrix{{res[0]}, {res[1]}, {res[2]}}), nil
+		},
+	})
+}
@@ -0,0 +1,128 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"testing"
+)
+
+func TestMatrix(t *testing.T) {
+	okExpressions := map[string]string{
+		"[1, 2; 3, 4]":                     "[1, 2; 3, 4]",
+		"[1, 2, 3]":                        "[1, 2, 3]",
+		"[1; 2; 3]":                        "[1; 2; 3]",
+		"[5]":                              "5",
+		"[1 + 1, -2; 3!, 2^3]":             "[2, -2; 6, 8]",
+		"[1, 2; 3, 4] + [4, 3; 2, 1]":      "[5, 5; 5, 5]",
+		"[1, 2; 3, 4] - 1":                 "[0, 1; 2, 3]",
+		"-[1, 2]":                          "[-1, -2]",
+		"2 * [1, 2; 3, 4]":                 "[2, 4; 6, 8]",
+		"2[1, 2]":                          "[2, 4]",
+		"[1, 2; 3, 4] * [5, 6; 7, 8]":      "[19, 22; 43, 50]",
+		"[1, 2] * [3; 4]":                  "11",
+		"[1; 2] * [3, 4]":                  "[3, 4; 6, 8]",
+		"[1, 2; 3, 4] / 2":                 "[1/2, 1; 3/2, 2]",
+		"[1, 2; 3, 4] ^ 2":                 "[7, 10; 15, 22]",
+		"[1, 2; 3, 4] ^ 0":                 "[1, 0; 0, 1]",
+		"[1, 2; 3, 4] ^ -1":                "[-2, 1; 3/2, -1/2]",
+		"det([1, 2; 3, 4])":                "-2",
+		"det([2, 0, 1; 1, 3, 2; 1, 1, 2])": "6",
+		"det([0, 1; 1, 0])":                "-1",
+		"det([1, 2; 2, 4])":                "0",
+		"inv([1, 2; 3, 4])":                "[-2, 1; 3/2, -1/2]",
+		"inv([1, 2; 3, 4]) * [1, 2; 3, 4]": "[1, 0; 0, 1]",
+		"transpose([1, 2, 3; 4, 5, 6])":    "[1, 4; 2, 5; 3, 6]",
+		"rref([1, 2, 3; 4, 5, 6])":         "[1, 0, -1; 0, 1, 2]",
+		"rref([1, 2; 2, 4])":               "[1, 2; 0, 0]",
+		"dot([1, 2, 3], [4, 5, 6])":        "32",
+		"dot([1; 2], [3, 4])":              "11",
+		"cross([1, 0, 0], [0, 1, 0])":      "[0, 0, 1]",
+		"cross([1; 2; 3], [4, 5, 6])":      "[-3; 6; -3]",
+		"[2, 1; 1, 3] \\ [3; 5]":           "[4/5; 7/5]",
+		"[2, 1; 1, 3] \\ [3, 5]":           "[4/5, 7/5]",
+		"[1, 2; 3, 4] / [1, 2; 3, 4]":      "[1, 0; 0, 1]",
+		"6 \\ 3":                           "1/2",
+		"sqrt([4, 9; 16, -1])":             "[2, 3; 4, i]",
+		"abs([-1, 2])":                     "[1, 2]",
+		"[i, 1] * [i; 1]":                  "0",
+		"[1, 2] == [1, 2]":                 "1",
+		"[1, 2] != [1, 2]":                 "0",
+		"[0, 0] ? 1 : 2":                   "2",
+		"[0, 1] ? 1 : 2":                   "1",
+		"sum(k, 1, 3, [k, 1])":             "[6, 3]",
+		"prod(k, 1, 2, [1, 1; 0, 1])":      "[1, 2; 0, 1]",
+		"max([1, 2; 3, 4], 3)":             "4",
+		"det(transpose([1, 2; 3, 4]) * 2)": "-8",
+		"[1, 2; 3, 4][1, 0; 0, 1]":         "[1, 2; 3, 4]",
+		"[sin(0), cos(0); 1 / 2, 2 ^ -1]":  "[0, 1; 1/2, 1/2]",
+		"[1, 2; 3, 4] ^ 3 == [1, 2; 3, 4] ^ 2 * [1, 2; 3, 4]": "1",
+	}
+
+	for expr, expected := range okExpressions {
+		res, err := New().RunComplex(expr)
+		if err != nil {
+			t.Errorf("unexpected error in '%s': %s", expr, err)
+			continue
+		}
+
+		if res.String() != expected {
+			t.Errorf("wrong result in expression '%s' (expected %s, got %s)", expr, expected, res)
+		}
+	}
+
+	badExpressions := []string{
+		"[]",
+		"[1, 2; 3]",
+		"[1;",
+		"[1, 2",
+		"1, 2]",
+		"(1; 2)",
+		"1; 2",
+		"[1, 2)",
+		"([1, 2]",
+		"[1, , 2]",
+		"[, 1]",
+		"[1, 2,]",
+		"[1; ; 2]",
+		"[; 1]",
+		"[1, 2] + [1, 2, 3]",
+		"[1, 2] * [1, 2]",
+		"det([1, 2, 3])",
+		"inv([1, 2; 2, 4])",
+		"[1, 2; 2, 4] ^ -1",
+		"[1, 2; 2, 4] \\ [1; 2]",
+		"[1, 2; 3, 4] \\ [1; 2; 3]",
+		"[1, 2; 3, 4] ^ 1.5",
+		"[1, 2] ^ 2",
+		"2 ^ [1, 2]",
+		"1 / [1, 2]",
+		"[1, 2] < [3, 4]",
+		"[1, 2] & 1",
+		"cross([1, 2], [3, 4])",
+		"dot([1, 2], [1, 2, 3])",
+		"[x, 1]",
+		"[1, 2] \\ [1, 2]",
+	}
+
+	for _, expr := range badExpressions {
+		if _, err := New().RunComplex(expr); err == nil {
+			t.Errorf("no error in bad expression '%s'", expr)
+		}
+	}
+
+	p := New()
+	if _, err := p.RunComplex("A = [1, 2; 3, 4]"); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := p.RunComplex("A * inv(A) == [1, 0; 0, 1]"); err != nil || res.String() != "1" {
+		t.Errorf("wrong result of matrix variable (expected 1, got %s, %v)", res, err)
+	}
+	if res, err := p.RunComplex("A *= 2"); err != nil || res.String() != "[2, 4; 6, 8]" {
+		t.Errorf("wrong result of matrix assignment (expected [2, 4; 6, 8], got %s, %v)", res, err)
+	}
+	if _, err := p.Run("A"); err != ErrMatrix {
+		t.Errorf("expected ErrMatrix for a matrix result, got %v", err)
+	}
+}
@@ -13,10 +13,13 @@ import (
 
 type association int
 
+// kind tells where an operator stands relative to its operands.
+type kind int
+
 type operator struct {
 	prec  int
 	assoc association
-	unary bool
+	kind  kind
 }
 
 const (
@@ -24,47 +27,72 @@ const (
 	AssocRight
 )
 
-var ErrDivisionByZero = errors.New("Division by zero")
+const (
+	infix   kind = iota // a + b
+	prefix              // -a
+	postfix             // a!
+)
+
+var (
+	ErrDivisionByZero = errors.New("Division by zero")
+	ErrNegativeBase   = errors.New("Fractional power of negative number")
+)
 
 var operators = map[TokenType]operator{
 	// Assignment operators
-	Eq:    {0, AssocRight, false}, // =
-	AddEq: {0, AssocRight, false}, // +=
-	SubEq: {0, AssocRight, false}, // -=
-	DivEq: {0, AssocRight, false}, // /=
-	MulEq: {0, AssocRight, false}, // *=
-	PowEq: {0, AssocRight, false}, // **=
-	RemEq: {0, AssocRight, false}, // %=
-	AndEq: {0, AssocRight, false}, // &=
-	OrEq:  {0, AssocRight, false}, // |=
-	XorEq: {0, AssocRight, false}, // ^=
-	LshEq: {0, AssocRight, false}, // <<=
-	RshEq: {0, AssocRight, false}, // >>=
+	Eq:    {0, AssocRight, infix}, // =
+	AddEq: {0, AssocRight, infix}, // +=
+	SubEq: {0, AssocRight, infix}, // -=
+	DivEq: {0, AssocRight, infix}, // /=
+	MulEq: {0, AssocRight, infix}, // *=
+	PowEq: {0, AssocRight, infix}, // ^=
+	RemEq: {0, AssocRight, infix}, // %=
+	AndEq: {0, AssocRight, infix}, // &=
+	OrEq:  {0, AssocRight, infix}, // |=
+	XorEq: {0, AssocRight, infix}, // ^^=
+	LshEq: {0, AssocRight, infix}, // <<=
+	RshEq: {0, AssocRight, infix}, // >>=
+
+	// Conditional operator, a ? b : c. The : replaces the ? on the operator
+	// stack once it's found, and takes all three operands when reduced.
+	Question: {1, AssocRight, infix}, // ?
+	Colon:    {1, AssocRight, infix}, // :
+
+	// Logical operators
+	LogOr:  {2, AssocLeft, infix},   // ||
+	LogAnd: {3, AssocLeft, infix},   // &&
+	LogNot: {13, AssocLeft, prefix}, // !
 
 	// Relational operators
-	EqEq:  {1, AssocRight, false}, // ==
-	NotEq: {1, AssocRight, false}, // !=
-	Gt:    {1, AssocRight, false}, // >
-	GtEq:  {1, AssocRight, false}, // >=
-	Lt:    {1, AssocRight, false}, // <
-	LtEq:  {1, AssocRight, false}, // <=
+	EqEq:  {4, AssocRight, infix}, // ==
+	NotEq: {4, AssocRight, infix}, // !=
+	Gt:    {4, AssocRight, infix}, // >
+	GtEq:  {4, AssocRight, infix}, // >=
+	Lt:    {4, AssocRight, infix}, // <
+	LtEq:  {4, AssocRight, infix}, // <=
 
 	// Bitwise operators
-	Or:  {2, AssocRight, false}, // |
-	Xor: {3, AssocRight, false}, // ^
-	And: {4, AssocRight, false}, // &
-	Lsh: {5, AssocRight, false}, // <<
-	Rsh: {5, AssocRight, false}, // >>
-	Not: {9, AssocLeft, true},   // ~
+	Or:  {5, AssocRight, infix},  // |
+	Xor: {6, AssocRight, infix},  // ^^
+	And: {7, AssocRight, infix},  // &
+	Lsh: {8, AssocRight, infix},  // <<
+	Rsh: {8, AssocRight, infix},  // >>
+	Not: {13, AssocLeft, prefix}, // ~
 
 	// Mathematical operators
-	Add:      {6, AssocLeft, false}, // +
-	Sub:      {6, AssocLeft, false}, // -
-	Mul:      {7, AssocLeft, false}, // *
-	Div:      {7, AssocLeft, false}, // /
-	Pow:      {8, AssocLeft, false}, // **
-	Rem:      {7, AssocLeft, false}, // %
-	UnaryMin: {10, AssocLeft, true}, // -
+	Add:      {9, AssocLeft, infix},   // +
+	Sub:      {9, AssocLeft, infix},   // -
+	Mul:      {10, AssocLeft, infix},  // *
+	Div:      {10, AssocLeft, infix},  // /
+	Pow:      {12, AssocRight, infix}, // ^, right associative so 2^3^2 = 2^(3^2)
+	Rem:      {10, AssocLeft, infix},  // %
+	LeftDiv:  {10, AssocLeft, infix},  // \, a \ b = b / a, which solves systems for matrices
+	UnaryMin: {11, AssocLeft, prefix}, // -, binds less tight than ^ so -x^2 = -(x^2)
+
+	// Postfix operators, applied to the operand right before them
+	Fact:       {14, AssocLeft, postfix}, // !
+	DoubleFact: {14, AssocLeft, postfix}, // !!
+	Percent:    {14, AssocLeft, postfix}, // %
 }
 
 // Determine if operator 1 has higher precedence than operator 2
@@ -73,8 +101,9 @@ func (o1 operator) hasHigherPrecThan(o2 operator) bool {
 		(o2.assoc == AssocRight && o2.prec < o1.prec)
 }
 
-// Execute a binary or unary expression
-func executeExpression(operator *Token, lhs, rhs *big.Rat) (*big.Rat, error) {
+// Execute a binary or unary expression. prec is the precision in bits of
+// results that can't be computed exactly, zero means float64 is used.
+func executeExpression(operator *Token, lhs, rhs *big.Rat, prec uint) (*big.Rat, error) {
 	result := new(big.Rat)
 
 	// Both lhs and rhs have to be integers for bitwise operations
@@ -99,16 +128,12 @@ func executeExpression(operator *Token, lhs, rhs *big.Rat) (*big.Rat, error) {
 	case Mul, MulEq:
 		result.Mul(lhs, rhs)
 	case Pow, PowEq:
-		if lhs.IsInt() && rhs.IsInt() {
-			intResult := new(big.Int)
-			intResult.Set(lhs.Num())
-			intResult.Exp(intResult, rhs.Num(), nil)
-			result.SetInt(intResult)
-		} else {
-			lhsFloat, _ := lhs.Float64()
-			rhsFloat, _ := rhs.Float64()
-			result.SetFloat64(math.Pow(lhsFloat, rhsFloat))
+		return pow(lhs, rhs, prec)
+	case LeftDiv:
+		if lhs.Sign() == 0 {
+			return nil, ErrDivisionByZero
 		}
+		result.Quo(rhs, lhs)
 	case Rem, RemEq:
 		if rhs.Sign() == 0 {
 			return nil, ErrDivisionByZero
@@ -128,6 +153,19 @@ func executeExpression(operator *Token, lhs, rhs *big.Rat) (*big.Rat, error) {
 		result.SetInt(new(big.Int).Rsh(lhs.Num(), shift))
 	case Not:
 		result.SetInt(new(big.Int).Not(rhs.Num()))
+	case LogNot:
+		result = boolToRat(rhs.Sign() == 0)
+	case Fact, DoubleFact:
+		if !rhs.IsInt() || rhs.Sign() < 0 {
+			return nil, fmt.Errorf("Expecting a non-negative integer for ‘%s’", operator)
+		}
+		if operator.Is(Fact) {
+			result = Factorial(rhs)
+		} else {
+			result = DoubleFactorial(rhs)
+		}
+	case Percent:
+		result.Quo(rhs, big.NewRat(100, 1))
 	case Eq:
 		result = rhs
 	case EqEq:
@@ -149,6 +187,68 @@ func executeExpression(operator *Token, lhs, rhs *big.Rat) (*big.Rat, error) {
 	return result, nil
 }
 
+// pow raises lhs to the power of rhs. Integer powers and rational powers of
+// perfect powers, like 8^(2/3), are exact. Negative bases can only be raised to
+// fractional powers with an odd denominator.
+func pow(lhs, rhs *big.Rat, prec uint) (*big.Rat, error) {
+	switch {
+	case lhs.Sign() == 0 && rhs.Sign() < 0:
+		return nil, ErrDivisionByZero
+	case rhs.IsInt():
+		return IntPow(lhs, rhs.Num()), nil
+	case lhs.Sign() == 0:
+		return new(big.Rat), nil
+	case lhs.Sign() < 0 && rhs.Denom().Bit(0) == 0:
+		return nil, ErrNegativeBase
+	}
+
+	// An odd root of a negative number is the negated root of its absolute
+	// value
+	abs := new(big.Rat).Abs(lhs)
+	negate := lhs.Sign() < 0 && rhs.Num().Bit(0) == 1
+
+	var res *big.Rat
+	if rhs.Denom().IsUint64() {
+		if root, ok := Root(abs, uint(rhs.Denom().Uint64())); ok {
+			res = IntPow(root, rhs.Num())
+		}
+	}
+
+	if res == nil {
+		var err error
+		if res, err = approxPow(abs, rhs, prec); err != nil {
+			return nil, err
+		}
+	}
+
+	if negate {
+		res.Neg(res)
+	}
+
+	return res, nil
+}
+
+// approxPow computes lhs^rhs for a positive lhs when it can't be done exactly.
+func approxPow(lhs, rhs *big.Rat, prec uint) (*big.Rat, error) {
+	if prec == 0 {
+		lhsFloat, _ := lhs.Float64()
+		rhsFloat, _ := rhs.Float64()
+		return ratFromFloat("^", math.Pow(lhsFloat, rhsFloat))
+	}
+
+	// lhs^rhs = e^(rhs * ln(lhs))
+	wp := prec + guardBits
+	res := bigLog(newFloat(wp).SetRat(lhs))
+	res.Mul(res, newFloat(wp).SetRat(rhs))
+	res = bigExp(res).SetPrec(prec)
+	if res.IsInf() || res.MantExp(nil) > maxFloatExp {
+		return nil, errors.New("^ result is not a finite number")
+	}
+
+	rat, _ := res.Rat(nil)
+	return rat, nil
+}
+
 func boolToRat(b bool) *big.Rat {
 	if b {
 		return RatTrue
@@ -11,21 +11,51 @@ import (
 	"math/big"
 )
 
-// Parser holds the lexed tokens, token position, declared variables and stacks
-// used throughout the parsing of an expression.
+// Parser holds the lexed tokens, token position, declared variables and
+// functions and stacks used throughout the parsing of an expression.
 //
 // By default, variables always contains the constants defined below. These can
 // however be overwritten.
+//
+// Variables only holds the real variables. Complex numbers like i and
+// matrices are kept apart; Var and SetVar get and set variables of any kind.
 type Parser struct {
 	Tokens    Tokens
 	Variables map[string]*big.Rat
+	// complexVars holds the variables that aren't real numbers
+	complexVars map[string]*Complex
 
 	pos int
 	tok *Token
 
 	operands, operators, arity stack
+
+	// functions holds the functions registered on this parser and the ones
+	// defined with Run, like f(x) = x ^ 2
+	functions functions
+	// locals holds the arguments of the user defined function being called
+	locals map[string]*Complex
+	depth  int
+
+	// prec is the precision in bits of results that can't be computed
+	// exactly, zero means float64 is used
+	prec uint
+	// constants holds the values of pi, tau, phi and e at prec
+	constants map[string]*Complex
+
+	angle AngleMode
 }
 
+// AngleMode is the unit of angles passed to and returned from trigonometric
+// functions.
+type AngleMode int
+
+const (
+	Radians AngleMode = iota
+	Degrees
+	Gradians
+)
+
 var (
 	// RatTrue represents true in boolean operations
 	RatTrue = big.NewRat(1, 1)
@@ -33,17 +63,23 @@ var (
 	RatFalse = big.NewRat(0, 1)
 
 	ErrUnmatchedParentheses = errors.New("Unmatched parentheses")
+	ErrUnmatchedBrackets    = errors.New("Unmatched brackets")
 	ErrMisplacedComma       = errors.New("Misplaced ‘,’")
 	ErrAssignToLiteral      = errors.New("Can't assign to literal")
-
-	defaultVariables = map[string]*big.Rat{
-		"pi":    new(big.Rat).SetFloat64(math.Pi),
-		"tau":   new(big.Rat).Mul(new(big.Rat).SetFloat64(math.Pi), big.NewRat(2, 1)),
-		"phi":   new(big.Rat).SetFloat64(math.Phi),
-		"e":     new(big.Rat).SetFloat64(math.E),
-		"true":  RatTrue,
-		"false": RatFalse,
+	ErrMaxCallDepth         = errors.New("Maximum function call depth exceeded")
+
+	defaultVariables = map[string]*Complex{
+		"pi":    realComplex(new(big.Rat).SetFloat64(math.Pi)),
+		"tau":   realComplex(new(big.Rat).Mul(new(big.Rat).SetFloat64(math.Pi), big.NewRat(2, 1))),
+		"phi":   realComplex(new(big.Rat).SetFloat64(math.Phi)),
+		"e":     realComplex(new(big.Rat).SetFloat64(math.E)),
+		"i":     NewComplex(new(big.Rat), big.NewRat(1, 1)),
+		"true":  realComplex(RatTrue),
+		"false": realComplex(RatFalse),
 	}
+
+	// constantNames are the default variables that depend on the precision
+	constantNames = []string{"pi", "tau", "phi", "e"}
 )
 
 // New initializes a new Parser instance, useful when you want to run multiple
@@ -52,42 +88,138 @@ func New() *Parser {
 	parser := &Parser{}
 
 	parser.Variables = make(map[string]*big.Rat)
+	parser.complexVars = make(map[string]*Complex)
+	parser.functions = make(functions)
+	parser.constants = make(map[string]*Complex)
 
 	for k, v := range defaultVariables {
-		parser.Variables[k] = v
+		parser.setVar(k, v)
+	}
+
+	for _, k := range constantNames {
+		parser.constants[k] = defaultVariables[k]
 	}
 
 	return parser
 }
 
+// MinPrecision is the lowest precision in bits SetPrecision takes, apart from
+// zero.
+const MinPrecision = 16
+
+// SetPrecision sets the precision in bits used for results that can't be
+// computed exactly, like sqrt(2), sin(1) or 2^0.5. With a precision of zero,
+// the default, these are computed with float64.
+//
+// Precisions below MinPrecision bits are raised to it, as results are assumed
+// to be off in their last 10 bits.
+//
+// The constants pi, tau, phi and e are recomputed at the new precision, unless
+// they have been assigned another value.
+//
+// Example:
+//
+//	p.SetPrecision(256)
+//	res, err := p.Run("sqrt(2)") // correct to 256 bits
+func (p *Parser) SetPrecision(prec uint) {
+	if prec != 0 && prec < MinPrecision {
+		prec = MinPrecision
+	}
+	constants := make(map[string]*Complex)
+
+	if prec == 0 {
+		for _, k := range constantNames {
+			constants[k] = defaultVariables[k]
+		}
+	} else {
+		pi := bigPi(prec)
+		e := bigExp(newFloat(prec).SetInt64(1))
+		phi := bigSqrt(newFloat(prec).SetInt64(5))
+		phi.Add(phi, newFloat(prec).SetInt64(1))
+		phi.SetMantExp(phi, -1)
+
+		for k, v := range map[string]*big.Float{
+			"pi":  pi,
+			"tau": newFloat(prec).Add(pi, pi),
+			"phi": phi,
+			"e":   e,
+		} {
+			rat, _ := v.Rat(nil)
+			constants[k] = realComplex(rat)
+		}
+	}
+
+	for k, v := range constants {
+		if p.Variables[k] == p.constants[k].Re {
+			p.Variables[k] = v.Re
+		}
+	}
+
+	p.prec = prec
+	p.constants = constants
+}
+
+// Precision returns the precision set with SetPrecision.
+func (p *Parser) Precision() uint {
+	return p.prec
+}
+
+// SetAngleMode sets the unit of angles used by the trigonometric functions and
+// their inverses, radians by default.
+//
+// Example:
+//
+//	p.SetAngleMode(mathcat.Degrees)
+//	res, err := p.Run("asin(1)") // 90
+func (p *Parser) SetAngleMode(mode AngleMode) {
+	p.angle = mode
+}
+
+// AngleMode returns the angle mode set with SetAngleMode.
+func (p *Parser) AngleMode() AngleMode {
+	return p.angle
+}
+
 // Eval evaluates an expression and returns its result and any errors found.
 //
 // Example:
 //
 //	res, err := mathcat.Eval("2 * 2 * 2") // 8
 func Eval(expr string) (*big.Rat, error) {
-	tokens, err := Lex(expr)
+	e, err := Compile(expr)
 
-	// If a lexer error occurred don't parse
+	// If a lexer or syntax error occurred don't evaluate
 	if err != nil {
 		return nil, err
 	}
 
-	p := New()
-	p.Tokens = tokens
-
-	return p.parse()
+	return toReal(New().evaluate(e.Root))
 }
 
 // Run executes an expression on an existing parser instance. Useful for
-// variable assignment.
+// variable assignment and defining functions. Defining a function gives a nil
+// result. Use RunComplex for expressions with a complex result, Run returns
+// ErrNotReal for those.
 //
 // Example:
 //
 //	p.Run("a = 555")
 //	p.Run("a += 45")
 //	res, err := p.Run("a + a") // 1200
+//
+//	p.Run("f(x, y) = x ^ 2 + y")
+//	res, err := p.Run("f(3, 4)") // 13
 func (p *Parser) Run(expr string) (*big.Rat, error) {
+	return toReal(p.RunComplex(expr))
+}
+
+// RunComplex executes an expression on an existing parser instance like Run,
+// but also allows complex results.
+//
+// Example:
+//
+//	res, err := p.RunComplex("sqrt(-4) + 1") // 1 + 2i
+func (p *Parser) RunComplex(expr string) (*Complex, error) {
 	tokens, err := Lex(expr)
 
 	if err != nil {
@@ -97,7 +229,12 @@ func (p *Parser) Run(expr string) (*big.Rat, error) {
 	p.reset()
 	p.Tokens = tokens
 
-	return p.parse()
+	root, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	return p.evaluate(root)
 }
 
 // Exec executes an expression with a given map of variables.
@@ -109,14 +246,53 @@ func (p *Parser) Run(expr string) (*big.Rat, error) {
 //	    "b": big.NewRat(3, 1),
 //	}) // 10
 func Exec(expr string, vars map[string]*big.Rat) (*big.Rat, error) {
+	e, err := Compile(expr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return e.Eval(vars)
+}
+
+// Expr is a compiled expression. It can be evaluated any number of times
+// without lexing and parsing the expression again.
+type Expr struct {
+	// Root is the root node of the expression's syntax tree. It is nil for
+	// expressions that don't do anything, like `()`.
+	Root Node
+}
+
+// Compile lexes and parses an expression into an Expr, ready to be evaluated
+// with Eval.
+//
+// Example:
+//
+//	e, err := mathcat.Compile("x ^ 2 + 1")
+//	res, err := e.Eval(map[string]*big.Rat{
+//	    "x": big.NewRat(3, 1),
+//	}) // 10
+func Compile(expr string) (*Expr, error) {
 	tokens, err := Lex(expr)
 
 	if err != nil {
 		return nil, err
 	}
 
+	p := &Parser{Tokens: tokens}
+
+	root, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Expr{Root: root}, nil
+}
+
+// Eval evaluates a compiled expression with a given map of variables. Any
+// assignments in the expression don't affect vars.
+func (e *Expr) Eval(vars map[string]*big.Rat) (*big.Rat, error) {
 	p := New()
-	p.Tokens = tokens
 
 	for name, val := range vars {
 		if !IsValidIdent(name) {
@@ -125,7 +301,15 @@ func Exec(expr string, vars map[string]*big.Rat) (*big.Rat, error) {
 		p.Variables[name] = val
 	}
 
-	return p.parse()
+	return toReal(p.evaluate(e.Root))
+}
+
+func (e *Expr) String() string {
+	if e.Root == nil {
+		return ""
+	}
+
+	return e.Root.String()
 }
 
 // GetVar gets an existing variable.
@@ -141,10 +325,82 @@ func (p Parser) GetVar(index string) (*big.Rat, error) {
 		return val, nil
 	}
 
+	if val, ok := p.complexVars[index]; ok {
+		if val.IsMatrix() {
+			return nil, fmt.Errorf("Variable ‘%s’ is a matrix", index)
+		}
+		return nil, fmt.Errorf("Variable ‘%s’ is not a real number", index)
+	}
+
 	return nil, fmt.Errorf("Undefined variable ‘%s’", index)
 }
 
-func (p *Parser) parse() (*big.Rat, error) {
+// Var gets an existing variable, which can also be a complex number or a
+// matrix.
+//
+// Example:
+//
+//	p.Run("z = 2 + 3i")
+//	if val, err := p.Var("z"); err == nil {
+//	    fmt.Println(val) // 2 + 3i
+//	}
+func (p *Parser) Var(name string) (*Complex, error) {
+	if val, ok := p.Variables[name]; ok {
+		return realComplex(val), nil
+	}
+
+	if val, ok := p.complexVars[name]; ok {
+		return val, nil
+	}
+
+	return nil, fmt.Errorf("Undefined variable ‘%s’", name)
+}
+
+// SetVar sets the variable name to val, which can also be a complex number
+// or a matrix. Real values end up in Variables.
+func (p *Parser) SetVar(name string, val *Complex) error {
+	if !IsValidIdent(name) {
+		return fmt.Errorf("Invalid variable name: ‘%s’", name)
+	}
+
+	p.setVar(name, val)
+	return nil
+}
+
+// setVar stores real values in Variables and any others in complexVars,
+// removing name from the other map.
+func (p *Parser) setVar(name string, val *Complex) {
+	if !val.IsMatrix() && val.IsReal() {
+		p.Variables[name] = val.Re
+		delete(p.complexVars, name)
+		return
+	}
+
+	p.complexVars[name] = val
+	delete(p.Variables, name)
+}
+
+// toReal converts the complex result of an expression to a real number.
+func toReal(res *Complex, err error) (*big.Rat, error) {
+	if err != nil || res == nil {
+		return nil, err
+	}
+
+	if res.IsMatrix() {
+		return nil, ErrMatrix
+	}
+	if !res.IsReal() {
+		return nil, ErrNotReal
+	}
+
+	return res.Re, nil
+}
+
+// parse runs the shunting-yard algorithm over the lexed tokens, building up a
+// syntax tree instead of a postfix expression. Operands are pushed as nodes and
+// every time an operator or function call gets popped it's reduced to a new
+// node with its operands as children.
+func (p *Parser) parse() (Node, error) {
 	// Initializing current token value
 	p.tok = p.Tokens[0]
 
@@ -164,25 +420,56 @@ func (p *Parser) parse() (*big.Rat, error) {
 				}
 				break
 			}
-			p.operands.Push(p.tok)
+
+			operand, err := p.operand(p.tok)
+			if err != nil {
+				return nil, err
+			}
+			p.operands.Push(operand)
 		case p.tok.Is(Lparen):
 			p.operators.Push(p.tok)
+		case p.tok.Is(Lbracket):
+			// Matrix literals keep count of their elements like function
+			// calls keep count of their arguments
+			p.operators.Push(p.tok)
+			if p.peek().Is(Rbracket) {
+				p.arity.Push(0)
+			} else {
+				p.arity.Push(1)
+			}
+		case p.tok.Is(Semicolon):
+			if err := p.handleSemicolon(); err != nil {
+				return nil, err
+			}
+		case p.tok.Is(Rbracket):
+			if err := p.handleRbracket(); err != nil {
+				return nil, err
+			}
 		case p.tok.Is(Comma):
 			for {
 				if p.operators.Empty() {
 					return nil, ErrMisplacedComma
 				}
 
-				if p.operators.Top().(*Token).Is(Lparen) {
+				if top := p.operators.Top().(*Token); top.Is(Lparen) {
+					break
+				} else if top.Is(Lbracket) {
+					if p.afterSeparator() {
+						return nil, ErrMisplacedComma
+					}
 					break
 				}
 
-				val, err := p.evaluate(p.operators.Pop().(*Token))
+				node, err := p.reduce(p.operators.Pop().(*Token))
 				if err != nil {
 					return nil, err
 				}
 
-				p.operands.Push(val)
+				p.operands.Push(node)
+			}
+
+			if p.arity.Empty() {
+				return nil, ErrMisplacedComma
 			}
 			p.arity.Push(p.arity.Pop().(int) + 1)
 		case p.tok.IsOperator():
@@ -199,45 +486,51 @@ func (p *Parser) parse() (*big.Rat, error) {
 				if top.Is(Lparen) {
 					break
 				}
+				if top.Is(Lbracket) {
+					return nil, ErrUnmatchedBrackets
+				}
 
-				val, err := p.evaluate(top)
+				node, err := p.reduce(top)
 				if err != nil {
 					return nil, err
 				}
 
-				p.operands.Push(val)
+				p.operands.Push(node)
 			}
 		}
 	}
 
-	// Evaluate remaining operators
+	// Reduce remaining operators
 	for !p.operators.Empty() {
 		top := p.operators.Pop().(*Token)
 
 		if top.Is(Lparen) {
 			return nil, ErrUnmatchedParentheses
 		}
+		if top.Is(Lbracket) {
+			return nil, ErrUnmatchedBrackets
+		}
 
-		val, err := p.evaluate(top)
+		node, err := p.reduce(top)
 		if err != nil {
 			return nil, err
 		}
 
-		p.operands.Push(val)
+		p.operands.Push(node)
 	}
 
 	// If there are no operands, the expression is useless and doesn't do
 	// anything, for example `()`
 	if p.operands.Empty() {
-		return new(big.Rat), nil
+		return nil, nil
 	}
 
-	// Single operand left means the expression was evaluated successful
+	// Single operand left means the expression was parsed successfully
 	if len(p.operands) == 1 {
-		return p.lookup(p.operands[0])
+		return p.operands[0].(Node), nil
 	}
 
-	// Leftover token on operand stack indicates invalid syntax
+	// Leftover node on operand stack indicates invalid syntax
 	return nil, fmt.Errorf("Unexpected ‘%s’", p.operands.Top())
 }
 
@@ -246,35 +539,51 @@ func (p *Parser) handleOperator() error {
 
 	o1 = operators[p.tok.Type]
 
+	if o1.kind == postfix {
+		return p.handlePostfix()
+	}
+
+	if p.tok.Is(Colon) {
+		return p.handleColon()
+	}
+
 	// No operators yet, just push to operators stack
 	if p.operators.Empty() {
 		p.operators.Push(p.tok)
 		return nil
 	}
 
+	// Prefix operators come before their operand, so an infix operator on top
+	// is still waiting for its right hand side, like in 2 ^ -3
+	if top := p.operators.Top().(*Token); o1.kind == prefix && top.IsOperator() &&
+		operators[top.Type].kind == infix {
+		p.operators.Push(p.tok)
+		return nil
+	}
+
 	// While there's a function at the top of the operator stack, or an operator
 	// with higher precedence than o1, pop operators to operands
 	for p.operators.Top().(*Token).Is(Ident) || p.operators.Top().(*Token).IsOperator() {
 		// Function call, always take precedence over operator
 		if p.operators.Top().(*Token).Is(Ident) {
 			function := p.operators.Pop().(*Token)
-			val, err := p.evaluateFunc(function)
+			node, err := p.reduceFunc(function)
 			if err != nil {
 				return err
 			}
 
-			p.operands.Push(val)
+			p.operands.Push(node)
 		} else {
 			o2 = operators[p.operators.Top().(*Token).Type]
 
 			// Another operator at top, check precedence
 			if o2.hasHigherPrecThan(o1) {
 				operator := p.operators.Pop().(*Token)
-				val, err := p.evaluateOp(operator)
+				node, err := p.reduceOp(operator)
 				if err != nil {
 					return err
 				}
-				p.operands.Push(val)
+				p.operands.Push(node)
 			} else {
 				break
 			}
@@ -290,118 +599,279 @@ func (p *Parser) handleOperator() error {
 	return nil
 }
 
-// evaluate gets called when an operator or function call has to be evaluated
-// for a result. In case of a function, evaluateFunc is called and in case of
-// an operator evaluateOp is called.
-func (p *Parser) evaluate(tok *Token) (*big.Rat, error) {
-	if tok.IsOperator() {
-		return p.evaluateOp(tok)
+// handlePostfix applies a postfix operator, like n!, to the operand right
+// before it. Postfix operators bind tighter than any other operator, so only a
+// function call that just ended has to be reduced first, like in sin(x)!.
+func (p *Parser) handlePostfix() error {
+	if p.pos < 2 {
+		return fmt.Errorf("Unexpected ‘%s’", p.tok)
+	}
+
+	prev := p.Tokens[p.pos-2]
+	if !prev.IsLiteral() && !prev.Is(Rparen) && !prev.Is(Rbracket) &&
+		!(prev.IsOperator() && operators[prev.Type].kind == postfix) {
+		return fmt.Errorf("Unexpected ‘%s’", p.tok)
+	}
+
+	if prev.Is(Rparen) && !p.operators.Empty() && p.operators.Top().(*Token).Is(Ident) {
+		node, err := p.reduceFunc(p.operators.Pop().(*Token))
+		if err != nil {
+			return err
+		}
+
+		p.operands.Push(node)
 	}
 
-	return p.evaluateFunc(tok)
+	node, err := p.reduceOp(p.tok)
+	if err != nil {
+		return err
+	}
+
+	p.operands.Push(node)
+
+	return nil
 }
 
-func (p *Parser) evaluateFunc(tok *Token) (*big.Rat, error) {
-	var (
-		function function
-		ok       bool
-		i        int
-	)
+// handleColon reduces the then branch of a conditional expression, everything
+// after the matching ?, and replaces the ? with the : so the whole conditional
+// gets reduced once the else branch is parsed.
+func (p *Parser) handleColon() error {
+	for {
+		if p.operators.Empty() || p.operators.Top().(*Token).Is(Lparen) ||
+			p.operators.Top().(*Token).Is(Lbracket) {
+			return fmt.Errorf("Unexpected ‘%s’", p.tok)
+		}
 
-	if function, ok = funcs[tok.Value]; !ok {
-		return nil, fmt.Errorf("Undefined function '%s'", tok)
+		top := p.operators.Pop().(*Token)
+		if top.Is(Question) {
+			break
+		}
+
+		node, err := p.reduce(top)
+		if err != nil {
+			return err
+		}
+
+		p.operands.Push(node)
 	}
 
-	actualArity := p.arity.Pop().(int)
+	p.operators.Push(p.tok)
 
-	// Skip argument validation for variable argument functions
-	if function.arity != -1 && actualArity != function.arity {
-		return nil, fmt.Errorf("Invalid argument count for '%s' (expected %d, got %d)",
-			tok, function.arity, actualArity)
+	return nil
+}
+
+// handleSemicolon ends a row of a matrix literal. It reduces the last element
+// like a comma does, and leaves a row separator among the elements.
+func (p *Parser) handleSemicolon() error {
+	for {
+		if p.operators.Empty() || p.operators.Top().(*Token).Is(Lparen) {
+			return fmt.Errorf("Unexpected ‘%s’", p.tok)
+		}
+
+		top := p.operators.Top().(*Token)
+		if top.Is(Lbracket) {
+			break
+		}
+
+		node, err := p.reduce(p.operators.Pop().(*Token))
+		if err != nil {
+			return err
+		}
+
+		p.operands.Push(node)
 	}
 
-	// Use actual arity for variable argument functions
-	argsNeeded := function.arity
-	if function.arity == -1 {
-		argsNeeded = actualArity
+	if p.afterSeparator() {
+		return fmt.Errorf("Unexpected ‘%s’", p.tok)
 	}
 
-	// Start popping off arguments for the function call
-	args := make([]*big.Rat, argsNeeded)
-	for i = argsNeeded - 1; i >= 0; i-- {
-		if p.operands.Empty() {
-			return nil, ErrMisplacedComma
+	// The separator counts as an element, and so does the next element
+	p.operands.Push(rowSep{})
+	p.arity.Push(p.arity.Pop().(int) + 2)
+
+	return nil
+}
+
+// handleRbracket reduces everything up to the matching [ and builds the
+// matrix literal.
+func (p *Parser) handleRbracket() error {
+	for {
+		if p.operators.Empty() {
+			return ErrUnmatchedBrackets
+		}
+
+		top := p.operators.Pop().(*Token)
+		if top.Is(Lbracket) {
+			break
+		}
+		if top.Is(Lparen) {
+			return ErrUnmatchedParentheses
 		}
 
-		arg, err := p.lookup(p.operands.Pop())
+		node, err := p.reduce(top)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		args[i] = arg
+		p.operands.Push(node)
+	}
+
+	count := p.arity.Pop().(int)
+	if count == 0 {
+		return ErrEmptyMatrix
+	}
+	if p.afterSeparator() {
+		return fmt.Errorf("Unexpected ‘%s’", p.tok)
+	}
+
+	elems := make([]Node, count)
+	for i := count - 1; i >= 0; i-- {
+		if p.operands.Empty() {
+			return ErrMisplacedComma
+		}
+
+		elems[i] = p.operands.Pop().(Node)
+	}
+
+	rows := [][]Node{nil}
+	for _, elem := range elems {
+		if _, ok := elem.(rowSep); ok {
+			rows = append(rows, nil)
+			continue
+		}
+		rows[len(rows)-1] = append(rows[len(rows)-1], elem)
+	}
+
+	for _, row := range rows {
+		if len(row) != len(rows[0]) {
+			return errors.New("Rows of a matrix must have the same length")
+		}
 	}
 
-	return function.fn(args), nil
+	p.operands.Push(&MatrixNode{Rows: rows})
+
+	return nil
+}
+
+// afterSeparator reports whether the token before the current one starts a
+// matrix or separates its elements, so there's an element missing.
+func (p *Parser) afterSeparator() bool {
+	prev := p.Tokens[p.pos-2]
+	return prev.Is(Lbracket) || prev.Is(Comma) || prev.Is(Semicolon)
 }
 
-func (p *Parser) evaluateOp(operator *Token) (*big.Rat, error) {
-	var (
-		result   *big.Rat
-		lhs, rhs *big.Rat
-		err      error
-		lhsToken interface{}
-	)
+// rowSep separates the rows of a matrix literal on the operand stack while
+// it's being parsed.
+type rowSep struct{}
+
+func (rowSep) String() string {
+	return ";"
+}
 
+// reduce gets called when an operator or function call is popped off the
+// operator stack. In case of a function, reduceFunc is called and in case of
+// an operator reduceOp is called.
+func (p *Parser) reduce(tok *Token) (Node, error) {
+	if tok.IsOperator() {
+		return p.reduceOp(tok)
+	}
+
+	return p.reduceFunc(tok)
+}
+
+func (p *Parser) reduceFunc(tok *Token) (Node, error) {
+	arity := p.arity.Pop().(int)
+
+	// Start popping off arguments for the function call
+	args := make([]Node, arity)
+	for i := arity - 1; i >= 0; i-- {
+		if p.operands.Empty() {
+			return nil, ErrMisplacedComma
+		}
+
+		args[i] = p.operands.Pop().(Node)
+	}
+
+	return &CallNode{Name: tok.Value, Args: args}, nil
+}
+
+func (p *Parser) reduceOp(operator *Token) (Node, error) {
 	if p.operands.Empty() {
 		return nil, fmt.Errorf("Unexpected ‘%s’", operator)
 	}
 
-	if rhs, err = p.lookup(p.operands.Pop()); err != nil {
-		return nil, err
-	}
+	rhs := p.operands.Pop().(Node)
 
 	// Unary operators have no left hand side
-	if op := operators[operator.Type]; !op.unary {
+	if op := operators[operator.Type]; op.kind != infix {
+		return &UnaryNode{Op: operator, Operand: rhs}, nil
+	}
+
+	if p.operands.Empty() {
+		return nil, fmt.Errorf("Unexpected ‘%s’", operator)
+	}
+
+	lhs := p.operands.Pop().(Node)
+
+	switch operator.Type {
+	case Question:
+		return nil, fmt.Errorf("Missing ‘:’ after ‘%s’", operator)
+	case Colon:
 		if p.operands.Empty() {
 			return nil, fmt.Errorf("Unexpected ‘%s’", operator)
 		}
-		// Save the token in case of a assignment variable is used and we need
-		// to save the result in a variable
-		lhsToken = p.operands.Pop()
-
-		// Don't lookup the left hand side if = is used so we can do initial
-		// assignment
-		if !operator.Is(Eq) {
-			lhs, err = p.lookup(lhsToken)
-			if err != nil {
-				return nil, err
-			}
-		}
-	}
 
-	result, err = executeExpression(operator, lhs, rhs)
-	if err != nil {
-		return nil, err
+		cond := p.operands.Pop().(Node)
+		return &CondNode{Cond: cond, Then: lhs, Else: rhs}, nil
 	}
 
 	if operator.IsAssignment() {
-		// Save result in variable
-		if val, ok := lhsToken.(*Token); !(ok && val.Is(Ident)) {
+		// Assigning to a function call defines a function, like f(x) = x ^ 2
+		if call, ok := lhs.(*CallNode); ok && operator.Is(Eq) {
+			return defineFunc(call, rhs)
+		}
+
+		ident, ok := lhs.(*IdentNode)
+		if !ok {
 			return nil, ErrAssignToLiteral
 		}
-		p.Variables[lhsToken.(*Token).Value] = result
+
+		return &AssignNode{Op: operator, Name: ident.Name, Value: rhs}, nil
 	}
 
-	return result, nil
+	return &BinaryNode{Op: operator, Lhs: lhs, Rhs: rhs}, nil
+}
+
+// defineFunc converts the call on the left hand side of a function definition
+// to a FuncDefNode. All arguments of the call have to be distinct identifiers,
+// they become the function's parameters.
+func defineFunc(call *CallNode, body Node) (Node, error) {
+	params := make([]string, len(call.Args))
+
+	for i, arg := range call.Args {
+		ident, ok := arg.(*IdentNode)
+		if !ok {
+			return nil, fmt.Errorf("Invalid parameter ‘%s’ for '%s'", arg, call.Name)
+		}
+
+		for _, param := range params[:i] {
+			if param == ident.Name {
+				return nil, fmt.Errorf("Duplicate parameter ‘%s’ for '%s'", param, call.Name)
+			}
+		}
+
+		params[i] = ident.Name
+	}
+
+	return &FuncDefNode{Name: call.Name, Params: params, Body: body}, nil
 }
 
-// Look up a literal. If it's an identifier, check the parser's variables map,
-// otherwise convert the tokenized string to a rational number.
-func (p *Parser) lookup(val interface{}) (*big.Rat, error) {
-	// val can be a token or a rational, if it's a rational it has been already
-	// evaluated and we don't need to do anything
-	if v, ok := val.(*big.Rat); ok {
-		return v, nil
+// operand converts a literal token to a node. Identifiers are looked up at
+// evaluation time, number literals are converted to a rational number right
+// away.
+func (p *Parser) operand(tok *Token) (Node, error) {
+	if tok.Is(Ident) {
+		return &IdentNode{Name: tok.Value}, nil
 	}
 
 	var (
@@ -415,7 +885,6 @@ func (p *Parser) lookup(val interface{}) (*big.Rat, error) {
 		Binary: 2,
 	}
 
-	tok := val.(*Token)
 	switch tok.Type {
 	case Decimal:
 		res, ok = res.SetString(tok.Value)
@@ -433,18 +902,255 @@ func (p *Parser) lookup(val interface{}) (*big.Rat, error) {
 		}
 
 		res.SetInt(tmpInt)
-	case Ident:
-		res, err := p.GetVar(tok.Value)
+	default:
+		return nil, fmt.Errorf("Invalid literal type ‘%s’", tok)
+	}
+
+	return &LiteralNode{Tok: tok, Value: res}, nil
+}
+
+// evaluate walks a syntax tree and returns its result. Evaluating a nil node
+// results in zero, in line with empty expressions like `()`.
+func (p *Parser) evaluate(n Node) (*Complex, error) {
+	switch n := n.(type) {
+	case nil:
+		return realComplex(new(big.Rat)), nil
+	case *LiteralNode:
+		return realComplex(n.Value), nil
+	case *IdentNode:
+		return p.variable(n.Name)
+	case *UnaryNode:
+		return p.evaluateOp(n.Op, nil, n.Operand)
+	case *BinaryNode:
+		if n.Op.Is(LogAnd) || n.Op.Is(LogOr) {
+			return p.evaluateLogic(n)
+		}
+		return p.evaluateOp(n.Op, n.Lhs, n.Rhs)
+	case *CondNode:
+		cond, err := p.evaluate(n.Cond)
 		if err != nil {
 			return nil, err
 		}
+		if isTrue(cond) {
+			return p.evaluate(n.Then)
+		}
+		return p.evaluate(n.Else)
+	case *CallNode:
+		return p.evaluateFunc(n)
+	case *AssignNode:
+		return p.evaluateAssign(n)
+	case *MatrixNode:
+		return p.evaluateMatrix(n)
+	case *FuncDefNode:
+		p.functions[n.Name] = function{arity: len(n.Params), def: n}
+		return nil, nil
+	}
 
-		return res, nil
-	default:
-		return nil, fmt.Errorf("Invalid lookup type ‘%s’", tok)
+	return nil, fmt.Errorf("Invalid node ‘%s’", n)
+}
+
+func (p *Parser) evaluateFunc(call *CallNode) (*Complex, error) {
+	var (
+		function function
+		ok       bool
+	)
+
+	// Functions defined on the parser take precedence over builtin functions
+	if function, ok = p.functions[call.Name]; !ok {
+		if function, ok = funcs[call.Name]; !ok {
+			return nil, fmt.Errorf("Undefined function '%s'", call.Name)
+		}
 	}
 
-	return res, nil
+	actualArity := len(call.Args)
+
+	// Skip argument validation for variable argument functions
+	if function.arity != -1 && actualArity != function.arity {
+		return nil, fmt.Errorf("Invalid argument count for '%s' (expected %d, got %d)",
+			call.Name, function.arity, actualArity)
+	}
+
+	// Lazy functions decide themselves which arguments get evaluated
+	if function.lazy != nil {
+		return function.lazy(p, call.Args)
+	}
+
+	args := make([]*Complex, actualArity)
+	for i, argNode := range call.Args {
+		arg, err := p.evaluate(argNode)
+		if err != nil {
+			return nil, err
+		}
+
+		args[i] = arg
+	}
+
+	if function.def != nil {
+		return p.call(function.def, args)
+	}
+
+	// Functions on numbers are applied to every element of a matrix
+	if !function.matrix && len(args) > 0 && args[0].IsMatrix() {
+		return mapElements(args, func(args []*Complex) (*Complex, error) {
+			return p.apply(call.Name, function, args)
+		})
+	}
+
+	return p.apply(call.Name, function, args)
+}
+
+// apply calls a builtin or registered function with evaluated arguments.
+func (p *Parser) apply(name string, function function, args []*Complex) (*Complex, error) {
+	if function.cfn != nil {
+		if !function.matrix {
+			for _, arg := range args {
+				if arg.IsMatrix() {
+					return nil, fmt.Errorf("Expecting numbers for '%s', got a matrix", name)
+				}
+			}
+		}
+		return function.cfn(p, args)
+	}
+
+	realArgs, err := realArgs(name, args)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := function.fn(p, realArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Guard against functions registered by host programs returning nothing
+	if result == nil {
+		return nil, fmt.Errorf("No result from function '%s'", name)
+	}
+
+	return realComplex(result), nil
+}
+
+// call evaluates the body of a user defined function with its parameters
+// bound to args. The body only sees its own parameters and the parser's
+// variables, not the arguments of the function calling it.
+func (p *Parser) call(def *FuncDefNode, args []*Complex) (*Complex, error) {
+	if p.depth >= maxCallDepth {
+		return nil, ErrMaxCallDepth
+	}
+
+	locals := make(map[string]*Complex, len(args))
+	for i, param := range def.Params {
+		locals[param] = args[i]
+	}
+
+	callerLocals := p.locals
+	p.locals = locals
+	p.depth++
+	defer func() {
+		p.locals = callerLocals
+		p.depth--
+	}()
+
+	return p.evaluate(def.Body)
+}
+
+// variable looks up a variable, checking the arguments of the function being
+// called first.
+func (p *Parser) variable(name string) (*Complex, error) {
+	if val, ok := p.locals[name]; ok {
+		return val, nil
+	}
+
+	return p.Var(name)
+}
+
+// evaluateLogic evaluates && and ||, which skip their right hand side if the
+// left hand side already decides the result.
+func (p *Parser) evaluateLogic(n *BinaryNode) (*Complex, error) {
+	lhs, err := p.evaluate(n.Lhs)
+	if err != nil {
+		return nil, err
+	}
+
+	if isTrue(lhs) == n.Op.Is(LogOr) {
+		return realComplex(boolToRat(isTrue(lhs))), nil
+	}
+
+	rhs, err := p.evaluate(n.Rhs)
+	if err != nil {
+		return nil, err
+	}
+
+	return realComplex(boolToRat(isTrue(rhs))), nil
+}
+
+// isTrue reports whether a value counts as true in conditions, which is any
+// value other than zero. A matrix is true unless all its elements are zero.
+func isTrue(x *Complex) bool {
+	if x.IsMatrix() {
+		for _, row := range x.Matrix {
+			for _, elem := range row {
+				if isTrue(elem) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	return x.Re.Sign() != 0 || !x.IsReal()
+}
+
+// evaluateOp evaluates a unary or binary operator. lhsNode is nil for unary
+// operators.
+func (p *Parser) evaluateOp(operator *Token, lhsNode, rhsNode Node) (*Complex, error) {
+	var lhs *Complex
+
+	if lhsNode != nil {
+		var err error
+		if lhs, err = p.evaluate(lhsNode); err != nil {
+			return nil, err
+		}
+	}
+
+	rhs, err := p.evaluate(rhsNode)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.execute(operator, lhs, rhs)
+}
+
+func (p *Parser) evaluateAssign(assign *AssignNode) (*Complex, error) {
+	var lhs *Complex
+
+	rhs, err := p.evaluate(assign.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't lookup the left hand side if = is used so we can do initial
+	// assignment
+	if !assign.Op.Is(Eq) {
+		if lhs, err = p.variable(assign.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := p.execute(assign.Op, lhs, rhs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Save result in variable, assigning to a parameter inside a function only
+	// changes the parameter
+	if _, ok := p.locals[assign.Name]; ok {
+		p.locals[assign.Name] = result
+	} else {
+		p.setVar(assign.Name, result)
+	}
+
+	return result, nil
 }
 
 func (p *Parser) reset() {
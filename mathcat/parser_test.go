@@ -5,6 +5,7 @@
 package mathcat
 
 import (
+	"math"
 	"math/big"
 	"testing"
 )
@@ -13,7 +14,7 @@ var RatZero = big.NewRat(0, 1)
 
 func TestFloatBitwise(t *testing.T) {
 	badExpressions := []string{
-		"2.4 | -2", "5.5 & 32", "7.7 ^ 2.1", "9 << 20.1", "7 >> 21.2", "~5.3",
+		"2.4 | -2", "5.5 & 32", "7.7 ^^ 2.1", "9 << 20.1", "7 >> 21.2", "~5.3",
 		"2 + 2 = 4",
 	}
 
@@ -25,7 +26,7 @@ func TestFloatBitwise(t *testing.T) {
 	}
 
 	okExpressions := []string{
-		"2.0 | 2", "5 & 32.0", "7 ^ 2", "9 << 20", "7.0 >> -21.0", "~255",
+		"2.0 | 2", "5 & 32.0", "7 ^^ 2", "9 << 20", "7.0 >> -21.0", "~255",
 	}
 
 	for _, expr := range okExpressions {
@@ -83,35 +84,35 @@ func TestNumberLiterals(t *testing.T) {
 
 func TestEval(t *testing.T) {
 	okExpressions := map[string]*big.Rat{
-		"()":                                            RatZero,
-		"-1":                                            big.NewRat(-1, 1),
-		"(1)":                                           big.NewRat(1, 1),
-		"12**12":                                        big.NewRat(8916100448256, 1),
-		"~(~(1))":                                       big.NewRat(1, 1),
-		"1000 > 10":                                     big.NewRat(1, 1),
-		"1000 < 10":                                     RatZero,
-		"55.0 == 55":                                    big.NewRat(1, 1),
-		"55 <= 55":                                      big.NewRat(1, 1),
-		"55 >= 55":                                      big.NewRat(1, 1),
-		"pi * 2 == tau":                                 big.NewRat(1, 1),
-		"-0 > 0":                                        RatZero,
-		"0 < -0":                                        RatZero,
-		"2 != 2":                                        RatZero,
-		"5 % 25":                                        big.NewRat(5, 1),
-		"5.5 % 25":                                      new(big.Rat).SetFloat64(5.5),
-		"((((((((((((1))))))))))))":                     big.NewRat(1, 1),
+		"()":                        RatZero,
+		"-1":                        big.NewRat(-1, 1),
+		"(1)":                       big.NewRat(1, 1),
+		"12^12":                     big.NewRat(8916100448256, 1),
+		"~(~(1))":                   big.NewRat(1, 1),
+		"1000 > 10":                 big.NewRat(1, 1),
+		"1000 < 10":                 RatZero,
+		"55.0 == 55":                big.NewRat(1, 1),
+		"55 <= 55":                  big.NewRat(1, 1),
+		"55 >= 55":                  big.NewRat(1, 1),
+		"pi * 2 == tau":             big.NewRat(1, 1),
+		"-0 > 0":                    RatZero,
+		"0 < -0":                    RatZero,
+		"2 != 2":                    RatZero,
+		"5 % 25":                    big.NewRat(5, 1),
+		"5.5 % 25":                  new(big.Rat).SetFloat64(5.5),
+		"((((((((((((1))))))))))))": big.NewRat(1, 1),
 		"(1 + (2 + (3 + (4 + (5 + (6 + (7)))))))":       big.NewRat(28, 1),
 		"(((((((1) + 2) + 3) + 4) + 5) + 6) + 7)":       big.NewRat(28, 1),
 		"((2 + 2 - 3) / (5 + 5 * 8 / 9)) - (9 + 2)":     big.NewRat(-926, 85),
 		"((2 * 4 - 6 / 3) * (3 * 5 + 8 / 4)) - (2 + 3)": big.NewRat(97, 1),
 		"0xdeadbeef & 0xff000000":                       big.NewRat(3724541952, 1),
 		"325-2*5+2":                                     big.NewRat(317, 1),
-		"3**pi * (6 - -7)":                              big.NewRat(57713016890376237, 140737488355328),
+		"3^pi * (6 - -7)":                               big.NewRat(57713016890376237, 140737488355328),
 		"(2 == 2) == true":                              RatTrue,
 		"(2 == 3) == false":                             RatTrue,
 		"true == 1 & false == 0":                        RatTrue,
 		"false":                                         RatFalse,
-		"33**11":                                        big.NewRat(50542106513726817, 1),
+		"33^11":                                         big.NewRat(50542106513726817, 1),
 	}
 
 	for expr, expected := range okExpressions {
@@ -128,7 +129,7 @@ func TestEval(t *testing.T) {
 
 	badExpressions := []string{
 		"2 / 0", "2 % 0", "+", "2 + 2 +", ")", "(2 + 2 * 8", "@#%@#*%&@#",
-		"a + a", "~~2", "2 == ()", "5 < -", "2 * (9 ** 2))", "5 ~ 3",
+		"a + a", "~~2", "2 == ()", "5 < -", "2 * (9 ^ 2))", "5 ~ 3",
 	}
 
 	for _, expr := range badExpressions {
@@ -139,6 +140,225 @@ func TestEval(t *testing.T) {
 	}
 }
 
+func TestPow(t *testing.T) {
+	exactExpressions := map[string]*big.Rat{
+		"2 ^ -3":            big.NewRat(1, 8),
+		"(2 / 3) ^ -2":      big.NewRat(9, 4),
+		"0 ^ 0":             big.NewRat(1, 1),
+		"8 ^ (1 / 3)":       big.NewRat(2, 1),
+		"4 ^ 1.5":           big.NewRat(8, 1),
+		"(16 / 9) ^ 0.5":    big.NewRat(4, 3),
+		"sqrt(16 / 9)":      big.NewRat(4, 3),
+		"sqrt(0.0625)":      big.NewRat(1, 4),
+		"(-8) ^ (1 / 3)":    big.NewRat(-2, 1),
+		"(-8) ^ (2 / 3)":    big.NewRat(4, 1),
+		"(-32) ^ (-3 / 5)":  big.NewRat(-1, 8),
+		"(3 ^ 40) ^ 0.025":  big.NewRat(3, 1),
+		"(2 ^ 100) ^ -0.01": big.NewRat(1, 2),
+	}
+
+	for expr, expected := range exactExpressions {
+		res, err := Eval(expr)
+		if err != nil {
+			t.Errorf("unexpected error in '%s': %s", expr, err)
+			continue
+		}
+
+		if expected.Cmp(res) != 0 {
+			t.Errorf("wrong result in expression '%s' (expected %s, got %s)",
+				expr, expected, res)
+		}
+	}
+
+	// Roots that aren't rational are still approximated
+	res, err := Eval("(-2) ^ (1 / 3)")
+	if f, _ := res.Float64(); err != nil || math.Abs(f+math.Cbrt(2)) > 1e-12 {
+		t.Errorf("wrong result for '(-2) ^ (1 / 3)' (got %s, %v)", res, err)
+	}
+
+	badExpressions := []string{"0 ^ -1", "(-4) ^ 0.5", "(-8) ^ (1 / 6)"}
+
+	for _, expr := range badExpressions {
+		if _, err := Eval(expr); err == nil {
+			t.Errorf("no error in bad expression '%s'", expr)
+		}
+	}
+}
+
+func TestImplicitMul(t *testing.T) {
+	vars := map[string]*big.Rat{"x": big.NewRat(3, 1)}
+	tests := map[string]*big.Rat{
+		"2x":             big.NewRat(6, 1),
+		"2x^2":           big.NewRat(18, 1),
+		"-2x":            big.NewRat(-6, 1),
+		"-2x^2":          big.NewRat(-18, 1),
+		"-x^2":           big.NewRat(-9, 1),
+		"-2^2":           big.NewRat(-4, 1),
+		"2^3^2":          big.NewRat(512, 1),
+		"2^-3^2":         big.NewRat(1, 512),
+		"(2^3)^2":        big.NewRat(64, 1),
+		"2^-2":           big.NewRat(1, 4),
+		"2^-x":           big.NewRat(1, 8),
+		"3(x + 1)":       big.NewRat(12, 1),
+		"(x + 1)(x - 1)": big.NewRat(8, 1),
+		"(x)2 + 1":       big.NewRat(7, 1),
+		"1/2x":           big.NewRat(3, 2),
+		"x^2x":           big.NewRat(27, 1),
+		"2max(x, 4)":     big.NewRat(8, 1),
+		"2e3x":           big.NewRat(6000, 1),
+		"-(2x)^2":        big.NewRat(-36, 1),
+		"4 - -2x":        big.NewRat(10, 1),
+		"2pi == pi + pi": RatTrue,
+		"2e == e * 2":    RatTrue,
+	}
+
+	for expr, expected := range tests {
+		res, err := Exec(expr, vars)
+		if err != nil {
+			t.Errorf("unexpected error in '%s': %s", expr, err)
+			continue
+		}
+
+		if expected.Cmp(res) != 0 {
+			t.Errorf("wrong result in expression '%s' (expected %s, got %s)", expr, expected, res)
+		}
+	}
+
+	// An identifier followed by parentheses is always a function call
+	badExpressions := []string{"2 3", "x 3", "x(2)", "2x(x + 1)"}
+
+	for _, expr := range badExpressions {
+		if _, err := Exec(expr, vars); err == nil {
+			t.Errorf("no error in bad expression '%s'", expr)
+		}
+	}
+}
+
+func TestPostfix(t *testing.T) {
+	okExpressions := map[string]*big.Rat{
+		"5!":         big.NewRat(120, 1),
+		"0!":         big.NewRat(1, 1),
+		"3!!":        big.NewRat(3, 1),
+		"6!!":        big.NewRat(48, 1),
+		"7!!":        big.NewRat(105, 1),
+		"0!!":        big.NewRat(1, 1),
+		"(3!)!":      big.NewRat(720, 1),
+		"3! !":       big.NewRat(720, 1),
+		"2^3!":       big.NewRat(64, 1),
+		"3!^2":       big.NewRat(36, 1),
+		"-3!":        big.NewRat(-6, 1),
+		"(1 + 2)!":   big.NewRat(6, 1),
+		"sqrt(9)!":   big.NewRat(6, 1),
+		"2 * 3! + 1": big.NewRat(13, 1),
+		"5! == 120":  RatTrue,
+		"5!=120":     RatTrue,
+		"50%":        big.NewRat(1, 2),
+		"20% * 50":   big.NewRat(10, 1),
+		"50% - 3":    big.NewRat(-5, 2),
+		"10%%":       big.NewRat(1, 1000),
+		"2 + 5%":     big.NewRat(41, 20),
+		"7 % 3":      big.NewRat(1, 1),
+		"7 % (2)":    big.NewRat(1, 1),
+	}
+
+	for expr, expected := range okExpressions {
+		res, err := Eval(expr)
+		if err != nil {
+			t.Errorf("unexpected error in '%s': %s", expr, err)
+			continue
+		}
+
+		if expected.Cmp(res) != 0 {
+			t.Errorf("wrong result in expression '%s' (expected %s, got %s)", expr, expected, res)
+		}
+	}
+
+	badExpressions := []string{"!", "5 + !", "(-1)!", "2.5!", "%", "(!)", "max(1, !)", "(-3)!!"}
+
+	for _, expr := range badExpressions {
+		if _, err := Eval(expr); err == nil {
+			t.Errorf("no error in bad expression '%s'", expr)
+		}
+	}
+
+	e, _ := Compile("(-3)! + (3!)! - x%")
+	if e.String() != "(-3)! + (3!)! - x%" {
+		t.Errorf("wrong string representation '%s'", e)
+	}
+}
+
+func TestConditional(t *testing.T) {
+	okExpressions := map[string]*big.Rat{
+		"1 && 0":                         RatFalse,
+		"1 && 2":                         RatTrue,
+		"0 || 3":                         RatTrue,
+		"0 || 0":                         RatFalse,
+		"!0":                             RatTrue,
+		"!5":                             RatFalse,
+		"!(1 && 0)":                      RatTrue,
+		"!0 && 0":                        RatFalse,
+		"1 < 2 && 2 < 3":                 RatTrue,
+		"1 || 0 && 0":                    RatTrue,
+		"0 && 1 / 0":                     RatFalse,
+		"1 || 1 / 0":                     RatTrue,
+		"1 ? 2 : 1 / 0":                  big.NewRat(2, 1),
+		"0 ? 1 / 0 : 3":                  big.NewRat(3, 1),
+		"if(0, 1 / 0, 3)":                big.NewRat(3, 1),
+		"if(2 > 1, -1, 1 / 0)":           big.NewRat(-1, 1),
+		"1 ? 2 : 3 + 1":                  big.NewRat(2, 1),
+		"(1 ? 2 : 3) + 1":                big.NewRat(3, 1),
+		"2 + (0 ? 1 : 2) * 3":            big.NewRat(8, 1),
+		"0 ? 1 : 0 ? 2 : 3":              big.NewRat(3, 1),
+		"1 ? 0 ? 1 : 2 : 3":              big.NewRat(2, 1),
+		"a = 0 ? 1 : 2":                  big.NewRat(2, 1),
+		"max(1 ? -1 : 1, -2)":            big.NewRat(-1, 1),
+		"3! ? 5!=120 : 0":                RatTrue,
+		"sqrt(-1) ? 1 : 0":               big.NewRat(1, 1),
+		"(1 > 2 ? 1 : 2) == 2 && !0":     RatTrue,
+		"0 ? 1 : 2 ? 3 : 4 ? 5 : 6":      big.NewRat(3, 1),
+		"max(0 || 0 ? 7 : 8, 1 ? 2 : 3)": big.NewRat(8, 1),
+	}
+
+	for expr, expected := range okExpressions {
+		res, err := Eval(expr)
+		if err != nil {
+			t.Errorf("unexpected error in '%s': %s", expr, err)
+			continue
+		}
+
+		if expected.Cmp(res) != 0 {
+			t.Errorf("wrong result in expression '%s' (expected %s, got %s)", expr, expected, res)
+		}
+	}
+
+	e, err := Compile("x < 0 ? -x : x^2")
+	if err != nil {
+		t.Fatalf("unexpected error compiling piecewise function: %s", err)
+	}
+
+	for x, expected := range map[int64]int64{-3: 3, 0: 0, 3: 9} {
+		res, err := e.Eval(map[string]*big.Rat{"x": big.NewRat(x, 1)})
+		if err != nil || res.Cmp(big.NewRat(expected, 1)) != 0 {
+			t.Errorf("wrong result for x = %d (expected %d, got %s)", x, expected, res)
+		}
+	}
+
+	if e.String() != "x < 0 ? -x : x ^ 2" {
+		t.Errorf("wrong string representation '%s'", e)
+	}
+
+	badExpressions := []string{
+		"1 ? 2", "1 : 2", "? 1 : 2", "(1 ? 2) : 3", "if(1, 2)", "1 ?: 2",
+		"1 ? 2 : 3 : 4", "1 &&", "|| 1", "1 ? 1 / 0 : 2",
+	}
+
+	for _, expr := range badExpressions {
+		if _, err := Eval(expr); err == nil {
+			t.Errorf("no error in bad expression '%s'", expr)
+		}
+	}
+}
+
 func TestExec(t *testing.T) {
 	type execTest struct {
 		expr     string
@@ -161,7 +381,7 @@ func TestExec(t *testing.T) {
 			"b5":  big.NewRat(3, 1),
 			"pi3": big.NewRat(3, 1)},
 			big.NewRat(10, 1)},
-		{"Å ** Å", map[string]*big.Rat{"Å": big.NewRat(1, 1)}, big.NewRat(1, 1)},
+		{"Å ^ Å", map[string]*big.Rat{"Å": big.NewRat(1, 1)}, big.NewRat(1, 1)},
 	}
 
 	for _, test := range okExpressions {
@@ -201,3 +421,39 @@ func TestGetVar(t *testing.T) {
 		t.Error("GetVar failed: " + err.Error())
 	}
 }
+
+func TestVar(t *testing.T) {
+	p := New()
+	p.Variables["x"] = big.NewRat(3, 2)
+	if res, err := p.Run("2x"); err != nil || res.Cmp(big.NewRat(3, 1)) != 0 {
+		t.Errorf("wrong result of 2x with x set in Variables (got %s, %v)", res, err)
+	}
+
+	if _, err := p.RunComplex("z = 2 + 3i"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.Variables["z"]; ok {
+		t.Error("complex variable z is in Variables")
+	}
+	if z, err := p.Var("z"); err != nil || z.String() != "2 + 3i" {
+		t.Errorf("wrong value of z (got %s, %v)", z, err)
+	}
+	if _, err := p.GetVar("z"); err == nil {
+		t.Error("no error getting complex variable z as a real number")
+	}
+
+	// Setting a variable to a real number moves it to Variables
+	if err := p.SetVar("z", NewComplex(big.NewRat(5, 1), new(big.Rat))); err != nil {
+		t.Fatal(err)
+	}
+	if z, ok := p.Variables["z"]; !ok || z.Cmp(big.NewRat(5, 1)) != 0 {
+		t.Errorf("wrong value of z in Variables (got %s)", z)
+	}
+	if res, err := p.Run("z + 1"); err != nil || res.Cmp(big.NewRat(6, 1)) != 0 {
+		t.Errorf("wrong result of z + 1 (got %s, %v)", res, err)
+	}
+
+	if err := p.SetVar("2a", NewComplex(new(big.Rat), new(big.Rat))); err == nil {
+		t.Error("no error setting variable 2a")
+	}
+}
@@ -0,0 +1,485 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"strings"
+)
+
+// LaTeX parses expr and renders it as LaTeX math, without the surrounding $.
+//
+// Example:
+//
+//	s, err := mathcat.LaTeX("sqrt(x) / 2 + sin(x)^2")
+//	fmt.Println(s) // \frac{\sqrt{x}}{2} + \sin\left(x\right)^{2}
+func LaTeX(expr string) (string, error) {
+	e, err := Compile(expr)
+	if err != nil {
+		return "", err
+	}
+
+	return e.LaTeX(), nil
+}
+
+// MathML parses expr and renders it as a MathML <math> element.
+func MathML(expr string) (string, error) {
+	e, err := Compile(expr)
+	if err != nil {
+		return "", err
+	}
+
+	return e.MathML(), nil
+}
+
+// LaTeX renders e as LaTeX math, like the package LaTeX.
+func (e *Expr) LaTeX() string {
+	if e.Root == nil {
+		return ""
+	}
+
+	return render(latex, e.Root)
+}
+
+// MathML renders e as a MathML <math> element, like the package MathML.
+func (e *Expr) MathML() string {
+	if e.Root == nil {
+		return ""
+	}
+
+	return `<math xmlns="http://www.w3.org/1998/Math/MathML">` + render(mathml, e.Root) + "</math>"
+}
+
+// markup is a format expressions are rendered to. The tree is walked the same
+// way for every format, only the pieces it's put together from differ.
+type markup struct {
+	number, ident, operator, function func(s string) string
+
+	// row puts parts after each other, attach does the same without the
+	// space row may put in between, and implicit is an implicit
+	// multiplication like 2x
+	row              func(parts ...string) string
+	attach, implicit func(a, b string) string
+
+	// call is a function name followed by its arguments
+	call func(name, args string) string
+
+	// list separates the arguments of a function with commas
+	list func(args []string) string
+
+	// fence wraps s in delimiters, named like in LaTeX: (, |, \lfloor and
+	// so on. An open delimiter of . leaves the left side open.
+	fence func(open, close, s string) string
+
+	frac, sup, sub func(a, b string) string
+	sqrt           func(s string) string
+
+	// limits puts from below and to above a big operator like a sum
+	limits func(op, from, to string) string
+
+	// cases is a piecewise function, the rows are a value and its condition
+	cases func(rows [][2]string, otherwise string) string
+
+	// matrix is a matrix in brackets
+	matrix func(rows [][]string) string
+
+	// symbols holds the operators, and the big operators of sum, prod and
+	// integrate under their function name
+	symbols map[interface{}]string
+
+	// space is a thin space, like between an integral and its dx
+	space string
+}
+
+// compoundOps are the operators of the compound assignments, a += b is
+// rendered as a = a + b.
+var compoundOps = map[TokenType]TokenType{
+	AddEq: Add, SubEq: Sub, DivEq: Div, MulEq: Mul, PowEq: Pow, RemEq: Rem,
+	AndEq: And, OrEq: Or, XorEq: Xor, LshEq: Lsh, RshEq: Rsh,
+}
+
+// functionNames are builtin functions that are written differently in math.
+var functionNames = map[string]string{
+	"asin": "arcsin", "acos": "arccos", "atan": "arctan",
+	"acsc": "arccsc", "asec": "arcsec", "acot": "arccot",
+}
+
+// greekLetters are the identifiers that are written as a greek letter.
+var greekLetters = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ",
+	"zeta": "ζ", "eta": "η", "theta": "θ", "iota": "ι", "kappa": "κ",
+	"lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ", "pi": "π", "rho": "ρ",
+	"sigma": "σ", "tau": "τ", "upsilon": "υ", "phi": "ϕ", "chi": "χ",
+	"psi": "ψ", "omega": "ω", "Gamma": "Γ", "Delta": "Δ", "Theta": "Θ",
+	"Lambda": "Λ", "Xi": "Ξ", "Pi": "Π", "Sigma": "Σ", "Upsilon": "Υ",
+	"Phi": "Φ", "Psi": "Ψ", "Omega": "Ω",
+}
+
+// render renders n in format m.
+func render(m *markup, n Node) string {
+	switch n := n.(type) {
+	case *LiteralNode:
+		// 2e-10 is written as 2 * 10 ^ -10
+		if i := strings.IndexAny(n.Tok.Value, "eE"); i > 0 && n.Tok.Is(Decimal) {
+			return m.row(m.number(n.Tok.Value[:i]), m.operator(m.symbols[Mul]),
+				m.sup(m.number("10"), m.number(n.Tok.Value[i+1:])))
+		}
+		return m.number(n.Tok.Value)
+	case *IdentNode:
+		return m.ident(n.Name)
+	case *UnaryNode:
+		op := operators[n.Op.Type]
+		_, nested := n.Operand.(*UnaryNode)
+		operand := renderParen(m, n.Operand, nested || renderPrec(n.Operand) < op.prec)
+
+		if op.kind == postfix {
+			return m.attach(operand, m.operator(m.symbols[n.Op.Type]))
+		}
+		return m.attach(m.operator(m.symbols[n.Op.Type]), operand)
+	case *BinaryNode:
+		return renderBinary(m, n)
+	case *CondNode:
+		return renderCases(m, n.Cond, n.Then, n.Else)
+	case *CallNode:
+		return renderCall(m, n)
+	case *AssignNode:
+		value := n.Value
+		if op, ok := compoundOps[n.Op.Type]; ok {
+			value = &BinaryNode{Op: &Token{Type: op}, Lhs: &IdentNode{Name: n.Name}, Rhs: n.Value}
+		}
+		return m.row(m.ident(n.Name), m.operator(m.symbols[Eq]), render(m, value))
+	case *FuncDefNode:
+		params := make([]string, len(n.Params))
+		for i, param := range n.Params {
+			params[i] = m.ident(param)
+		}
+		return m.row(m.call(m.function(n.Name), m.fence("(", ")", m.list(params))),
+			m.operator(m.symbols[Eq]), render(m, n.Body))
+	case *MatrixNode:
+		rows := make([][]string, len(n.Rows))
+		for i, row := range n.Rows {
+			rows[i] = make([]string, len(row))
+			for j, elem := range row {
+				rows[i][j] = render(m, elem)
+			}
+		}
+		return m.matrix(rows)
+	}
+
+	return ""
+}
+
+// renderPrec is like precedence, but fractions never need parentheses, except
+// as the base of a power.
+func renderPrec(n Node) int {
+	if b, ok := n.(*BinaryNode); ok && b.Op.Is(Div) {
+		return atomPrec
+	}
+
+	return precedence(n)
+}
+
+// renderParen renders n, wrapped in parentheses if wrap is set.
+func renderParen(m *markup, n Node, wrap bool) string {
+	if wrap {
+		return m.fence("(", ")", render(m, n))
+	}
+
+	return render(m, n)
+}
+
+func renderBinary(m *markup, n *BinaryNode) string {
+	switch n.Op.Type {
+	case Div:
+		return m.frac(render(m, n.Lhs), render(m, n.Rhs))
+	case Pow:
+		// The exponent is grouped by being raised, only the base can need
+		// parentheses
+		base := renderParen(m, n.Lhs, precedence(n.Lhs) <= operators[Pow].prec)
+		return m.sup(base, render(m, n.Rhs))
+	}
+
+	op := operators[n.Op.Type]
+	lhs, rhs := renderPrec(n.Lhs), renderPrec(n.Rhs)
+	wrapRhs := rhs < op.prec || rhs == op.prec && op.assoc == AssocLeft
+	left := renderParen(m, n.Lhs, lhs < op.prec || lhs == op.prec && op.assoc == AssocRight)
+	right := renderParen(m, n.Rhs, wrapRhs)
+
+	// 2x rather than 2 * x
+	if n.Op.Is(Mul) && juxtapose(n.Lhs, n.Rhs, wrapRhs) {
+		return m.implicit(left, right)
+	}
+
+	return m.row(left, m.operator(m.symbols[n.Op.Type]), right)
+}
+
+// juxtapose reports whether lhs * rhs can be written without the operator,
+// like 2x, 2 sin(x) and 2(x + 1). A number followed by a number can't.
+func juxtapose(lhs, rhs Node, wrapped bool) bool {
+	lit, ok := lhs.(*LiteralNode)
+	if !ok || !lit.Tok.Is(Decimal) || strings.ContainsAny(lit.Tok.Value, "eE") {
+		return false
+	}
+	if wrapped {
+		return true
+	}
+
+	switch rhs := rhs.(type) {
+	case *IdentNode, *CallNode:
+		return true
+	case *BinaryNode:
+		if rhs.Op.Is(Pow) {
+			return juxtapose(lhs, rhs.Lhs, precedence(rhs.Lhs) <= operators[Pow].prec)
+		}
+	}
+
+	return false
+}
+
+// renderCases renders a conditional as a piecewise function. Conditionals in
+// the else branch become more rows.
+func renderCases(m *markup, cond, then, els Node) string {
+	var rows [][2]string
+
+	for {
+		rows = append(rows, [2]string{render(m, then), render(m, cond)})
+
+		next, ok := els.(*CondNode)
+		if !ok {
+			return m.cases(rows, render(m, els))
+		}
+		cond, then, els = next.Cond, next.Then, next.Else
+	}
+}
+
+// renderBody renders the body of a sum, product, integral or derivative, in
+// parentheses if it's a sum itself.
+func renderBody(m *markup, n Node) string {
+	return renderParen(m, n, renderPrec(n) < operators[Mul].prec)
+}
+
+func renderCall(m *markup, n *CallNode) string {
+	args := make([]string, len(n.Args))
+	for i, arg := range n.Args {
+		args[i] = render(m, arg)
+	}
+
+	switch {
+	case n.Name == "sqrt" && len(args) == 1:
+		return m.sqrt(args[0])
+	case n.Name == "abs" && len(args) == 1:
+		return m.fence("|", "|", args[0])
+	case n.Name == "floor" && len(args) == 1:
+		return m.fence(`\lfloor`, `\rfloor`, args[0])
+	case n.Name == "ceil" && len(args) == 1:
+		return m.fence(`\lceil`, `\rceil`, args[0])
+	case n.Name == "exp" && len(args) == 1:
+		return m.sup(m.ident("e"), args[0])
+	case n.Name == "fact" && len(args) == 1:
+		return render(m, &UnaryNode{Op: &Token{Type: Fact}, Operand: n.Args[0]})
+	case n.Name == "logn" && len(args) == 2:
+		return m.call(m.sub(m.function("log"), args[0]), m.fence("(", ")", args[1]))
+	case n.Name == "if" && len(args) == 3:
+		return renderCases(m, n.Args[0], n.Args[1], n.Args[2])
+	case (n.Name == "sum" || n.Name == "prod") && isFold(n):
+		from := m.row(args[0], m.operator(m.symbols[Eq]), args[1])
+		return m.row(m.limits(m.symbols[n.Name], from, args[2]), renderBody(m, n.Args[3]))
+	case n.Name == "integrate" && len(args) == 4:
+		return m.row(m.limits(m.symbols[n.Name], args[2], args[3]), renderBody(m, n.Args[0]),
+			m.space, m.implicit(m.ident("d"), args[1]))
+	case n.Name == "deriv" && len(args) == 3:
+		// d/dx f, evaluated at x = a
+		d := m.frac(m.ident("d"), m.implicit(m.ident("d"), args[1]))
+		return m.sub(m.fence(".", "|", m.row(d, renderBody(m, n.Args[0]))),
+			m.row(args[1], m.operator(m.symbols[Eq]), args[2]))
+	}
+
+	name := n.Name
+	if math, ok := functionNames[name]; ok {
+		name = math
+	}
+
+	return m.call(m.function(name), m.fence("(", ")", m.list(args)))
+}
+
+// latexFunctions are the functions LaTeX has a command for, the rest are
+// written with \operatorname.
+var latexFunctions = map[string]bool{
+	"sin": true, "cos": true, "tan": true, "csc": true, "sec": true, "cot": true,
+	"arcsin": true, "arccos": true, "arctan": true, "ln": true, "log": true,
+	"exp": true, "max": true, "min": true, "gcd": true, "arg": true,
+}
+
+// latexName writes a multi letter name upright with command, escaping the
+// underscores.
+func latexName(command, name string) string {
+	return command + "{" + strings.Replace(name, "_", `\_`, -1) + "}"
+}
+
+// latexAttach puts b right after a, with a space in between if a is a command
+// like \lnot that would otherwise run into b.
+func latexAttach(a, b string) string {
+	name := strings.TrimRightFunc(a, isLetter)
+	if name != a && strings.HasSuffix(name, `\`) && b != "" && isLetter(rune(b[0])) {
+		return a + " " + b
+	}
+
+	return a + b
+}
+
+func isLetter(c rune) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+var latex = &markup{
+	number: func(s string) string { return s },
+	ident: func(name string) string {
+		if _, ok := greekLetters[name]; ok {
+			return `\` + name
+		}
+		if len([]rune(name)) == 1 {
+			return name
+		}
+		return latexName(`\mathrm`, name)
+	},
+	operator: func(s string) string { return s },
+	function: func(name string) string {
+		if latexFunctions[name] {
+			return `\` + name
+		}
+		if len([]rune(name)) == 1 {
+			return name
+		}
+		return latexName(`\operatorname`, name)
+	},
+	row: func(parts ...string) string {
+		return strings.Join(parts, " ")
+	},
+	attach:   latexAttach,
+	implicit: latexAttach,
+	call:     func(name, args string) string { return name + args },
+	list:     func(args []string) string { return strings.Join(args, ", ") },
+	fence: func(open, close, s string) string {
+		return latexAttach(`\left`+open, s) + `\right` + close
+	},
+	frac:   func(a, b string) string { return `\frac{` + a + "}{" + b + "}" },
+	sup:    func(a, b string) string { return a + "^{" + b + "}" },
+	sub:    func(a, b string) string { return a + "_{" + b + "}" },
+	sqrt:   func(s string) string { return `\sqrt{` + s + "}" },
+	limits: func(op, from, to string) string { return op + "_{" + from + "}^{" + to + "}" },
+	cases: func(rows [][2]string, otherwise string) string {
+		var b strings.Builder
+		b.WriteString(`\begin{cases}`)
+		for _, row := range rows {
+			b.WriteString(" " + row[0] + ` & \text{if } ` + row[1] + ` \\`)
+		}
+		b.WriteString(" " + otherwise + ` & \text{otherwise} \end{cases}`)
+		return b.String()
+	},
+	matrix: func(rows [][]string) string {
+		lines := make([]string, len(rows))
+		for i, row := range rows {
+			lines[i] = strings.Join(row, " & ")
+		}
+		return `\begin{bmatrix} ` + strings.Join(lines, ` \\ `) + ` \end{bmatrix}`
+	},
+	symbols: map[interface{}]string{
+		Add: "+", Sub: "-", UnaryMin: "-", Mul: `\cdot`, Rem: `\bmod`, LeftDiv: `\backslash`,
+		Fact: "!", DoubleFact: "!!", Percent: `\%`,
+		And: `\mathbin{\&}`, Or: `\mathbin{|}`, Xor: `\oplus`, Lsh: `\ll`, Rsh: `\gg`,
+		Not: `\mathord{\sim}`, Eq: "=", EqEq: "=", NotEq: `\neq`,
+		Gt: ">", GtEq: `\geq`, Lt: "<", LtEq: `\leq`,
+		LogAnd: `\land`, LogOr: `\lor`, LogNot: `\lnot`,
+		"sum": `\sum`, "prod": `\prod`, "integrate": `\int`,
+	},
+	space: `\,`,
+}
+
+// xmlEscaper escapes the characters that are special in MathML.
+var xmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// mathmlFences are the MathML characters of the LaTeX delimiters.
+var mathmlFences = map[string]string{
+	`\lfloor`: "⌊", `\rfloor`: "⌋", `\lceil`: "⌈", `\rceil`: "⌉", ".": "",
+}
+
+func mathmlRow(parts ...string) string {
+	if len(parts) == 1 {
+		return parts[0]
+	}
+
+	return "<mrow>" + strings.Join(parts, "") + "</mrow>"
+}
+
+var mathml = &markup{
+	number: func(s string) string { return "<mn>" + xmlEscaper.Replace(s) + "</mn>" },
+	ident: func(name string) string {
+		if letter, ok := greekLetters[name]; ok {
+			return "<mi>" + letter + "</mi>"
+		}
+		return "<mi>" + xmlEscaper.Replace(name) + "</mi>"
+	},
+	operator: func(s string) string { return "<mo>" + xmlEscaper.Replace(s) + "</mo>" },
+	function: func(name string) string { return "<mi>" + xmlEscaper.Replace(name) + "</mi>" },
+	row:      mathmlRow,
+	attach:   func(a, b string) string { return mathmlRow(a, b) },
+	implicit: func(a, b string) string {
+		// U+2062 is the invisible times
+		return mathmlRow(a, "<mo>&#x2062;</mo>", b)
+	},
+	call: func(name, args string) string {
+		// U+2061 is the invisible function application
+		return mathmlRow(name, "<mo>&#x2061;</mo>", args)
+	},
+	list: func(args []string) string {
+		return mathmlRow(strings.Join(args, "<mo>,</mo>"))
+	},
+	fence: func(open, close, s string) string {
+		if fence, ok := mathmlFences[open]; ok {
+			open = fence
+		}
+		if fence, ok := mathmlFences[close]; ok {
+			close = fence
+		}
+
+		if open == "" {
+			return "<mrow>" + s + "<mo>" + close + "</mo></mrow>"
+		}
+		return "<mrow><mo>" + open + "</mo>" + s + "<mo>" + close + "</mo></mrow>"
+	},
+	frac:   func(a, b string) string { return "<mfrac>" + a + b + "</mfrac>" },
+	sup:    func(a, b string) string { return "<msup>" + a + b + "</msup>" },
+	sub:    func(a, b string) string { return "<msub>" + a + b + "</msub>" },
+	sqrt:   func(s string) string { return "<msqrt>" + s + "</msqrt>" },
+	limits: func(op, from, to string) string { return "<munderover><mo>" + op + "</mo>" + from + to + "</munderover>" },
+	cases: func(rows [][2]string, otherwise string) string {
+		var b strings.Builder
+		b.WriteString(`<mrow><mo>{</mo><mtable columnalign="left">`)
+		for _, row := range rows {
+			b.WriteString("<mtr><mtd>" + row[0] + "</mtd><mtd><mtext>if </mtext>" + row[1] + "</mtd></mtr>")
+		}
+		b.WriteString("<mtr><mtd>" + otherwise + "</mtd><mtd><mtext>otherwise</mtext></mtd></mtr>")
+		b.WriteString("</mtable></mrow>")
+		return b.String()
+	},
+	matrix: func(rows [][]string) string {
+		var b strings.Builder
+		b.WriteString("<mrow><mo>[</mo><mtable>")
+		for _, row := range rows {
+			b.WriteString("<mtr><mtd>" + strings.Join(row, "</mtd><mtd>") + "</mtd></mtr>")
+		}
+		b.WriteString("</mtable><mo>]</mo></mrow>")
+		return b.String()
+	},
+	symbols: map[interface{}]string{
+		Add: "+", Sub: "−", UnaryMin: "−", Mul: "⋅", Rem: "mod", LeftDiv: "\\",
+		Fact: "!", DoubleFact: "!!", Percent: "%",
+		And: "&", Or: "|", Xor: "⊕", Lsh: "≪", Rsh: "≫",
+		Not: "~", Eq: "=", EqEq: "=", NotEq: "≠",
+		Gt: ">", GtEq: "≥", Lt: "<", LtEq: "≤",
+		LogAnd: "∧", LogOr: "∨", LogNot: "¬",
+		"sum": "∑", "prod": "∏", "integrate": "∫",
+	},
+	space: `<mspace width="0.17em"/>`,
+}
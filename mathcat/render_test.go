@@ -0,0 +1,97 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLaTeX(t *testing.T) {
+	representations := map[string]string{
+		"sqrt(x) / 2 + sin(x)^2":       `\frac{\sqrt{x}}{2} + \sin\left(x\right)^{2}`,
+		"2x + 3":                       "2x + 3",
+		"2 * 3":                        `2 \cdot 3`,
+		"-x^2":                         "-x^{2}",
+		"(-x)^2":                       `\left(-x\right)^{2}`,
+		"(1/2)^x":                      `\left(\frac{1}{2}\right)^{x}`,
+		"x^(1/2)":                      `x^{\frac{1}{2}}`,
+		"(a + b) / (c * d)":            `\frac{a + b}{c \cdot d}`,
+		"a - (b - c)":                  `a - \left(b - c\right)`,
+		"!x && y || z":                 `\lnot x \land y \lor z`,
+		"5! + 50%":                     `5! + 50\%`,
+		"a <= b":                       `a \leq b`,
+		"a += 3":                       "a = a + 3",
+		"f(x, y) = x^2 + y":            `f\left(x, y\right) = x^{2} + y`,
+		"x < 0 ? -x : x^2":             `\begin{cases} -x & \text{if } x < 0 \\ x^{2} & \text{otherwise} \end{cases}`,
+		"if(x > 5, 5, 0)":              `\begin{cases} 5 & \text{if } x > 5 \\ 0 & \text{otherwise} \end{cases}`,
+		"sum(a, b, c) + mean([a, b])":  `\operatorname{sum}\left(a, b, c\right) + \operatorname{mean}\left(\begin{bmatrix} a & b \end{bmatrix}\right)`,
+		"sum(k, 1, n, k^2 + 1)":        `\sum_{k = 1}^{n} \left(k^{2} + 1\right)`,
+		"sum(i, 1, 2, 3)":              `\sum_{i = 1}^{2} 3`,
+		"integrate(sin(t), t, 0, pi)":  `\int_{0}^{\pi} \sin\left(t\right) \, dt`,
+		"deriv(x^2, x, 3)":             `\left.\frac{d}{dx} x^{2}\right|_{x = 3}`,
+		"abs(x - 1) + floor(x)":        `\left|x - 1\right| + \left\lfloor x\right\rfloor`,
+		"logn(2, x) + ln(x) + asin(x)": `\log_{2}\left(x\right) + \ln\left(x\right) + \arcsin\left(x\right)`,
+		"exp(-x^2)":                    "e^{-x^{2}}",
+		"foo_bar(x)":                   `\operatorname{foo\_bar}\left(x\right)`,
+		"theta * speed":                `\theta \cdot \mathrm{speed}`,
+		"2e-10":                        `2 \cdot 10^{-10}`,
+		"3 % 2":                        `3 \bmod 2`,
+		"[1, x; -2, 3 / 4] \\ b":       `\begin{bmatrix} 1 & x \\ -2 & \frac{3}{4} \end{bmatrix} \backslash b`,
+	}
+
+	for expr, expected := range representations {
+		res, err := LaTeX(expr)
+		if err != nil {
+			t.Errorf("unexpected error rendering '%s': %s", expr, err)
+			continue
+		}
+
+		if res != expected {
+			t.Errorf("wrong LaTeX for '%s' (expected '%s', got '%s')", expr, expected, res)
+		}
+	}
+
+	if _, err := LaTeX("2 +"); err == nil {
+		t.Errorf("no error rendering bad expression")
+	}
+}
+
+func TestMathML(t *testing.T) {
+	representations := map[string]string{
+		"x / 2":      "<mfrac><mi>x</mi><mn>2</mn></mfrac>",
+		"2x":         "<mrow><mn>2</mn><mo>&#x2062;</mo><mi>x</mi></mrow>",
+		"x ^ 2 < pi": "<mrow><msup><mi>x</mi><mn>2</mn></msup><mo>&lt;</mo><mi>π</mi></mrow>",
+		"sqrt(-x)":   "<msqrt><mrow><mo>−</mo><mi>x</mi></mrow></msqrt>",
+		"sin(x)":     "<mrow><mi>sin</mi><mo>&#x2061;</mo><mrow><mo>(</mo><mi>x</mi><mo>)</mo></mrow></mrow>",
+		"a && b":     "<mrow><mi>a</mi><mo>∧</mo><mi>b</mi></mrow>",
+		"[1, 2; x, 4]": "<mrow><mo>[</mo><mtable><mtr><mtd><mn>1</mn></mtd><mtd><mn>2</mn></mtd></mtr>" +
+			"<mtr><mtd><mi>x</mi></mtd><mtd><mn>4</mn></mtd></mtr></mtable><mo>]</mo></mrow>",
+		"max(a, b)": "<mrow><mi>max</mi><mo>&#x2061;</mo><mrow><mo>(</mo>" +
+			"<mi>a</mi><mo>,</mo><mi>b</mi><mo>)</mo></mrow></mrow>",
+	}
+
+	for expr, expected := range representations {
+		res, err := MathML(expr)
+		if err != nil {
+			t.Errorf("unexpected error rendering '%s': %s", expr, err)
+			continue
+		}
+
+		expected = `<math xmlns="http://www.w3.org/1998/Math/MathML">` + expected + "</math>"
+		if res != expected {
+			t.Errorf("wrong MathML for '%s' (expected '%s', got '%s')", expr, expected, res)
+		}
+	}
+
+	// Every element that's opened has to be closed
+	res, _ := MathML("x < 0 ? -x : abs(x) + integrate(t, t, 0, x) + sum(k, 1, 3, k!)")
+	for _, tag := range []string{"mrow", "mtable", "mtr", "mtd", "munderover", "mi", "mn", "mo"} {
+		if open, close := strings.Count(res, "<"+tag+">")+strings.Count(res, "<"+tag+" "),
+			strings.Count(res, "</"+tag+">"); open != close {
+			t.Errorf("unbalanced <%s> in '%s'", tag, res)
+		}
+	}
+}
@@ -0,0 +1,377 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"math/big"
+)
+
+// maxFoldExp limits the powers of constants that get folded, so 10 ^ 100000
+// isn't expanded into a literal with a hundred thousand digits.
+const maxFoldExp = 1024
+
+// Simplify parses expr and returns it simplified: constants are folded, like
+// terms and factors are collected and identities like x * 1, x + 0 and x ^ 1
+// are removed. The expression is assumed to be defined, so x / x becomes 1.
+//
+// Example:
+//
+//	e, err := mathcat.Simplify("2x + 3 * x * 1 - 0 + 2^3")
+//	fmt.Println(e) // 5 * x + 8
+func Simplify(expr string) (*Expr, error) {
+	e, err := Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.Simplify(), nil
+}
+
+// Simplify returns a simplified copy of e, like the package Simplify.
+func (e *Expr) Simplify() *Expr {
+	if e.Root == nil {
+		return &Expr{}
+	}
+
+	return &Expr{Root: simplify(e.Root)}
+}
+
+// simplify returns a simplified copy of n.
+func simplify(n Node) Node {
+	if n == nil {
+		return nil
+	}
+
+	if lit, ok := n.(*LiteralNode); ok {
+		return number(lit.Value)
+	}
+
+	n, _ = mapNode(n, func(child Node) (Node, error) {
+		return simplify(child), nil
+	})
+
+	switch node := n.(type) {
+	case *UnaryNode:
+		switch node.Op.Type {
+		case UnaryMin:
+			return simplifySum(n)
+		case Percent:
+			return simplifyProduct(divNode(node.Operand, number(big.NewRat(100, 1))))
+		}
+	case *BinaryNode:
+		switch node.Op.Type {
+		case Add, Sub:
+			return simplifySum(n)
+		case Mul, Div:
+			return simplifyProduct(n)
+		case Pow:
+			return simplifyPow(node)
+		}
+	case *CondNode:
+		if cond, ok := numberValue(node.Cond); ok {
+			if cond.Sign() != 0 {
+				return node.Then
+			}
+			return node.Else
+		}
+	case *FuncDefNode:
+		return &FuncDefNode{Name: node.Name, Params: node.Params, Body: simplify(node.Body)}
+	}
+
+	return n
+}
+
+// factor is a base raised to a constant power, like the x ^ 2 in 3 * x ^ 2.
+type factor struct {
+	base Node
+	exp  *big.Rat
+}
+
+// product is a constant times a list of factors in the order they first
+// appeared in. Factors with the same base are merged. Matrices don't commute,
+// so products of more than one matrix aren't collected at all.
+type product struct {
+	coeff    *big.Rat
+	factors  []factor
+	matrices int
+}
+
+func newProduct() *product {
+	return &product{coeff: big.NewRat(1, 1)}
+}
+
+// add multiplies p by n raised to exp. Products and quotients are only
+// split up for integer powers, (x * y) ^ (1 / 2) is kept whole. It returns
+// false if n divides by zero or multiplies matrices, then n is left as is.
+func (p *product) add(n Node, exp *big.Rat) bool {
+	if x, ok := numberValue(n); ok {
+		if res, ok := foldPow(x, exp); ok {
+			p.coeff.Mul(p.coeff, res)
+			return true
+		}
+		if x.Sign() == 0 && exp.Sign() < 0 {
+			return false
+		}
+	}
+
+	switch node := n.(type) {
+	case *UnaryNode:
+		if node.Op.Is(UnaryMin) && exp.IsInt() {
+			if exp.Num().Bit(0) == 1 {
+				p.coeff.Neg(p.coeff)
+			}
+			return p.add(node.Operand, exp)
+		}
+	case *BinaryNode:
+		switch {
+		case node.Op.Is(Mul) && exp.IsInt():
+			return p.add(node.Lhs, exp) && p.add(node.Rhs, exp)
+		case node.Op.Is(Div) && exp.IsInt():
+			return p.add(node.Lhs, exp) && p.add(node.Rhs, new(big.Rat).Neg(exp))
+		case node.Op.Is(Pow) && exp.IsInt():
+			if e, ok := numberValue(node.Rhs); ok {
+				return p.add(node.Lhs, new(big.Rat).Mul(e, exp))
+			}
+		}
+	case *MatrixNode:
+		if p.matrices++; p.matrices > 1 {
+			return false
+		}
+	}
+
+	p.merge(n, exp)
+	return true
+}
+
+// merge adds the factor base ^ exp, adding up the exponents if the base is
+// already in p.
+func (p *product) merge(base Node, exp *big.Rat) {
+	key := base.String()
+
+	for i, f := range p.factors {
+		if f.base.String() == key {
+			p.factors[i].exp = new(big.Rat).Add(f.exp, exp)
+			return
+		}
+	}
+
+	p.factors = append(p.factors, factor{base, exp})
+}
+
+// node builds p back into a node. The sign of the coefficient is left out and
+// returned separately, so sums can turn a + -b into a - b.
+func (p *product) node() (Node, bool) {
+	var num, den Node
+
+	mul := func(acc, n Node) Node {
+		if acc == nil {
+			return n
+		}
+		return &BinaryNode{Op: op(Mul), Lhs: acc, Rhs: n}
+	}
+
+	coeff := new(big.Rat).Abs(p.coeff)
+	if coeff.Num().Cmp(big.NewInt(1)) != 0 {
+		num = number(new(big.Rat).SetInt(coeff.Num()))
+	}
+	if !coeff.IsInt() {
+		den = number(new(big.Rat).SetInt(coeff.Denom()))
+	}
+
+	for _, f := range p.factors {
+		switch f.exp.Sign() {
+		case 1:
+			num = mul(num, powNode(f.base, number(f.exp)))
+		case -1:
+			den = mul(den, powNode(f.base, number(new(big.Rat).Neg(f.exp))))
+		}
+	}
+
+	if num == nil {
+		num = one()
+	}
+	if den != nil {
+		num = &BinaryNode{Op: op(Div), Lhs: num, Rhs: den}
+	}
+
+	return num, p.coeff.Sign() < 0
+}
+
+// isConst reports whether p has no factors left, like 2 * x / x.
+func (p *product) isConst() bool {
+	for _, f := range p.factors {
+		if f.exp.Sign() != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// key identifies the factors of p, so like terms like 2 * x and 3 * x can be
+// found.
+func (p *product) key() string {
+	if p.isConst() {
+		return ""
+	}
+
+	coeff := p.coeff
+	p.coeff = big.NewRat(1, 1)
+	n, _ := p.node()
+	p.coeff = coeff
+
+	return n.String()
+}
+
+// simplifyProduct collects the constants and like factors of a product or
+// quotient.
+func simplifyProduct(n Node) Node {
+	p := newProduct()
+	if !p.add(n, big.NewRat(1, 1)) {
+		return n
+	}
+
+	if p.coeff.Sign() == 0 {
+		return number(new(big.Rat))
+	}
+
+	res, negative := p.node()
+	if negative {
+		return negate(res)
+	}
+
+	return res
+}
+
+// negate returns -n, with the minus moved onto the first factor of products
+// and quotients so it prints as -x / 2 rather than -(x / 2).
+func negate(n Node) Node {
+	if b, ok := n.(*BinaryNode); ok && (b.Op.Is(Mul) || b.Op.Is(Div)) {
+		return &BinaryNode{Op: b.Op, Lhs: negate(b.Lhs), Rhs: b.Rhs}
+	}
+
+	return negNode(n)
+}
+
+// simplifySum collects the constants and like terms of a sum or difference,
+// keeping them in the order they first appeared in.
+func simplifySum(n Node) Node {
+	var (
+		terms []*product
+		keys  = make(map[string]*product)
+		ok    = true
+	)
+
+	var collect func(n Node, sign int)
+	collect = func(n Node, sign int) {
+		if node, isBinary := n.(*BinaryNode); isBinary && (node.Op.Is(Add) || node.Op.Is(Sub)) {
+			collect(node.Lhs, sign)
+			if node.Op.Is(Sub) {
+				sign = -sign
+			}
+			collect(node.Rhs, sign)
+			return
+		}
+		if node, isUnary := n.(*UnaryNode); isUnary && node.Op.Is(UnaryMin) {
+			collect(node.Operand, -sign)
+			return
+		}
+
+		p := newProduct()
+		if !p.add(n, big.NewRat(1, 1)) {
+			ok = false
+			return
+		}
+		if sign < 0 {
+			p.coeff.Neg(p.coeff)
+		}
+
+		key := p.key()
+		if term, found := keys[key]; found {
+			term.coeff.Add(term.coeff, p.coeff)
+			return
+		}
+		keys[key] = p
+		terms = append(terms, p)
+	}
+
+	collect(n, 1)
+	if !ok {
+		return n
+	}
+
+	var res Node
+	for _, term := range terms {
+		if term.coeff.Sign() == 0 {
+			continue
+		}
+
+		t, negative := term.node()
+		switch {
+		case res == nil && negative:
+			res = negate(t)
+		case res == nil:
+			res = t
+		case negative:
+			res = &BinaryNode{Op: op(Sub), Lhs: res, Rhs: t}
+		default:
+			res = &BinaryNode{Op: op(Add), Lhs: res, Rhs: t}
+		}
+	}
+
+	if res == nil {
+		return number(new(big.Rat))
+	}
+
+	return res
+}
+
+// simplifyPow folds constant powers, and removes the exponents 0 and 1.
+func simplifyPow(n *BinaryNode) Node {
+	base, baseOk := numberValue(n.Lhs)
+	exp, expOk := numberValue(n.Rhs)
+
+	switch {
+	case expOk && exp.Sign() == 0:
+		return one()
+	case expOk && exp.Cmp(big.NewRat(1, 1)) == 0:
+		return n.Lhs
+	case baseOk && expOk:
+		if res, ok := foldPow(base, exp); ok {
+			return number(res)
+		}
+	case expOk:
+		// (x ^ a) ^ n = x ^ (a * n) for integers n, and products like
+		// (2 * x) ^ 2 are written as 4 * x ^ 2
+		if exp.IsInt() {
+			return simplifyProduct(n)
+		}
+	}
+
+	return n
+}
+
+// foldPow computes base ^ exp if the result is exact: integer powers and
+// roots that are rational.
+func foldPow(base, exp *big.Rat) (*big.Rat, bool) {
+	if exp.Num().CmpAbs(big.NewInt(maxFoldExp)) > 0 || !exp.Denom().IsUint64() ||
+		exp.Denom().Uint64() > maxFoldExp {
+		return nil, false
+	}
+	if base.Sign() == 0 && exp.Sign() < 0 {
+		return nil, false
+	}
+
+	res := IntPow(base, exp.Num())
+	if exp.IsInt() {
+		return res, true
+	}
+
+	if res.Sign() < 0 {
+		return nil, false
+	}
+
+	return Root(res, uint(exp.Denom().Uint64()))
+}
@@ -0,0 +1,84 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestSimplify(t *testing.T) {
+	simplified := map[string]string{
+		"2x + 3 * x * 1 - 0 + 2^3": "5 * x + 8",
+		"x / x":                    "1",
+		"x - x":                    "0",
+		"0 * sin(x)":               "0",
+		"(2x) ^ 2":                 "4 * x ^ 2",
+		"x * x ^ 2 / x ^ 3 * y":    "y",
+		"x / 2":                    "x / 2",
+		"[x + x, 2 * 3]":           "[2 * x, 6]",
+		"[1, x] * [x; 1] * [1, x]": "[1, x] * [x; 1] * [1, x]",
+		"-x / 2":                   "-x / 2",
+		"1 - x":                    "1 - x",
+		"-(x + 1) + x":             "-1",
+		"(x ^ 2) ^ 3":              "x ^ 6",
+		"x ^ 0 + y ^ 1":            "1 + y",
+		"4 ^ (1 / 2) * x":          "2 * x",
+		"2 ^ (1 / 2)":              "2 ^ (1 / 2)",
+		"50%":                      "1 / 2",
+		"0 ? y : x + x":            "2 * x",
+		"sin(x + x)":               "sin(2 * x)",
+		"f(x) = x + x":             "f(x) = 2 * x",
+		"1 / 0":                    "1 / 0",
+		"10 ^ 100000":              "10 ^ 100000",
+	}
+
+	for expr, expected := range simplified {
+		res, err := Simplify(expr)
+		if err != nil {
+			t.Errorf("unexpected error simplifying '%s': %s", expr, err)
+			continue
+		}
+
+		if res.String() != expected {
+			t.Errorf("wrong simplification of '%s' (expected '%s', got '%s')", expr, expected, res)
+		}
+
+		// Simplifying twice shouldn't change anything
+		if again := res.Simplify(); again.String() != res.String() {
+			t.Errorf("simplifying '%s' again gives '%s'", res, again)
+		}
+	}
+
+	// The simplified expression has to have the same value
+	for _, expr := range []string{
+		"3x^2 - x * 2 + x / 4 - 7", "(x + 1) ^ 2 * (x + 1) / 3", "-(-x) * -2 + x%",
+		"x ^ 3 * x ^ -2 + 1", "2 ^ x * 2 ^ x / 4", "x - (1 - x) * 2",
+	} {
+		res, err := Simplify(expr)
+		if err != nil {
+			t.Errorf("unexpected error simplifying '%s': %s", expr, err)
+			continue
+		}
+
+		for _, x := range []int64{1, 2, 9} {
+			vars := map[string]*big.Rat{"x": big.NewRat(x, 1)}
+			expected, err := Exec(expr, vars)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := Exec(res.String(), vars)
+			if err != nil || got.Cmp(expected) != 0 {
+				t.Errorf("simplification '%s' of '%s' is wrong at x = %d (expected %s, got %s)",
+					res, expr, x, expected, got)
+			}
+		}
+	}
+
+	if _, err := Simplify("2 +"); err == nil {
+		t.Errorf("no error simplifying bad expression")
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// values flattens the arguments of a statistics function into a list of
+// values. Arguments can be numbers or lists, which are vectors like
+// [1, 2, 3] or any other matrix read row by row, so mean(1, 2, 3) and
+// mean([1, 2, 3]) are the same.
+func values(name string, args []*Complex) ([]*Complex, error) {
+	var res []*Complex
+	for _, arg := range args {
+		if !arg.IsMatrix() {
+			res = append(res, arg)
+			continue
+		}
+
+		for _, row := range arg.Matrix {
+			res = append(res, row...)
+		}
+	}
+
+	if len(res) == 0 {
+		return nil, fmt.Errorf("Expecting values for ‘%s’", name)
+	}
+
+	return res, nil
+}
+
+// realValues is like values, but only takes real numbers.
+func realValues(name string, args []*Complex) ([]*big.Rat, error) {
+	vals, err := values(name, args)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*big.Rat, len(vals))
+	for i, val := range vals {
+		if !val.IsReal() {
+			return nil, fmt.Errorf("Expecting real numbers for ‘%s’", name)
+		}
+		res[i] = val.Re
+	}
+
+	return res, nil
+}
+
+// sortedValues is like realValues, with the values sorted from small to large.
+func sortedValues(name string, args []*Complex) ([]*big.Rat, error) {
+	vals, err := realValues(name, args)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(vals, func(i, j int) bool {
+		return vals[i].Cmp(vals[j]) < 0
+	})
+
+	return vals, nil
+}
+
+// total adds up vals.
+func total(vals []*Complex) *Complex {
+	res := realComplex(new(big.Rat))
+	for _, val := range vals {
+		res = res.add(val)
+	}
+
+	return res
+}
+
+// mean is the average of vals.
+func mean(vals []*Complex) *Complex {
+	res, _ := total(vals).quo(realComplex(big.NewRat(int64(len(vals)), 1)))
+	return res
+}
+
+// median is the middle value of sorted values, or the mean of the two middle
+// values if there's an even number of them.
+func median(sorted []*big.Rat) *big.Rat {
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return new(big.Rat).Set(sorted[mid])
+	}
+
+	res := new(big.Rat).Add(sorted[mid-1], sorted[mid])
+	return res.Quo(res, big.NewRat(2, 1))
+}
+
+// quartiles returns the first and third quartile of sorted values, which are
+// the medians of the lower and upper half. The median itself is left out of
+// both halves if there's an odd number of values, like most calculators do.
+func quartiles(sorted []*big.Rat) (*big.Rat, *big.Rat) {
+	if len(sorted) == 1 {
+		return new(big.Rat).Set(sorted[0]), new(big.Rat).Set(sorted[0])
+	}
+
+	half := len(sorted) / 2
+	return median(sorted[:half]), median(sorted[len(sorted)-half:])
+}
+
+// mode returns the value that occurs most often in sorted values, the smallest
+// one if there's a tie.
+func mode(sorted []*big.Rat) *big.Rat {
+	best, bestCount := sorted[0], 0
+	for i := 0; i < len(sorted); {
+		j := i
+		for j < len(sorted) && sorted[j].Cmp(sorted[i]) == 0 {
+			j++
+		}
+
+		if j-i > bestCount {
+			best, bestCount = sorted[i], j-i
+		}
+		i = j
+	}
+
+	return new(big.Rat).Set(best)
+}
+
+// variance returns the variance of vals. The sample variance divides by one
+// less than the number of values, the population variance by the number of
+// values.
+func variance(name string, vals []*big.Rat, sample bool) (*big.Rat, error) {
+	n := int64(len(vals))
+	if sample {
+		if n < 2 {
+			return nil, fmt.Errorf("Expecting at least 2 values for ‘%s’", name)
+		}
+		n--
+	}
+
+	avg := new(big.Rat)
+	for _, val := range vals {
+		avg.Add(avg, val)
+	}
+	avg.Quo(avg, big.NewRat(int64(len(vals)), 1))
+
+	res := new(big.Rat)
+	for _, val := range vals {
+		d := new(big.Rat).Sub(val, avg)
+		res.Add(res, d.Mul(d, d))
+	}
+
+	return res.Quo(res, big.NewRat(n, 1)), nil
+}
+
+// aggregate evaluates the list form of sum and prod, like sum(1, 2, 3) or
+// sum([1, 2, 3]), with combine adding or multiplying the values.
+func (p *Parser) aggregate(name string, args []Node, init *Complex,
+	combine func(acc, val *Complex) *Complex) (*Complex, error) {
+	evaluated := make([]*Complex, len(args))
+	for i, arg := range args {
+		val, err := p.evaluate(arg)
+		if err != nil {
+			return nil, err
+		}
+		evaluated[i] = val
+	}
+
+	vals, err := values(name, evaluated)
+	if err != nil {
+		return nil, err
+	}
+
+	acc := init
+	for _, val := range vals {
+		acc = combine(acc, val)
+	}
+
+	return acc, nil
+}
+
+// statistic registers a statistics function taking any number of values.
+func statistic(name string, fn func(p *Parser, args []*Complex) (*Complex, error)) {
+	funcs.register(name, function{arity: -1, matrix: true, cfn: fn})
+}
+
+// sortedStatistic registers a statistics function of the sorted real values.
+func sortedStatistic(name string, fn func(sorted []*big.Rat) *big.Rat) {
+	statistic(name, func(_ *Parser, args []*Complex) (*Complex, error) {
+		sorted, err := sortedValues(name, args)
+		if err != nil {
+			return nil, err
+		}
+		return realComplex(fn(sorted)), nil
+	})
+}
+
+func init() {
+	statistic("mean", func(_ *Parser, args []*Complex) (*Complex, error) {
+		vals, err := values("mean", args)
+		if err != nil {
+			return nil, err
+		}
+		return mean(vals), nil
+	})
+	statistic("count", func(_ *Parser, args []*Complex) (*Complex, error) {
+		count := 0
+		for _, arg := range args {
+			count += len(asMatrix(arg)) * len(asMatrix(arg)[0])
+		}
+		return realComplex(big.NewRat(int64(count), 1)), nil
+	})
+	sortedStatistic("median", median)
+	sortedStatistic("mode", mode)
+	sortedStatistic("max", func(sorted []*big.Rat) *big.Rat {
+		return new(big.Rat).Set(sorted[len(sorted)-1])
+	})
+	sortedStatistic("min", func(sorted []*big.Rat) *big.Rat {
+		return new(big.Rat).Set(sorted[0])
+	})
+	sortedStatistic("q1", func(sorted []*big.Rat) *big.Rat {
+		q1, _ := quartiles(sorted)
+		return q1
+	})
+	sortedStatistic("q3", func(sorted []*big.Rat) *big.Rat {
+		_, q3 := quartiles(sorted)
+		return q3
+	})
+	statistic("quartiles", func(_ *Parser, args []*Complex) (*Complex, error) {
+		sorted, err := sortedValues("quartiles", args)
+		if err != nil {
+			return nil, err
+		}
+		q1, q3 := quartiles(sorted)
+		return &Complex{Matrix: Matrix{{realComplex(q1), realComplex(median(sorted)), realComplex(q3)}}}, nil
+	})
+
+	for _, v := range []struct {
+		variance, stdev string
+		sample          bool
+	}{{"var", "stdev", true}, {"pvar", "pstdev", false}} {
+		v := v
+		statistic(v.variance, func(_ *Parser, args []*Complex) (*Complex, error) {
+			vals, err := realValues(v.variance, args)
+			if err != nil {
+				return nil, err
+			}
+			res, err := variance(v.variance, vals, v.sample)
+			if err != nil {
+				return nil, err
+			}
+			return realComplex(res), nil
+		})
+		statistic(v.stdev, func(p *Parser, args []*Complex) (*Complex, error) {
+			vals, err := realValues(v.stdev, args)
+			if err != nil {
+				return nil, err
+			}
+			res, err := variance(v.stdev, vals, v.sample)
+			if err != nil {
+				return nil, err
+			}
+			// Exact when the variance is a square, like for 2, 4, 4, 4, 5, 5, 7, 9
+			return p.csqrt(realComplex(res))
+		})
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2016 Steven Oud. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can be found
+// in the LICENSE file.
+
+package mathcat
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	okExpressions := map[string]string{
+		"mean(1, 2, 3, 4)":                        "5/2",
+		"mean([1, 2, 3, 4])":                      "5/2",
+		"mean([1, 2], [3; 4])":                    "5/2",
+		"mean(1 / 3, 2 / 3)":                      "1/2",
+		"mean(i, 1)":                              "1/2 + 1/2i",
+		"median(3, 1, 2)":                         "2",
+		"median(4, 1, 3, 2)":                      "5/2",
+		"median([7])":                             "7",
+		"mode(3, 1, 2, 2, 3)":                     "2",
+		"mode(5)":                                 "5",
+		"mode([1, 2; 2, 1], 1)":                   "1",
+		"var(2, 4, 4, 4, 5, 5, 7, 9)":             "32/7",
+		"pvar(2, 4, 4, 4, 5, 5, 7, 9)":            "4",
+		"pstdev([2, 4, 4, 4, 5, 5, 7, 9])":        "2",
+		"sum(1, 2, 3)":                            "6",
+		"sum([1, 2; 3, 4])":                       "10",
+		"sum(1)":                                  "1",
+		"sum(i, 1)":                               "1 + i",
+		"sum(k, 1, 3, k)":                         "6",
+		"sum(1, 2, 3, 4, 5)":                      "15",
+		"sum(i, 1, 2, 3)":                         "6",
+		"sum([i, 1, 2, 3])":                       "6 + i",
+		"prod([i, 1, 2, 3])":                      "6i",
+		"prod([1, 2, 3, 4])":                      "24",
+		"prod(1 / 2, 4)":                          "2",
+		"count(1, 2, 3)":                          "3",
+		"count([1, 2; 3, 4], 5)":                  "5",
+		"count()":                                 "0",
+		"q1(1, 2, 3, 4, 5, 6, 7, 8)":              "5/2",
+		"q3(1, 2, 3, 4, 5, 6, 7, 8)":              "13/2",
+		"quartiles(7, 6, 5, 4, 3, 2, 1)":          "[2, 4, 6]",
+		"quartiles(1)":                            "[1, 1, 1]",
+		"max(3, 1, 4, 1, 5)":                      "5",
+		"min(3, 1, 4, 1, 5)":                      "1",
+		"max(2)":                                  "2",
+		"max([1, 9; 3, 4], 5)":                    "9",
+		"max(q3(1, 2, 3, 4), 1) - q1(1, 2, 3, 4)": "2",
+	}
+
+	for expr, expected := range okExpressions {
+		res, err := New().RunComplex(expr)
+		if err != nil {
+			t.Errorf("unexpected error in '%s': %s", expr, err)
+			continue
+		}
+
+		if res.String() != expected {
+			t.Errorf("wrong result in expression '%s' (expected %s, got %s)", expr, expected, res)
+		}
+	}
+
+	// The standard deviation is only exact if the variance is a square
+	if res, err := New().RunComplex("stdev(1, 3)"); err != nil || res.Text(func(x *big.Rat) string {
+		return x.FloatString(6)
+	}) != "1.414214" {
+		t.Errorf("wrong result of stdev(1, 3) (expected 1.414214, got %s, %v)", res, err)
+	}
+
+	// Defining the variable doesn't turn a sum over it into a list
+	p := New()
+	if _, err := p.Run("k = 5"); err != nil {
+		t.Fatal(err)
+	}
+	for expr, expected := range map[string]string{"sum(k, 1, 3, 2)": "6", "sum([k, 1, 2, 3])": "11", "k": "5"} {
+		if res, err := p.RunComplex(expr); err != nil || res.String() != expected {
+			t.Errorf("wrong result of '%s' with k = 5 (expected %s, got %s, %v)", expr, expected, res, err)
+		}
+	}
+
+	badExpressions := []string{
+		"mean()",
+		"median()",
+		"median(i, 1)",
+		"mode(i)",
+		"var(1)",
+		"stdev([5])",
+		"max()",
+		"min([1, i])",
+		"sum()",
+		"prod()",
+		"quartiles()",
+		"sum(1, x)",
+	}
+
+	for _, expr := range badExpressions {
+		if _, err := New().RunComplex(expr); err == nil {
+			t.Errorf("no error in bad expression '%s'", expr)
+		}
+	}
+}
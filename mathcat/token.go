@@ -33,14 +33,19 @@ const (
 	Sub      // -
 	Div      // /
 	Mul      // *
-	Pow      // **
+	Pow      // ^
 	Rem      // %
+	LeftDiv  // \
 	UnaryMin // -
 
+	Fact       // !
+	DoubleFact // !!
+	Percent    // %
+
 	bitwiseBegin
 	And // &
 	Or  // |
-	Xor // ^
+	Xor // ^^
 	Lsh // <<
 	Rsh // >>
 	Not // ~
@@ -48,7 +53,7 @@ const (
 	assignmentBegin
 	AndEq // &=
 	OrEq  // |=
-	XorEq // ^=
+	XorEq // ^^=
 	LshEq // <<=
 	RshEq // >>=
 	bitwiseEnd
@@ -58,7 +63,7 @@ const (
 	SubEq // -=
 	DivEq // /=
 	MulEq // *=
-	PowEq // **=
+	PowEq // ^=
 	RemEq // %=
 	assignmentEnd
 
@@ -68,11 +73,20 @@ const (
 	GtEq  // >=
 	Lt    // <
 	LtEq  // <=
+
+	LogAnd   // &&
+	LogOr    // ||
+	LogNot   // !
+	Question // ?
+	Colon    // :
 	operatorsEnd
 
-	Lparen // (
-	Rparen // )
-	Comma  // ,
+	Lparen    // (
+	Rparen    // )
+	Comma     // ,
+	Lbracket  // [
+	Rbracket  // ]
+	Semicolon // ;
 )
 
 var tokens = map[TokenType]string{
@@ -88,13 +102,18 @@ var tokens = map[TokenType]string{
 	Sub:      "-",
 	Div:      "/",
 	Mul:      "*",
-	Pow:      "**",
+	Pow:      "^",
 	Rem:      "%",
+	LeftDiv:  "\\",
 	UnaryMin: "-",
 
+	Fact:       "!",
+	DoubleFact: "!!",
+	Percent:    "%",
+
 	And: "&",
 	Or:  "|",
-	Xor: "^",
+	Xor: "^^",
 	Lsh: "<<",
 	Rsh: ">>",
 	Not: "~",
@@ -104,12 +123,12 @@ var tokens = map[TokenType]string{
 	SubEq: "-=",
 	DivEq: "/=",
 	MulEq: "*=",
-	PowEq: "**=",
+	PowEq: "^=",
 	RemEq: "%=",
 
 	AndEq: "&=",
 	OrEq:  "|=",
-	XorEq: "^=",
+	XorEq: "^^=",
 	LshEq: "<<=",
 	RshEq: ">>=",
 
@@ -120,9 +139,18 @@ var tokens = map[TokenType]string{
 	Lt:    "<",
 	LtEq:  "<=",
 
-	Lparen: "(",
-	Rparen: ")",
-	Comma:  ",",
+	LogAnd:   "&&",
+	LogOr:    "||",
+	LogNot:   "!",
+	Question: "?",
+	Colon:    ":",
+
+	Lparen:    "(",
+	Rparen:    ")",
+	Comma:     ",",
+	Lbracket:  "[",
+	Rbracket:  "]",
+	Semicolon: ";",
 }
 
 func (tok Token) String() string {